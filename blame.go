@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// blameAuthors returns the author of each line of the file by "git blame".
+// The author of line n is at index n-1.
+func blameAuthors(filename string) ([]string, error) {
+	cmd := exec.Command("git", "blame", "--line-porcelain", "--", filepath.Base(filename))
+	cmd.Dir = filepath.Dir(filename)
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("git blame %s: %v: %s", filename, err, strings.TrimSpace(stderr.String()))
+	}
+	return parseBlamePorcelain(stdout)
+}
+
+// parseBlamePorcelain parses output of "git blame --line-porcelain".
+func parseBlamePorcelain(r io.Reader) ([]string, error) {
+	var authors []string
+	var line int
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		text := s.Text()
+		switch {
+		case strings.HasPrefix(text, "\t"):
+			// Content of the line.
+		case strings.HasPrefix(text, "author "):
+			for len(authors) < line {
+				authors = append(authors, "")
+			}
+			authors[line-1] = strings.TrimPrefix(text, "author ")
+		default:
+			// Header: <sha> <orig line> <final line> [<num lines>]
+			fields := strings.Fields(text)
+			if len(fields) >= 3 && len(fields[0]) >= 40 {
+				n, err := strconv.Atoi(fields[2])
+				if err != nil {
+					return nil, fmt.Errorf("invalid blame header: %q", text)
+				}
+				line = n
+			}
+		}
+	}
+	return authors, s.Err()
+}
+
+// gitRoot returns the root directory of the git repository of the current
+// directory.
+func gitRoot() (string, error) {
+	out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return "", fmt.Errorf("cannot find git repository: %v", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// changedFiles returns paths of files changed since the git ref in the
+// repository at root.
+func changedFiles(root, ref string) (map[string]bool, error) {
+	cmd := exec.Command("git", "diff", "--name-only", "-z", ref, "--")
+	cmd.Dir = root
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff %s: %v: %s", ref, err, strings.TrimSpace(stderr.String()))
+	}
+	files := make(map[string]bool)
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name != "" {
+			files[filepath.Join(root, filepath.FromSlash(name))] = true
+		}
+	}
+	return files, nil
+}
+
+// changedLines returns lines added or modified since the git ref per path of
+// files in the repository at root.
+func changedLines(root, ref string) (map[string]map[int]bool, error) {
+	cmd := exec.Command("git", "diff", "-U0", "--no-color", "--no-ext-diff", ref, "--")
+	cmd.Dir = root
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff %s: %v: %s", ref, err, strings.TrimSpace(stderr.String()))
+	}
+	lines, err := parseDiffLines(bytes.NewReader(out))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]map[int]bool, len(lines))
+	for name, ls := range lines {
+		files[filepath.Join(root, filepath.FromSlash(name))] = ls
+	}
+	return files, nil
+}
+
+// hunkRe matches a hunk header of a unified diff and captures the start and
+// the number of lines of the new file.
+var hunkRe = regexp.MustCompile(`^@@ -[0-9,]+ \+([0-9]+)(?:,([0-9]+))? @@`)
+
+// parseDiffLines parses the output of "git diff -U0" and returns added or
+// modified lines per slash separated path of new files. Deleted files are
+// not included.
+func parseDiffLines(r io.Reader) (map[string]map[int]bool, error) {
+	files := make(map[string]map[int]bool)
+	var cur map[int]bool
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, "+++ ") {
+			name := strings.TrimPrefix(line, "+++ ")
+			if strings.HasPrefix(name, `"`) {
+				if uq, err := strconv.Unquote(name); err == nil {
+					name = uq
+				}
+			}
+			cur = nil
+			if name != "/dev/null" {
+				cur = make(map[int]bool)
+				files[strings.TrimPrefix(name, "b/")] = cur
+			}
+			continue
+		}
+		m := hunkRe.FindStringSubmatch(line)
+		if m == nil || cur == nil {
+			continue
+		}
+		start, _ := strconv.Atoi(m[1])
+		n := 1
+		if m[2] != "" {
+			n, _ = strconv.Atoi(m[2])
+		}
+		for l := start; l < start+n; l++ {
+			cur[l] = true
+		}
+	}
+	return files, s.Err()
+}
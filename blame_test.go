@@ -0,0 +1,35 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseBlamePorcelain(t *testing.T) {
+	const in = `c0a56401a11b8b93fa383fe6b36618f424755225 1 1 1
+author Alice
+author-mail <a@example.com>
+summary x
+filename f.go
+	package f
+0000000000000000000000000000000000000000 2 2 1
+author Not Committed Yet
+author-mail <not.committed.yet>
+filename f.go
+	// comment
+c0a56401a11b8b93fa383fe6b36618f424755225 3 3 1
+author Alice
+author-mail <a@example.com>
+filename f.go
+	func f() {}
+`
+	got, err := parseBlamePorcelain(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Alice", "Not Committed Yet", "Alice"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
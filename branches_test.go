@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+const branchesTestSource = `package a
+
+func F(x int) int {
+	if x > 0 {
+		return 1
+	} else if x < 0 {
+		return -1
+	} else {
+		return 0
+	}
+}
+
+func G(x int) {
+	switch x {
+	case 1:
+		println(1)
+	case 2:
+	default:
+		println(0)
+	}
+}
+`
+
+func TestWriteBranches(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-branches")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "a.go")
+	if err := ioutil.WriteFile(filename, []byte(branchesTestSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Blocks as written by "go test -cover" for the source, where F is called
+	// with positive and zero and G with 1.
+	p := &cover.Profile{FileName: "example.com/a/a.go", Mode: "count", Blocks: []cover.ProfileBlock{
+		{StartLine: 4, StartCol: 2, EndLine: 4, EndCol: 11, NumStmt: 1, Count: 2},
+		{StartLine: 5, StartCol: 3, EndLine: 6, EndCol: 1, NumStmt: 1, Count: 1},
+		{StartLine: 6, StartCol: 9, EndLine: 6, EndCol: 18, NumStmt: 1, Count: 1},
+		{StartLine: 7, StartCol: 3, EndLine: 8, EndCol: 1, NumStmt: 1, Count: 0},
+		{StartLine: 9, StartCol: 3, EndLine: 10, EndCol: 1, NumStmt: 1, Count: 1},
+		{StartLine: 14, StartCol: 2, EndLine: 14, EndCol: 11, NumStmt: 1, Count: 1},
+		{StartLine: 16, StartCol: 3, EndLine: 16, EndCol: 13, NumStmt: 1, Count: 1},
+		{StartLine: 17, StartCol: 9, EndLine: 17, EndCol: 9, NumStmt: 0, Count: 0},
+		{StartLine: 19, StartCol: 3, EndLine: 19, EndCol: 13, NumStmt: 1, Count: 0},
+	}}
+	resolve := func(string) (string, error) { return filename, nil }
+	var buf bytes.Buffer
+	if err := writeBranches(&buf, []*cover.Profile{p}, resolve); err != nil {
+		t.Fatal(err)
+	}
+	name := displayPath(filename)
+	want := name + ":6:18: if branch not covered\n" +
+		name + ":18:9: default branch not covered\n" +
+		"branch coverage: 60.0% (3/5 arms)\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
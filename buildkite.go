@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/tools/cover"
+)
+
+// writeBuildkiteAnnotation writes a Markdown summary of total coverage and
+// failed packages, which can be passed to "buildkite-agent annotate".
+func writeBuildkiteAnnotation(w io.Writer, profiles []*cover.Profile, results []*packageResult) error {
+	var b strings.Builder
+	s := statementStats(profiles)
+	fmt.Fprintf(&b, "### Coverage: %.1f%% of statements\n\n", s.percent())
+	fmt.Fprintf(&b, "%d of %d statements covered in %d package(s).\n", s.Covered, s.Total, len(results))
+	var failed []*packageResult
+	for _, r := range results {
+		if !r.Success {
+			failed = append(failed, r)
+		}
+	}
+	if len(failed) > 0 {
+		fmt.Fprintf(&b, "\n**%d package(s) failed**\n", len(failed))
+		for _, r := range failed {
+			output := r.output()
+			fmt.Fprintf(&b, "\n<details><summary><code>%s</code> (%s)</summary>\n\n```term\n%s\n```\n\n</details>\n", r.Pkg, classifyFailure(output), tailLines(output, outputTailLines))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// annotateBuildkite runs "buildkite-agent annotate" with the annotation. The
+// style is "error" if some packages failed.
+func annotateBuildkite(annotation []byte, failed bool) error {
+	style := "success"
+	if failed {
+		style = "error"
+	}
+	cmd := exec.Command("buildkite-agent", "annotate", "--context", "goverage", "--style", style)
+	cmd.Stdin = bytes.NewReader(annotation)
+	cmd.Stdout = testOutput
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("buildkite-agent annotate: %v", err)
+	}
+	return nil
+}
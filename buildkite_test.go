@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestWriteBuildkiteAnnotation(t *testing.T) {
+	profiles := []*cover.Profile{{Blocks: []cover.ProfileBlock{{NumStmt: 3, Count: 1}, {NumStmt: 1, Count: 0}}}}
+	results := []*packageResult{
+		{Pkg: "example.com/a", Success: true},
+		{Pkg: "example.com/b", ExitCode: 1, Stdout: []byte("--- FAIL: TestB (0.00s)\nFAIL\n")},
+	}
+	var buf bytes.Buffer
+	if err := writeBuildkiteAnnotation(&buf, profiles, results); err != nil {
+		t.Fatal(err)
+	}
+	const want = "### Coverage: 75.0% of statements\n" +
+		"\n" +
+		"3 of 4 statements covered in 2 package(s).\n" +
+		"\n" +
+		"**1 package(s) failed**\n" +
+		"\n" +
+		"<details><summary><code>example.com/b</code> (test)</summary>\n" +
+		"\n" +
+		"```term\n" +
+		"--- FAIL: TestB (0.00s)\n" +
+		"FAIL\n" +
+		"```\n" +
+		"\n" +
+		"</details>\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/cover"
+)
+
+// cacheVersion is included in cache keys. Bump it when the format of cached
+// profiles or the way to compute keys changes.
+const cacheVersion = "goverage-cache-v2"
+
+// profileCache caches cover profiles of packages whose tests succeeded, keyed
+// by a hash of the inputs of the tests.
+type profileCache struct {
+	dir string
+	// remote is a shared cache behind the local one. It may be nil.
+	remote *remoteCache
+}
+
+// newProfileCache returns a cache in dir. If dir is empty, the cache is placed
+// in the user cache directory. remote may be nil.
+func newProfileCache(dir string, remote *remoteCache) (*profileCache, error) {
+	if dir == "" {
+		userDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(userDir, "goverage")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &profileCache{dir: dir, remote: remote}, nil
+}
+
+func (c *profileCache) filename(key string) string {
+	return filepath.Join(c.dir, key+".out")
+}
+
+// get returns cached profiles for the key. ok is false if there is no entry.
+func (c *profileCache) get(key string) (profiles []*cover.Profile, ok bool) {
+	filename := c.filename(key)
+	if !isExist(filename) {
+		if c.remote == nil || !c.fetch(key) {
+			return nil, false
+		}
+	}
+	profiles, err := cover.ParseProfiles(filename)
+	if err != nil {
+		// Treat a broken entry as missing. It will be overwritten.
+		return nil, false
+	}
+	return profiles, true
+}
+
+// put stores profiles for the key.
+func (c *profileCache) put(key string, profiles []*cover.Profile) error {
+	tmp := c.filename(key) + ".tmp"
+	if err := writeProfile(tmp, profiles); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.filename(key)); err != nil {
+		return err
+	}
+	if c.remote != nil && !c.remote.readOnly {
+		b, err := ioutil.ReadFile(c.filename(key))
+		if err != nil {
+			return err
+		}
+		return c.remote.put(key, b)
+	}
+	return nil
+}
+
+// fetch downloads the entry from the remote cache to the local one. Errors are
+// logged and treated as a cache miss not to fail the run.
+func (c *profileCache) fetch(key string) bool {
+	b, ok, err := c.remote.get(key)
+	if err != nil {
+		log.Printf("failed to get %s from remote cache: %v", key, err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	tmp := c.filename(key) + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		log.Printf("failed to store remote cache entry %s: %v", key, err)
+		return false
+	}
+	return os.Rename(tmp, c.filename(key)) == nil
+}
+
+// testInputs is the result of "go list -test -deps" for target packages,
+// which is used to compute cache keys.
+type testInputs struct {
+	// pkgs maps an import path to the package. Test variants are not
+	// included.
+	pkgs map[string]*listPackage
+	// deps maps an import path of a target package to import paths of
+	// packages its test binary depends on, including itself.
+	deps map[string][]string
+
+	fileHashes map[string]string
+}
+
+// listTestInputs lists packages which tests of importPaths depend on.
+func listTestInputs(importPaths []string) (*testInputs, error) {
+	cmd := goList(append([]string{"-test", "-deps", "-json"}, importPaths...)...)
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to run 'go list -test -deps': %v\n%s", err, stderr.Bytes())
+	}
+	listed, err := decodeListPackages(stdout)
+	if err != nil {
+		return nil, err
+	}
+	in := &testInputs{
+		pkgs:       make(map[string]*listPackage),
+		deps:       make(map[string][]string),
+		fileHashes: make(map[string]string),
+	}
+	testMains := make(map[string]*listPackage)
+	for _, p := range listed {
+		if strings.HasSuffix(p.ImportPath, ".test") {
+			testMains[strings.TrimSuffix(p.ImportPath, ".test")] = p
+			continue
+		}
+		if strings.Contains(p.ImportPath, " [") {
+			// Test variant such as "a [a.test]" has same files as the base
+			// package.
+			continue
+		}
+		in.pkgs[p.ImportPath] = p
+	}
+	for _, importPath := range importPaths {
+		deps := []string{importPath}
+		if m, ok := testMains[importPath]; ok {
+			for _, d := range m.Deps {
+				if i := strings.Index(d, " ["); i >= 0 {
+					d = d[:i]
+				}
+				deps = append(deps, d)
+			}
+		} else if p, ok := in.pkgs[importPath]; ok {
+			deps = append(deps, p.Deps...)
+		}
+		in.deps[importPath] = uniqueStrings(deps)
+	}
+	return in, nil
+}
+
+// key returns the cache key for the package. It covers goverage and go
+// versions, args and env for "go test", all source files (including C and
+// assembly files and embedded files) of packages the test depends on,
+// excluding the standard library, and test files, embedded test files and
+// testdata of the package.
+func (in *testInputs) key(importPath, goVersion string, args, env []string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n", cacheVersion, goVersion, importPath)
+	fmt.Fprintf(h, "args %q\nenv %q\n", args, env)
+	for _, d := range in.deps[importPath] {
+		p, ok := in.pkgs[d]
+		if !ok || p.Standard {
+			continue
+		}
+		var files []string
+		for _, fs := range [][]string{p.GoFiles, p.CgoFiles, p.EmbedFiles, p.CFiles, p.CXXFiles, p.SFiles, p.HFiles} {
+			files = append(files, fs...)
+		}
+		if d == importPath {
+			for _, fs := range [][]string{p.TestGoFiles, p.XTestGoFiles, p.TestEmbedFiles, p.XTestEmbedFiles} {
+				files = append(files, fs...)
+			}
+			testdata, err := testdataFiles(p.Dir)
+			if err != nil {
+				return "", err
+			}
+			files = append(files, testdata...)
+		}
+		files = uniqueStrings(files)
+		sort.Strings(files)
+		for _, f := range files {
+			sum, err := in.fileHash(filepath.Join(p.Dir, filepath.FromSlash(f)))
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(h, "file %s/%s %s\n", d, f, sum)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// testdataFiles returns slash separated paths of files in the testdata
+// directory of the package directory dir, relative to dir. Tests often read
+// them.
+func testdataFiles(dir string) ([]string, error) {
+	root := filepath.Join(dir, "testdata")
+	if !isExist(root) {
+		return nil, nil
+	}
+	var files []string
+	err := filepath.Walk(root, func(name string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			rel, err := filepath.Rel(dir, name)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return files, err
+}
+
+func (in *testInputs) fileHash(filename string) (string, error) {
+	if sum, ok := in.fileHashes[filename]; ok {
+		return sum, nil
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	in.fileHashes[filename] = sum
+	return sum, nil
+}
+
+// depProfiles returns profiles of files in packages the test of importPath
+// depends on. Blocks of other files in a cached profile may be stale because
+// the key doesn't cover their sources, and the test never runs them anyway.
+func (in *testInputs) depProfiles(importPath string, profiles []*cover.Profile) []*cover.Profile {
+	deps := make(map[string]bool)
+	for _, d := range in.deps[importPath] {
+		deps[d] = true
+	}
+	result := make([]*cover.Profile, 0, len(profiles))
+	for _, p := range profiles {
+		if deps[path.Dir(p.FileName)] {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// goVersion returns the output of "go version".
+func goVersion() (string, error) {
+	out, err := exec.Command("go", "version").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func uniqueStrings(ss []string) []string {
+	seen := make(map[string]bool, len(ss))
+	result := ss[:0]
+	for _, s := range ss {
+		if !seen[s] {
+			seen[s] = true
+			result = append(result, s)
+		}
+	}
+	return result
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestProfileCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c, err := newProfileCache(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.get("key"); ok {
+		t.Fatal("got entry from empty cache")
+	}
+	profiles := []*cover.Profile{
+		{FileName: "example.com/a/a.go", Mode: "set", Blocks: []cover.ProfileBlock{
+			{StartLine: 3, StartCol: 10, EndLine: 5, EndCol: 2, NumStmt: 1, Count: 1},
+		}},
+	}
+	if err := c.put("key", profiles); err != nil {
+		t.Fatal(err)
+	}
+	got, ok := c.get("key")
+	if !ok {
+		t.Fatal("cache entry not found")
+	}
+	if !reflect.DeepEqual(got, profiles) {
+		t.Errorf("got %+v, want %+v", got, profiles)
+	}
+}
+
+func TestTestInputs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-inputs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	in := &testInputs{
+		pkgs: map[string]*listPackage{
+			"example.com/a": {ImportPath: "example.com/a", Dir: dir, GoFiles: []string{"a.go"}},
+			"fmt":           {ImportPath: "fmt", Standard: true},
+		},
+		deps: map[string][]string{
+			"example.com/a": {"example.com/a", "fmt"},
+		},
+		fileHashes: map[string]string{},
+	}
+	key1, err := in.key("example.com/a", "go1.20", []string{"-short"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, _ := in.key("example.com/a", "go1.20", nil, nil); key == key1 {
+		t.Error("key doesn't depend on args")
+	}
+	if key, _ := in.key("example.com/a", "go1.21", []string{"-short"}, nil); key == key1 {
+		t.Error("key doesn't depend on go version")
+	}
+	in.fileHashes = map[string]string{}
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.go"), []byte("package a // changed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if key, _ := in.key("example.com/a", "go1.20", []string{"-short"}, nil); key == key1 {
+		t.Error("key doesn't depend on source files")
+	}
+
+	for name, add := range map[string]func(p *listPackage, f string){
+		"testdata/in.txt": func(p *listPackage, f string) {},
+		"a.s":             func(p *listPackage, f string) { p.SFiles = append(p.SFiles, f) },
+		"a.h":             func(p *listPackage, f string) { p.HFiles = append(p.HFiles, f) },
+		"test.txt":        func(p *listPackage, f string) { p.TestEmbedFiles = append(p.TestEmbedFiles, f) },
+	} {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte("1"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		add(in.pkgs["example.com/a"], name)
+		in.fileHashes = map[string]string{}
+		before, err := in.key("example.com/a", "go1.20", nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte("2"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		in.fileHashes = map[string]string{}
+		if after, _ := in.key("example.com/a", "go1.20", nil, nil); after == before {
+			t.Errorf("key doesn't depend on %s", name)
+		}
+	}
+
+	profiles := []*cover.Profile{{FileName: "example.com/a/a.go"}, {FileName: "example.com/b/b.go"}}
+	got := in.depProfiles("example.com/a", profiles)
+	if len(got) != 1 || got[0].FileName != "example.com/a/a.go" {
+		t.Errorf("unexpected depProfiles: %+v", got)
+	}
+}
+
+func TestRun_cache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("./example/root"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	useCache, cacheDir = true, dir
+	defer func() { useCache, cacheDir = false, "" }()
+	profiles := make([]string, 2)
+	for i := range profiles {
+		out := filepath.Join(dir, "coverage.out")
+		if err := run(out, []string{"./..."}, "count", "", "", "", false, false); err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		profiles[i] = string(b)
+	}
+	if profiles[0] != profiles[1] {
+		t.Errorf("first run:\n%v\ncached run:\n%v", profiles[0], profiles[1])
+	}
+	entries, err := filepath.Glob(filepath.Join(dir, "*.out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// coverage.out and an entry for each package.
+	if len(entries) != 3 {
+		t.Errorf("unexpected cache entries: %v", entries)
+	}
+}
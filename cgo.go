@@ -0,0 +1,55 @@
+package main
+
+import (
+	"path"
+	"strings"
+
+	"golang.org/x/tools/cover"
+)
+
+// cgoSource returns the original file name of name if it's a file generated
+// by cgo from it, such as "pkg/_obj/foo.cgo1.go" for "pkg/foo.go". ok is
+// false for other files.
+func cgoSource(name string) (source string, ok bool) {
+	dir, base := path.Split(name)
+	if !strings.HasSuffix(base, ".cgo1.go") {
+		return "", false
+	}
+	dir = strings.TrimSuffix(dir, "/")
+	if path.Base(dir) == "_obj" {
+		dir = path.Dir(dir)
+	}
+	return path.Join(dir, strings.TrimSuffix(base, ".cgo1.go")+".go"), true
+}
+
+// cgoGenerated reports whether name is a file generated by cgo, which does not
+// exist in the source tree.
+func cgoGenerated(name string) bool {
+	base := path.Base(name)
+	return strings.HasPrefix(base, "_cgo_") || strings.HasSuffix(base, ".cgo1.go") || strings.HasSuffix(base, ".cgo2.go")
+}
+
+// fixCgoProfiles returns profiles whose files generated by cgo are mapped back
+// to their original files. Generated files without original ones, such as
+// _cgo_gotypes.go, are dropped if skip is true and kept as is otherwise.
+func fixCgoProfiles(profiles []*cover.Profile, skip bool) []*cover.Profile {
+	result := make([]*cover.Profile, 0, len(profiles))
+	mapped := false
+	for _, p := range profiles {
+		if source, ok := cgoSource(p.FileName); ok {
+			c := *p
+			c.FileName = source
+			result = append(result, &c)
+			mapped = true
+			continue
+		}
+		if skip && cgoGenerated(p.FileName) {
+			continue
+		}
+		result = append(result, p)
+	}
+	if !mapped {
+		return result
+	}
+	return mergeProfiles([][]*cover.Profile{result})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestCgoSource(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		ok     bool
+	}{
+		{"example.com/p/_obj/foo.cgo1.go", "example.com/p/foo.go", true},
+		{"example.com/p/foo.cgo1.go", "example.com/p/foo.go", true},
+		{"example.com/p/_obj/_cgo_gotypes.go", "", false},
+		{"example.com/p/foo.go", "", false},
+	}
+	for _, tt := range tests {
+		source, ok := cgoSource(tt.name)
+		if source != tt.source || ok != tt.ok {
+			t.Errorf("cgoSource(%q) = %q, %v, want %q, %v", tt.name, source, ok, tt.source, tt.ok)
+		}
+	}
+}
+
+func TestFixCgoProfiles(t *testing.T) {
+	profiles := []*cover.Profile{
+		{FileName: "example.com/p/_obj/_cgo_gotypes.go", Mode: "set", Blocks: []cover.ProfileBlock{{StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 1, NumStmt: 1, Count: 1}}},
+		{FileName: "example.com/p/_obj/foo.cgo1.go", Mode: "set", Blocks: []cover.ProfileBlock{{StartLine: 3, StartCol: 1, EndLine: 4, EndCol: 1, NumStmt: 1, Count: 1}}},
+		{FileName: "example.com/p/bar.go", Mode: "set", Blocks: []cover.ProfileBlock{{StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 1, NumStmt: 1}}},
+	}
+	for _, tt := range []struct {
+		skip bool
+		want []string
+	}{
+		{false, []string{"example.com/p/_obj/_cgo_gotypes.go", "example.com/p/bar.go", "example.com/p/foo.go"}},
+		{true, []string{"example.com/p/bar.go", "example.com/p/foo.go"}},
+	} {
+		got := fixCgoProfiles(profiles, tt.skip)
+		if len(got) != len(tt.want) {
+			t.Fatalf("skip=%v: got %d profiles, want %d", tt.skip, len(got), len(tt.want))
+		}
+		for i, p := range got {
+			if p.FileName != tt.want[i] {
+				t.Errorf("skip=%v: got %s, want %s", tt.skip, p.FileName, tt.want[i])
+			}
+		}
+	}
+	if profiles[1].FileName != "example.com/p/_obj/foo.cgo1.go" {
+		t.Errorf("fixCgoProfiles modified the given profile: %s", profiles[1].FileName)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/cover"
+)
+
+// chartWidth is the number of cells of a bar of 100% coverage.
+const chartWidth = 40
+
+// chartBlocks is blocks of eighths of a cell to draw bars at a finer
+// resolution than cells.
+var chartBlocks = []string{"", "▏", "▎", "▍", "▌", "▋", "▊", "▉"}
+
+// writeChart writes a bar chart of statement coverage of each package in
+// profiles, in descending order of coverage, to be scanned in CI logs.
+func writeChart(w io.Writer, profiles []*cover.Profile) error {
+	stats := packageStats(profiles)
+	sort.SliceStable(stats, func(i, j int) bool { return stats[i].percent() > stats[j].percent() })
+	width := 0
+	for _, s := range stats {
+		if len(s.Name) > width {
+			width = len(s.Name)
+		}
+	}
+	for _, s := range stats {
+		if _, err := fmt.Fprintf(w, "%-*s  %5.1f%% %s\n", width, s.Name, s.percent(), bar(s.percent())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bar returns a bar of the percentage.
+func bar(percent float64) string {
+	eighths := int(percent*chartWidth*8/100 + 0.5)
+	return strings.Repeat("█", eighths/8) + chartBlocks[eighths%8]
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestWriteChart(t *testing.T) {
+	profiles := []*cover.Profile{
+		{FileName: "example.com/a/a.go", Blocks: []cover.ProfileBlock{{NumStmt: 1, Count: 1}, {NumStmt: 3, Count: 0}}},
+		{FileName: "example.com/a/b/b.go", Blocks: []cover.ProfileBlock{{NumStmt: 2, Count: 1}}},
+		{FileName: "example.com/c/c.go", Blocks: []cover.ProfileBlock{{NumStmt: 2, Count: 0}}},
+	}
+	var buf bytes.Buffer
+	if err := writeChart(&buf, profiles); err != nil {
+		t.Fatal(err)
+	}
+	want := "" +
+		"example.com/a/b  100.0% ████████████████████████████████████████\n" +
+		"example.com/a     25.0% ██████████\n" +
+		"example.com/c      0.0% \n"
+	if got := buf.String(); got != want {
+		t.Errorf("writeChart() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestBar(t *testing.T) {
+	tests := []struct {
+		percent float64
+		want    string
+	}{
+		{0, ""},
+		{1, "▍"},
+		{2.5, "█"},
+		{92.3, "████████████████████████████████████▉"},
+	}
+	for _, tt := range tests {
+		if got := bar(tt.percent); got != tt.want {
+			t.Errorf("bar(%v) = %q, want %q", tt.percent, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/cover"
+)
+
+// thresholdsConfig is minimum coverage (in percent) checked by "goverage
+// check".
+type thresholdsConfig struct {
+	// Total is minimum coverage of all statements in the profile. It's not
+	// checked if zero.
+	Total float64 `yaml:"total"`
+	// Package is minimum coverage of every directory of packages, so that a
+	// policy like "80% in total and no package below 50%" is checked at once.
+	// Thresholds of Directories and "//goverage:min" below it are raised to
+	// it. It's not checked if zero.
+	Package float64 `yaml:"package"`
+	// Directories are minimum coverage of directories. A directory is checked
+	// against the entry matching its nearest ancestor (or itself), so
+	// subdirectories inherit thresholds of their parents.
+	Directories []dirThreshold `yaml:"directories"`
+}
+
+// dirThreshold is minimum coverage of directories matching Path, a slash
+// separated glob relative to the config file directory (e.g. "internal/*").
+type dirThreshold struct {
+	Path string  `yaml:"path"`
+	Min  float64 `yaml:"min"`
+}
+
+// thresholdErrors is a list of threshold violations.
+type thresholdErrors []*ThresholdError
+
+func (errs thresholdErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (errs thresholdErrors) Is(target error) bool { return target == ErrThreshold }
+
+func (errs thresholdErrors) Unwrap() []error {
+	result := make([]error, len(errs))
+	for i, err := range errs {
+		result[i] = err
+	}
+	return result
+}
+
+// runCheck checks coverage of an existing profile against thresholds in the
+// config file.
+func runCheck(args []string) error {
+	fs := newFlagSet("check", "[-threshold N] [-package-min N] [-config .goverage.yml] coverage.out")
+	cfgFile := fs.String("config", "", "Config file (default \""+defaultConfigFile+"\" if exists)")
+	total := fs.Float64("threshold", 0, "Minimum total coverage in percent. It overrides thresholds.total of the config file")
+	pkgMin := fs.Float64("package-min", 0, "Minimum coverage in percent of every package, which raises lower thresholds of packages. It overrides thresholds.package of the config file, and 0 disables it")
+	fs.Parse(args)
+	set := setFlagNames(fs)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("goverage check: a profile is required")
+	}
+	cfg, err := loadConfig(configFromRoot(*cfgFile))
+	if err != nil {
+		return err
+	}
+	if *total > 0 {
+		cfg.Thresholds.Total = *total
+	}
+	if set["package-min"] {
+		cfg.Thresholds.Package = *pkgMin
+	}
+	profiles, err := readProfiles(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	resolve := newProfileResolver(profiles).resolve
+	return checkThresholds(os.Stdout, cfg, cfg.filterProfiles(profiles, resolve), resolve, terminalLinker(os.Stdout, os.Getenv))
+}
+
+// checkThresholds checks coverage of profiles against thresholds in cfg and
+// "//goverage:min" annotations in source files, and writes the result of each
+// checked directory and the total to w. Directories are linked to them by
+// link. It returns thresholdErrors of all violations if some coverage is below
+// its threshold.
+func checkThresholds(w io.Writer, cfg *config, profiles []*cover.Profile, resolve func(string) (string, error), link linkFunc) error {
+	var errs thresholdErrors
+	dirs := dirStats(cfg.dir, profiles, resolve)
+	for _, s := range dirs {
+		min, ok, err := minAnnotation(filepath.Join(cfg.dir, filepath.FromSlash(s.Name)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			var t dirThreshold
+			t, ok = nearestDirThreshold(cfg.Thresholds.Directories, s.Name)
+			min = t.Min
+		}
+		if floor := cfg.Thresholds.Package; floor > 0 && (!ok || min < floor) {
+			min, ok = floor, true
+		}
+		if !ok {
+			continue
+		}
+		result := "ok"
+		if s.percent() < min {
+			result = "FAIL"
+			errs = append(errs, &ThresholdError{Target: s.Name, Coverage: s.percent(), Threshold: min})
+		}
+		// The directory is the last so that invisible escape sequences of the
+		// link don't break alignment.
+		fmt.Fprintf(w, "%-4s  %5.1f%%  (min %5.1f%%)  %s\n", result, s.percent(), min, link(s.Name, filepath.Join(cfg.dir, filepath.FromSlash(s.Name))))
+	}
+	if min := cfg.Thresholds.Total; min > 0 {
+		s := statementStats(profiles)
+		result := "ok"
+		if s.percent() < min {
+			result = "FAIL"
+			errs = append(errs, &ThresholdError{Target: "total", Coverage: s.percent(), Threshold: min})
+		}
+		fmt.Fprintf(w, "%-4s  %5.1f%%  (min %5.1f%%)  total\n", result, s.percent(), min)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// dirStats returns statement coverage of each directory relative to root,
+// sorted by the directory. Files outside of root are ignored.
+func dirStats(root string, profiles []*cover.Profile, resolve func(string) (string, error)) []*coverStats {
+	stats := make(map[string]*coverStats)
+	for _, p := range profiles {
+		filename, err := resolve(p.FileName)
+		if err != nil {
+			log.Printf("skip %s: %v", p.FileName, err)
+			continue
+		}
+		rel, ok := relPath(root, filename)
+		if !ok {
+			continue
+		}
+		dir := path.Dir(rel)
+		s, ok := stats[dir]
+		if !ok {
+			s = &coverStats{Name: dir}
+			stats[dir] = s
+		}
+		for _, b := range p.Blocks {
+			s.Total += b.NumStmt
+			if b.Count > 0 {
+				s.Covered += b.NumStmt
+			}
+		}
+	}
+	result := make([]*coverStats, 0, len(stats))
+	for _, s := range stats {
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// nearestDirThreshold returns the threshold for the slash separated directory
+// relative to the config file directory. It's the entry matching the nearest
+// ancestor of dir, including dir itself. If several entries match the same
+// directory, the last one wins.
+func nearestDirThreshold(thresholds []dirThreshold, dir string) (dirThreshold, bool) {
+	for d := dir; ; d = path.Dir(d) {
+		for i := len(thresholds) - 1; i >= 0; i-- {
+			pattern := path.Clean(strings.TrimPrefix(thresholds[i].Path, "./"))
+			if ok, _ := path.Match(pattern, d); ok {
+				return thresholds[i], true
+			}
+		}
+		if d == "." || d == "/" {
+			return dirThreshold{}, false
+		}
+	}
+}
+
+// minAnnotationPrefix is the prefix of a comment declaring minimum coverage
+// of the package in the file, such as "//goverage:min 85". It takes
+// precedence over thresholds in the config file.
+const minAnnotationPrefix = "//goverage:min"
+
+// minAnnotation returns minimum coverage declared by a "//goverage:min"
+// comment in non-test Go files in dir. It's an error if files declare
+// different values.
+func minAnnotation(dir string) (min float64, ok bool, err error) {
+	// Do not use filepath.Glob, which treats brackets in dir as a pattern.
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	for _, fi := range files {
+		name := fi.Name()
+		if fi.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		filename := filepath.Join(dir, name)
+		v, found, err := fileMinAnnotation(filename)
+		if err != nil {
+			return 0, false, err
+		}
+		if !found {
+			continue
+		}
+		if ok && v != min {
+			return 0, false, fmt.Errorf("%s: conflicting %s %v and %v in package", filename, minAnnotationPrefix, min, v)
+		}
+		min, ok = v, true
+	}
+	return min, ok, nil
+}
+
+func fileMinAnnotation(filename string) (min float64, ok bool, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0, false, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for l := 1; s.Scan(); l++ {
+		line := strings.TrimSpace(s.Text())
+		if !strings.HasPrefix(line, minAnnotationPrefix) {
+			continue
+		}
+		arg := strings.TrimPrefix(line, minAnnotationPrefix)
+		if arg != "" && arg[0] != ' ' && arg[0] != '\t' {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(arg), "%"), 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("%s:%d: invalid %s: %v", filename, l, minAnnotationPrefix, err)
+		}
+		if ok && v != min {
+			return 0, false, fmt.Errorf("%s:%d: conflicting %s %v and %v", filename, l, minAnnotationPrefix, min, v)
+		}
+		min, ok = v, true
+	}
+	return min, ok, s.Err()
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestNearestDirThreshold(t *testing.T) {
+	thresholds := []dirThreshold{
+		{Path: ".", Min: 60},
+		{Path: "internal/auth", Min: 90},
+		{Path: "legacy/*", Min: 20},
+		{Path: "./legacy/keep", Min: 50},
+	}
+	tests := []struct {
+		dir  string
+		want float64
+	}{
+		{".", 60},
+		{"cmd/app", 60},
+		{"internal/auth", 90},
+		{"internal/auth/token", 90},
+		{"legacy", 60},
+		{"legacy/old", 20},
+		{"legacy/old/deeper", 20},
+		{"legacy/keep", 50},
+	}
+	for _, tt := range tests {
+		got, ok := nearestDirThreshold(thresholds, tt.dir)
+		if !ok || got.Min != tt.want {
+			t.Errorf("nearestDirThreshold(%q) = %v, %v; want %v", tt.dir, got.Min, ok, tt.want)
+		}
+	}
+	if _, ok := nearestDirThreshold([]dirThreshold{{Path: "internal", Min: 90}}, "cmd"); ok {
+		t.Error("got threshold for directory without matching entries")
+	}
+}
+
+func TestCheckThresholds(t *testing.T) {
+	block := func(numStmt, count int) cover.ProfileBlock {
+		return cover.ProfileBlock{StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 2, NumStmt: numStmt, Count: count}
+	}
+	profiles := []*cover.Profile{
+		{FileName: "example.com/app/main.go", Blocks: []cover.ProfileBlock{block(1, 1)}},
+		{FileName: "example.com/app/internal/auth/token.go", Blocks: []cover.ProfileBlock{block(4, 1), block(1, 0)}},
+		{FileName: "example.com/app/legacy/old/old.go", Blocks: []cover.ProfileBlock{block(1, 1), block(9, 0)}},
+	}
+	cfg := &config{dir: "/repo", Thresholds: thresholdsConfig{Directories: []dirThreshold{
+		{Path: ".", Min: 60},
+		{Path: "internal/auth", Min: 90},
+		{Path: "legacy/*", Min: 5},
+	}}}
+	resolve := func(name string) (string, error) { return "/repo/" + strings.TrimPrefix(name, "example.com/app/"), nil }
+	var buf bytes.Buffer
+	err := checkThresholds(&buf, cfg, profiles, resolve, noLink)
+	const want = `ok    100.0%  (min  60.0%)  .
+FAIL   80.0%  (min  90.0%)  internal/auth
+ok     10.0%  (min   5.0%)  legacy/old
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if !errors.Is(err, ErrThreshold) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var terr *ThresholdError
+	if !errors.As(err, &terr) || terr.Target != "internal/auth" || terr.Coverage != 80 || terr.Threshold != 90 {
+		t.Errorf("unexpected threshold error: %v", err)
+	}
+	if errs := err.(thresholdErrors); len(errs) != 1 {
+		t.Errorf("unexpected violations: %v", err)
+	}
+	cfg.Thresholds.Directories[1].Min = 80
+	if err := checkThresholds(ioutil.Discard, cfg, profiles, resolve, noLink); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestMinAnnotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok, err := minAnnotation(dir); ok || err != nil {
+		t.Fatalf("minAnnotation() on empty dir = %v, %v", ok, err)
+	}
+	write("doc.go", "// Package auth authenticates users.\n//goverage:min 85\npackage auth\n")
+	write("token.go", "package auth\n\n//goverage:minimum is not an annotation\n")
+	write("token_test.go", "package auth\n\n//goverage:min 10\n")
+	if min, ok, err := minAnnotation(dir); !ok || err != nil || min != 85 {
+		t.Errorf("minAnnotation() = %v, %v, %v; want 85", min, ok, err)
+	}
+	write("token.go", "package auth\n\n//goverage:min 90\n")
+	if _, _, err := minAnnotation(dir); err == nil {
+		t.Error("want error for conflicting annotations")
+	}
+	write("token.go", "package auth\n\n//goverage:min high\n")
+	if _, _, err := minAnnotation(dir); err == nil {
+		t.Error("want error for invalid annotation")
+	}
+	write("token.go", "package auth\n")
+
+	profiles := []*cover.Profile{{FileName: "example.com/auth/token.go", Blocks: []cover.ProfileBlock{
+		{StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 2, NumStmt: 4, Count: 1},
+		{StartLine: 3, StartCol: 1, EndLine: 4, EndCol: 2, NumStmt: 1, Count: 0},
+	}}}
+	cfg := &config{dir: dir, Thresholds: thresholdsConfig{Directories: []dirThreshold{{Path: ".", Min: 50}}}}
+	resolve := func(name string) (string, error) { return filepath.Join(dir, filepath.Base(name)), nil }
+	var terr *ThresholdError
+	if err := checkThresholds(ioutil.Discard, cfg, profiles, resolve, noLink); !errors.As(err, &terr) || terr.Threshold != 85 {
+		t.Errorf("annotation should take precedence over config: %v", err)
+	}
+}
+
+func TestMinAnnotation_unusualDir(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "goverage-check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	dir := filepath.Join(tmp, "[legacy] ünïcode dir")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "doc.go"), []byte("//goverage:min 70\npackage legacy\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if min, ok, err := minAnnotation(dir); !ok || err != nil || min != 70 {
+		t.Errorf("minAnnotation() = %v, %v, %v; want 70", min, ok, err)
+	}
+}
+
+func TestCheckThresholds_total(t *testing.T) {
+	profiles := []*cover.Profile{
+		{FileName: "example.com/app/main.go", Blocks: []cover.ProfileBlock{
+			{StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 2, NumStmt: 3, Count: 1},
+			{StartLine: 3, StartCol: 1, EndLine: 4, EndCol: 2, NumStmt: 1, Count: 0},
+		}},
+	}
+	cfg := &config{dir: "/repo", Thresholds: thresholdsConfig{Total: 80}}
+	resolve := func(name string) (string, error) { return "/repo/main.go", nil }
+	var buf bytes.Buffer
+	err := checkThresholds(&buf, cfg, profiles, resolve, noLink)
+	if got, want := buf.String(), "FAIL   75.0%  (min  80.0%)  total\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	var terr *ThresholdError
+	if !errors.As(err, &terr) || terr.Target != "total" || terr.Coverage != 75 {
+		t.Errorf("unexpected error: %v", err)
+	}
+	cfg.Thresholds.Total = 75
+	if err := checkThresholds(ioutil.Discard, cfg, profiles, resolve, noLink); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCheckThresholds_packageFloor(t *testing.T) {
+	block := func(numStmt, count int) cover.ProfileBlock {
+		return cover.ProfileBlock{StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 2, NumStmt: numStmt, Count: count}
+	}
+	profiles := []*cover.Profile{
+		{FileName: "example.com/app/main.go", Blocks: []cover.ProfileBlock{block(9, 1)}},
+		{FileName: "example.com/app/store/store.go", Blocks: []cover.ProfileBlock{block(4, 1), block(6, 0)}},
+		{FileName: "example.com/app/legacy/old.go", Blocks: []cover.ProfileBlock{block(1, 1), block(9, 0)}},
+	}
+	cfg := &config{dir: "/repo", Thresholds: thresholdsConfig{
+		Total:       80,
+		Package:     50,
+		Directories: []dirThreshold{{Path: "legacy", Min: 10}},
+	}}
+	resolve := func(name string) (string, error) { return "/repo/" + strings.TrimPrefix(name, "example.com/app/"), nil }
+	var buf bytes.Buffer
+	err := checkThresholds(&buf, cfg, profiles, resolve, noLink)
+	const want = `ok    100.0%  (min  50.0%)  .
+FAIL   10.0%  (min  50.0%)  legacy
+FAIL   40.0%  (min  50.0%)  store
+FAIL   48.3%  (min  80.0%)  total
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	errs, ok := err.(thresholdErrors)
+	if !ok || len(errs) != 3 || errs[0].Target != "legacy" || errs[1].Target != "store" || errs[2].Target != "total" {
+		t.Errorf("unexpected violations: %v", err)
+	}
+	// A threshold above the floor is kept.
+	cfg.Thresholds.Directories[0].Min = 5
+	cfg.Thresholds.Directories = append(cfg.Thresholds.Directories, dirThreshold{Path: ".", Min: 100})
+	buf.Reset()
+	checkThresholds(&buf, cfg, profiles, resolve, noLink)
+	if got := strings.SplitN(buf.String(), "\n", 2)[0]; got != "ok    100.0%  (min 100.0%)  ." {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+
+	"golang.org/x/tools/cover"
+)
+
+// checkstyleResult is a checkstyle XML document.
+type checkstyleResult struct {
+	XMLName xml.Name          `xml:"checkstyle"`
+	Version string            `xml:"version,attr"`
+	Files   []*checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string             `xml:"name,attr"`
+	Errors []*checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Column   int    `xml:"column,attr"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+// Checkstyle sources of errors written by writeCheckstyle.
+const (
+	checkstyleSourceFunc   = "goverage.uncovered-function"
+	checkstyleSourceRegion = "goverage.uncovered"
+)
+
+// writeCheckstyle writes uncovered functions and regions of profiles as
+// checkstyle warnings. A function without any covered statements is reported
+// once instead of each region in it.
+func writeCheckstyle(w io.Writer, profiles []*cover.Profile, resolve func(string) (string, error)) error {
+	result := checkstyleResult{Version: "4.3"}
+	for _, p := range profiles {
+		filename, err := resolve(p.FileName)
+		if err != nil {
+			log.Printf("skip %s: %v", p.FileName, err)
+			continue
+		}
+		funcs, err := funcExtents(filename, p)
+		if err != nil {
+			log.Printf("skip %s: %v", p.FileName, err)
+			continue
+		}
+		f := &checkstyleFile{Name: filename}
+		var uncoveredFuncs []*funcExtent
+		for _, fn := range funcs {
+			if fn.Total > 0 && fn.Covered == 0 {
+				uncoveredFuncs = append(uncoveredFuncs, fn)
+				f.Errors = append(f.Errors, &checkstyleError{
+					Line:     fn.StartLine,
+					Column:   fn.StartCol,
+					Severity: "warning",
+					Message:  fmt.Sprintf("function %s is not covered by tests", fn.Name),
+					Source:   checkstyleSourceFunc,
+				})
+			}
+		}
+	regions:
+		for _, r := range uncoveredRegions(p) {
+			for _, fn := range uncoveredFuncs {
+				if fn.contains(r) {
+					continue regions
+				}
+			}
+			f.Errors = append(f.Errors, &checkstyleError{
+				Line:     r.StartLine,
+				Column:   r.StartCol,
+				Severity: "warning",
+				Message:  fmt.Sprintf("%d statement(s) not covered by tests (lines %d-%d)", r.NumStmt, r.StartLine, r.EndLine),
+				Source:   checkstyleSourceRegion,
+			})
+		}
+		sort.SliceStable(f.Errors, func(i, j int) bool { return f.Errors[i].Line < f.Errors[j].Line })
+		if len(f.Errors) > 0 {
+			result.Files = append(result.Files, f)
+		}
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(result); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestWriteCheckstyle(t *testing.T) {
+	filename, cleanup := writeFuncsTestSource(t)
+	defer cleanup()
+	profiles := []*cover.Profile{{FileName: "example.com/a/a.go", Blocks: []cover.ProfileBlock{
+		{StartLine: 3, StartCol: 14, EndLine: 5, EndCol: 2, NumStmt: 1, Count: 0},
+		{StartLine: 9, StartCol: 17, EndLine: 10, EndCol: 9, NumStmt: 1, Count: 1},
+		{StartLine: 10, StartCol: 9, EndLine: 12, EndCol: 3, NumStmt: 1, Count: 0},
+	}}}
+	resolve := func(string) (string, error) { return filename, nil }
+	var buf bytes.Buffer
+	if err := writeCheckstyle(&buf, profiles, resolve); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<checkstyle version="4.3">
+  <file name="` + filename + `">
+    <error line="3" column="1" severity="warning" message="function F is not covered by tests" source="goverage.uncovered-function"></error>
+    <error line="10" column="9" severity="warning" message="1 statement(s) not covered by tests (lines 10-12)" source="goverage.uncovered"></error>
+  </file>
+</checkstyle>
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"log"
+	"path"
+	"sort"
+	"time"
+
+	"golang.org/x/tools/cover"
+)
+
+type cloverCoverage struct {
+	XMLName   xml.Name      `xml:"coverage"`
+	Generated int64         `xml:"generated,attr"`
+	Clover    string        `xml:"clover,attr"`
+	Project   cloverProject `xml:"project"`
+}
+
+type cloverProject struct {
+	Timestamp int64            `xml:"timestamp,attr"`
+	Name      string           `xml:"name,attr,omitempty"`
+	Metrics   cloverMetrics    `xml:"metrics"`
+	Packages  []*cloverPackage `xml:"package"`
+}
+
+// cloverMetrics is metrics of a project, a package or a file. Packages and
+// Files are only written for projects.
+type cloverMetrics struct {
+	Statements          int `xml:"statements,attr"`
+	CoveredStatements   int `xml:"coveredstatements,attr"`
+	Conditionals        int `xml:"conditionals,attr"`
+	CoveredConditionals int `xml:"coveredconditionals,attr"`
+	Methods             int `xml:"methods,attr"`
+	CoveredMethods      int `xml:"coveredmethods,attr"`
+	Elements            int `xml:"elements,attr"`
+	CoveredElements     int `xml:"coveredelements,attr"`
+	Packages            int `xml:"packages,attr,omitempty"`
+	Files               int `xml:"files,attr,omitempty"`
+}
+
+type cloverPackage struct {
+	Name    string        `xml:"name,attr"`
+	Metrics cloverMetrics `xml:"metrics"`
+	Files   []*cloverFile `xml:"file"`
+}
+
+type cloverFile struct {
+	Name    string        `xml:"name,attr"`
+	Path    string        `xml:"path,attr"`
+	Metrics cloverMetrics `xml:"metrics"`
+	Lines   []*cloverLine `xml:"line"`
+}
+
+// cloverLine is a line of a statement ("stmt") or a function ("method").
+type cloverLine struct {
+	Num       int    `xml:"num,attr"`
+	Count     int    `xml:"count,attr"`
+	Type      string `xml:"type,attr"`
+	Signature string `xml:"signature,attr,omitempty"`
+}
+
+// add adds m to the metrics.
+func (s *cloverMetrics) add(m cloverMetrics) {
+	s.Statements += m.Statements
+	s.CoveredStatements += m.CoveredStatements
+	s.Methods += m.Methods
+	s.CoveredMethods += m.CoveredMethods
+	s.Elements += m.Elements
+	s.CoveredElements += m.CoveredElements
+}
+
+// writeClover writes coverage of profiles as a Clover XML report. Statements
+// of metrics are those of the profile, and lines have the execution counts of
+// lineHits. A function is covered if its first line with statements is.
+// Functions are omitted for files which cannot be parsed.
+func writeClover(w io.Writer, profiles []*cover.Profile, resolve func(string) (string, error), timestamp time.Time) error {
+	ms := timestamp.UnixNano() / int64(time.Millisecond)
+	report := cloverCoverage{Generated: ms, Clover: "goverage", Project: cloverProject{Timestamp: ms}}
+	pkgs := make(map[string]*cloverPackage)
+	for _, p := range profiles {
+		file := &cloverFile{Name: path.Base(p.FileName), Path: p.FileName}
+		stmts := statementStats([]*cover.Profile{p})
+		file.Metrics.Statements = stmts.Total
+		file.Metrics.CoveredStatements = stmts.Covered
+		lines := coberturaLines(lineHits(p))
+		filename, err := resolve(p.FileName)
+		if err != nil {
+			log.Printf("cannot resolve %s: %v", p.FileName, err)
+		} else {
+			file.Path = filename
+			if funcs, err := funcExtents(filename, p); err == nil {
+				for _, fn := range funcs {
+					count := 0
+					for _, l := range lines {
+						if fn.StartLine <= l.Number && l.Number <= fn.EndLine {
+							count = l.Hits
+							break
+						}
+					}
+					file.Lines = append(file.Lines, &cloverLine{Num: fn.StartLine, Count: count, Type: "method", Signature: fn.Name})
+					file.Metrics.Methods++
+					if count > 0 {
+						file.Metrics.CoveredMethods++
+					}
+				}
+			}
+		}
+		for _, l := range lines {
+			file.Lines = append(file.Lines, &cloverLine{Num: l.Number, Count: l.Hits, Type: "stmt"})
+		}
+		sort.SliceStable(file.Lines, func(i, j int) bool { return file.Lines[i].Num < file.Lines[j].Num })
+		file.Metrics.Elements = file.Metrics.Statements + file.Metrics.Methods
+		file.Metrics.CoveredElements = file.Metrics.CoveredStatements + file.Metrics.CoveredMethods
+		name := path.Dir(p.FileName)
+		pkg, ok := pkgs[name]
+		if !ok {
+			pkg = &cloverPackage{Name: name}
+			pkgs[name] = pkg
+		}
+		pkg.Files = append(pkg.Files, file)
+		pkg.Metrics.add(file.Metrics)
+		report.Project.Metrics.add(file.Metrics)
+		report.Project.Metrics.Files++
+	}
+	for _, pkg := range pkgs {
+		report.Project.Packages = append(report.Project.Packages, pkg)
+	}
+	sort.Slice(report.Project.Packages, func(i, j int) bool { return report.Project.Packages[i].Name < report.Project.Packages[j].Name })
+	report.Project.Metrics.Packages = len(pkgs)
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
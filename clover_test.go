@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestWriteClover(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-clover")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "a.go")
+	src := "package a\n\nfunc A() {\n\tprintln()\n}\n\nfunc B() {\n\tprintln()\n}\n"
+	if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profiles := []*cover.Profile{
+		{FileName: "example.com/a/a.go", Mode: "set", Blocks: []cover.ProfileBlock{
+			{StartLine: 3, StartCol: 10, EndLine: 5, EndCol: 2, NumStmt: 1, Count: 1},
+			{StartLine: 7, StartCol: 10, EndLine: 9, EndCol: 2, NumStmt: 2, Count: 0},
+		}},
+		{FileName: "example.com/b/b.go", Mode: "set", Blocks: []cover.ProfileBlock{
+			{StartLine: 1, StartCol: 1, EndLine: 1, EndCol: 5, NumStmt: 1, Count: 1},
+		}},
+	}
+	resolve := func(name string) (string, error) {
+		if name == "example.com/a/a.go" {
+			return filename, nil
+		}
+		return "", errors.New("not found")
+	}
+	var buf bytes.Buffer
+	if err := writeClover(&buf, profiles, resolve, time.Unix(1, 0)); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<coverage generated="1000" clover="goverage">
+  <project timestamp="1000">
+    <metrics statements="4" coveredstatements="2" conditionals="0" coveredconditionals="0" methods="2" coveredmethods="1" elements="6" coveredelements="3" packages="2" files="2"></metrics>
+    <package name="example.com/a">
+      <metrics statements="3" coveredstatements="1" conditionals="0" coveredconditionals="0" methods="2" coveredmethods="1" elements="5" coveredelements="2"></metrics>
+      <file name="a.go" path="` + filename + `">
+        <metrics statements="3" coveredstatements="1" conditionals="0" coveredconditionals="0" methods="2" coveredmethods="1" elements="5" coveredelements="2"></metrics>
+        <line num="3" count="1" type="method" signature="A"></line>
+        <line num="3" count="1" type="stmt"></line>
+        <line num="4" count="1" type="stmt"></line>
+        <line num="5" count="1" type="stmt"></line>
+        <line num="7" count="0" type="method" signature="B"></line>
+        <line num="7" count="0" type="stmt"></line>
+        <line num="8" count="0" type="stmt"></line>
+        <line num="9" count="0" type="stmt"></line>
+      </file>
+    </package>
+    <package name="example.com/b">
+      <metrics statements="1" coveredstatements="1" conditionals="0" coveredconditionals="0" methods="0" coveredmethods="0" elements="1" coveredelements="1"></metrics>
+      <file name="b.go" path="example.com/b/b.go">
+        <metrics statements="1" coveredstatements="1" conditionals="0" coveredconditionals="0" methods="0" coveredmethods="0" elements="1" coveredelements="1"></metrics>
+        <line num="1" count="1" type="stmt"></line>
+      </file>
+    </package>
+  </project>
+</coverage>
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"sort"
+	"time"
+
+	"golang.org/x/tools/cover"
+)
+
+// coberturaDoctype is the DOCTYPE of Cobertura XML reports.
+const coberturaDoctype = `<!DOCTYPE coverage SYSTEM "http://cobertura.sourceforge.net/xml/coverage-04.dtd">`
+
+type coberturaCoverage struct {
+	XMLName         xml.Name            `xml:"coverage"`
+	LineRate        float64             `xml:"line-rate,attr"`
+	BranchRate      float64             `xml:"branch-rate,attr"`
+	LinesCovered    int                 `xml:"lines-covered,attr"`
+	LinesValid      int                 `xml:"lines-valid,attr"`
+	BranchesCovered int                 `xml:"branches-covered,attr"`
+	BranchesValid   int                 `xml:"branches-valid,attr"`
+	Complexity      float64             `xml:"complexity,attr"`
+	Version         string              `xml:"version,attr"`
+	Timestamp       int64               `xml:"timestamp,attr"`
+	Sources         []string            `xml:"sources>source"`
+	Packages        []*coberturaPackage `xml:"packages>package"`
+}
+
+type coberturaPackage struct {
+	Name       string            `xml:"name,attr"`
+	LineRate   float64           `xml:"line-rate,attr"`
+	BranchRate float64           `xml:"branch-rate,attr"`
+	Complexity float64           `xml:"complexity,attr"`
+	Classes    []*coberturaClass `xml:"classes>class"`
+
+	lines coverStats
+}
+
+type coberturaClass struct {
+	Name       string             `xml:"name,attr"`
+	Filename   string             `xml:"filename,attr"`
+	LineRate   float64            `xml:"line-rate,attr"`
+	BranchRate float64            `xml:"branch-rate,attr"`
+	Complexity float64            `xml:"complexity,attr"`
+	Methods    []*coberturaMethod `xml:"methods>method"`
+	Lines      []*coberturaLine   `xml:"lines>line"`
+}
+
+type coberturaMethod struct {
+	Name       string           `xml:"name,attr"`
+	Signature  string           `xml:"signature,attr"`
+	LineRate   float64          `xml:"line-rate,attr"`
+	BranchRate float64          `xml:"branch-rate,attr"`
+	Complexity float64          `xml:"complexity,attr"`
+	Lines      []*coberturaLine `xml:"lines>line"`
+}
+
+type coberturaLine struct {
+	Number int `xml:"number,attr"`
+	Hits   int `xml:"hits,attr"`
+}
+
+// writeCobertura writes line coverage of profiles as a Cobertura XML report.
+// File names are relative to source, the root of the sources. Methods are
+// omitted for files which cannot be parsed.
+func writeCobertura(w io.Writer, profiles []*cover.Profile, resolve func(string) (string, error), source string, timestamp time.Time) error {
+	report := coberturaCoverage{
+		Version:   "goverage",
+		Timestamp: timestamp.UnixNano() / int64(time.Millisecond),
+		Sources:   []string{source},
+	}
+	pkgs := make(map[string]*coberturaPackage)
+	var total coverStats
+	for _, p := range profiles {
+		class := &coberturaClass{Name: path.Base(p.FileName), Filename: p.FileName}
+		filename, err := resolve(p.FileName)
+		if err != nil {
+			log.Printf("cannot resolve %s: %v", p.FileName, err)
+		} else if rel, ok := relPath(source, filename); ok {
+			class.Filename = rel
+		}
+		class.Lines = coberturaLines(lineHits(p))
+		lines := coberturaLineStats(class.Lines)
+		class.LineRate = lines.percent() / 100
+		if err == nil {
+			if funcs, err := funcExtents(filename, p); err == nil {
+				for _, fn := range funcs {
+					m := &coberturaMethod{Name: fn.Name, Lines: []*coberturaLine{}}
+					for _, l := range class.Lines {
+						if fn.StartLine <= l.Number && l.Number <= fn.EndLine {
+							m.Lines = append(m.Lines, l)
+						}
+					}
+					s := coberturaLineStats(m.Lines)
+					m.LineRate = s.percent() / 100
+					class.Methods = append(class.Methods, m)
+				}
+			}
+		}
+		name := path.Dir(p.FileName)
+		pkg, ok := pkgs[name]
+		if !ok {
+			pkg = &coberturaPackage{Name: name}
+			pkgs[name] = pkg
+		}
+		pkg.Classes = append(pkg.Classes, class)
+		pkg.lines.Covered += lines.Covered
+		pkg.lines.Total += lines.Total
+		total.Covered += lines.Covered
+		total.Total += lines.Total
+	}
+	for _, pkg := range pkgs {
+		pkg.LineRate = pkg.lines.percent() / 100
+		report.Packages = append(report.Packages, pkg)
+	}
+	sort.Slice(report.Packages, func(i, j int) bool { return report.Packages[i].Name < report.Packages[j].Name })
+	report.LinesCovered = total.Covered
+	report.LinesValid = total.Total
+	report.LineRate = total.percent() / 100
+	if _, err := fmt.Fprintf(w, "%s%s\n", xml.Header, coberturaDoctype); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// coberturaLines returns lines in hits in ascending order.
+func coberturaLines(hits map[int]int) []*coberturaLine {
+	lines := make([]*coberturaLine, 0, len(hits))
+	for n, h := range hits {
+		lines = append(lines, &coberturaLine{Number: n, Hits: h})
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].Number < lines[j].Number })
+	return lines
+}
+
+func coberturaLineStats(lines []*coberturaLine) coverStats {
+	s := coverStats{Total: len(lines)}
+	for _, l := range lines {
+		if l.Hits > 0 {
+			s.Covered++
+		}
+	}
+	return s
+}
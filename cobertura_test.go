@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestWriteCobertura(t *testing.T) {
+	filename, cleanup := writeFuncsTestSource(t)
+	defer cleanup()
+	profiles := []*cover.Profile{{FileName: "example.com/a/a.go", Blocks: []cover.ProfileBlock{
+		{StartLine: 3, StartCol: 14, EndLine: 5, EndCol: 2, NumStmt: 1, Count: 2},
+		{StartLine: 9, StartCol: 17, EndLine: 10, EndCol: 9, NumStmt: 1, Count: 1},
+		{StartLine: 10, StartCol: 9, EndLine: 12, EndCol: 3, NumStmt: 1, Count: 0},
+	}}}
+	resolve := func(string) (string, error) { return filename, nil }
+	source := filepath.Dir(filepath.Dir(filename))
+	var buf bytes.Buffer
+	if err := writeCobertura(&buf, profiles, resolve, source, time.Unix(1, 0)); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE coverage SYSTEM "http://cobertura.sourceforge.net/xml/coverage-04.dtd">
+<coverage line-rate="0.5714285714285715" branch-rate="0" lines-covered="4" lines-valid="7" branches-covered="0" branches-valid="0" complexity="0" version="goverage" timestamp="1000">
+  <sources>
+    <source>` + source + `</source>
+  </sources>
+  <packages>
+    <package name="example.com/a" line-rate="0.5714285714285715" branch-rate="0" complexity="0">
+      <classes>
+        <class name="a.go" filename="` + filepath.Base(filepath.Dir(filename)) + `/a.go" line-rate="0.5714285714285715" branch-rate="0" complexity="0">
+          <methods>
+            <method name="F" signature="" line-rate="1" branch-rate="0" complexity="0">
+              <lines>
+                <line number="3" hits="2"></line>
+                <line number="4" hits="2"></line>
+                <line number="5" hits="2"></line>
+              </lines>
+            </method>
+            <method name="(*T).M" signature="" line-rate="0.25" branch-rate="0" complexity="0">
+              <lines>
+                <line number="9" hits="1"></line>
+                <line number="10" hits="0"></line>
+                <line number="11" hits="0"></line>
+                <line number="12" hits="0"></line>
+              </lines>
+            </method>
+            <method name="T.N" signature="" line-rate="0" branch-rate="0" complexity="0">
+              <lines></lines>
+            </method>
+          </methods>
+          <lines>
+            <line number="3" hits="2"></line>
+            <line number="4" hits="2"></line>
+            <line number="5" hits="2"></line>
+            <line number="9" hits="1"></line>
+            <line number="10" hits="0"></line>
+            <line number="11" hits="0"></line>
+            <line number="12" hits="0"></line>
+          </lines>
+        </class>
+      </classes>
+    </package>
+  </packages>
+</coverage>
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
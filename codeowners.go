@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// codeownersLocations are the paths of CODEOWNERS relative to the repository
+// root, in the order of precedence.
+var codeownersLocations = []string{
+	".github/CODEOWNERS",
+	"CODEOWNERS",
+	"docs/CODEOWNERS",
+}
+
+// codeownersRule is a line of CODEOWNERS.
+type codeownersRule struct {
+	pattern *regexp.Regexp
+	owners  []string
+}
+
+// codeowners maps files to their owners by CODEOWNERS rules.
+type codeowners struct {
+	// root is the directory which patterns are relative to.
+	root  string
+	rules []codeownersRule
+}
+
+// findCodeowners returns the path of CODEOWNERS in the repository root.
+func findCodeowners(root string) (string, bool) {
+	for _, loc := range codeownersLocations {
+		filename := filepath.Join(root, filepath.FromSlash(loc))
+		if isExist(filename) {
+			return filename, true
+		}
+	}
+	return "", false
+}
+
+// loadCodeowners loads the CODEOWNERS file whose patterns are relative to
+// root.
+func loadCodeowners(filename, root string) (*codeowners, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseCodeowners(f, root)
+}
+
+func parseCodeowners(r io.Reader, root string) (*codeowners, error) {
+	c := &codeowners{root: root}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		re, err := regexp.Compile(codeownersPatternRegexp(fields[0]))
+		if err != nil {
+			return nil, err
+		}
+		c.rules = append(c.rules, codeownersRule{pattern: re, owners: fields[1:]})
+	}
+	return c, s.Err()
+}
+
+// codeownersPatternRegexp converts a CODEOWNERS pattern, which follows the
+// gitignore rules, to a regexp matching slash separated paths relative to the
+// repository root.
+func codeownersPatternRegexp(pattern string) string {
+	anchored := strings.HasPrefix(pattern, "/") || strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.Trim(pattern, "/")
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '*' && i+1 < len(pattern) && pattern[i+1] == '*':
+			i++
+			if i+1 < len(pattern) && pattern[i+1] == '/' {
+				// "**/" matches zero or more directories.
+				i++
+				b.WriteString("(.*/)?")
+			} else {
+				b.WriteString(".*")
+			}
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	switch {
+	case dirOnly:
+		b.WriteString("/.*$")
+	case strings.HasSuffix(pattern, "*") && !strings.HasSuffix(pattern, "**"):
+		// "docs/*" matches files directly in docs but not in its
+		// subdirectories.
+		b.WriteString("$")
+	default:
+		// A pattern also matches everything in the directory it matches.
+		b.WriteString("(/.*)?$")
+	}
+	return b.String()
+}
+
+// owners returns owners of the file. The last matching rule wins as GitHub
+// does. It returns nil for files without owners.
+func (c *codeowners) owners(filename string) []string {
+	rel, ok := relPath(c.root, filename)
+	if !ok {
+		return nil
+	}
+	for i := len(c.rules) - 1; i >= 0; i-- {
+		if c.rules[i].pattern.MatchString(rel) {
+			return c.rules[i].owners
+		}
+	}
+	return nil
+}
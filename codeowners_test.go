@@ -0,0 +1,50 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCodeowners(t *testing.T) {
+	const in = `# Default owners.
+*       @org/everyone
+
+*.md    @org/docs
+/store/ @org/storage
+cmd/**/main.go @org/cli
+internal/auth @org/security
+docs/*  @org/docs @alice
+/legacy/unowned.go
+`
+	c, err := parseCodeowners(strings.NewReader(in), "/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		file string
+		want []string
+	}{
+		{"/repo/main.go", []string{"@org/everyone"}},
+		{"/repo/README.md", []string{"@org/docs"}},
+		{"/repo/sub/README.md", []string{"@org/docs"}},
+		{"/repo/store/user.go", []string{"@org/storage"}},
+		{"/repo/store/cache/lru.go", []string{"@org/storage"}},
+		{"/repo/x/store/user.go", []string{"@org/everyone"}},
+		{"/repo/cmd/main.go", []string{"@org/cli"}},
+		{"/repo/cmd/app/v2/main.go", []string{"@org/cli"}},
+		{"/repo/internal/auth/token.go", []string{"@org/security"}},
+		{"/repo/docs/guide.go", []string{"@org/docs", "@alice"}},
+		{"/repo/docs/sub/guide.go", []string{"@org/everyone"}},
+		{"/repo/legacy/unowned.go", []string{}},
+	}
+	for _, tt := range tests {
+		got := c.owners(tt.file)
+		if got == nil {
+			got = []string{}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("owners(%q) = %v, want %v", tt.file, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// defaultConfigFile is the config file read when -config is not given. It's
+// optional unlike the file given by -config.
+const defaultConfigFile = ".goverage.yml"
+
+// config is the content of a goverage config file.
+type config struct {
+	// dir is the absolute path of the directory which contains the config
+	// file. Relative package patterns are resolved from dir.
+	dir string
+
+	Packages []packageConfig `yaml:"packages"`
+	Cache    cacheConfig     `yaml:"cache"`
+	Exclude  excludeConfig   `yaml:"exclude"`
+
+	Thresholds thresholdsConfig `yaml:"thresholds"`
+
+	// goGenerate caches outputs of //go:generate directives of each
+	// directory. See generatedReason.
+	goGenerate map[string]map[string]string
+}
+
+// cacheConfig is configuration of the profile cache enabled by -cache.
+type cacheConfig struct {
+	Remote *remoteCacheConfig `yaml:"remote"`
+}
+
+// packageConfig is configuration applied to packages matching Pattern. Pattern
+// is an import path pattern (e.g. "github.com/me/app/...") or a relative one
+// (e.g. "./store/...").
+type packageConfig struct {
+	Pattern string            `yaml:"pattern"`
+	Env     map[string]string `yaml:"env"`
+	// Run is the -run expression of go test to select tests of the
+	// packages.
+	Run string `yaml:"run"`
+	// Pre and Post are hooks run before and after tests of each package.
+	Pre  []hookConfig `yaml:"pre"`
+	Post []hookConfig `yaml:"post"`
+}
+
+// loadConfig loads the config file. If filename is empty, it loads
+// defaultConfigFile if exists and returns an empty config otherwise.
+func loadConfig(filename string) (*config, error) {
+	if filename == "" {
+		filename = defaultConfigFile
+		if !isExist(filename) {
+			dir, err := filepath.Abs(".")
+			return &config{dir: dir}, err
+		}
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	cfg := new(config)
+	if err := yaml.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %v", filename, err)
+	}
+	switch d := cfg.Exclude.GeneratedDetection; d {
+	case "", generatedHeader, generatedDeep:
+	default:
+		return nil, fmt.Errorf("invalid exclude.generated_detection %q in %s: must be header or deep", d, filename)
+	}
+	if cfg.dir, err = filepath.Abs(filepath.Dir(filename)); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// match reports whether the package matches the package pattern.
+func (c *config) match(pattern string, p *listPackage) bool {
+	if !isRelativePattern(pattern) {
+		return matchPattern(pattern, p.ImportPath)
+	}
+	if p.Dir == "" {
+		return false
+	}
+	rel, ok := relPath(c.dir, p.Dir)
+	if !ok {
+		r, err := filepath.Rel(c.dir, p.Dir)
+		if err != nil {
+			return false
+		}
+		rel = filepath.ToSlash(r)
+	}
+	return matchPattern(strings.TrimPrefix(pattern, "./"), rel)
+}
+
+// env returns environment variables in KEY=VALUE form for the package. When
+// several package configs set the same variable, the last one wins.
+func (c *config) env(p *listPackage) []string {
+	vars := map[string]string{}
+	for _, pc := range c.Packages {
+		if !c.match(pc.Pattern, p) {
+			continue
+		}
+		for k, v := range pc.Env {
+			vars[k] = v
+		}
+	}
+	env := make([]string, 0, len(vars))
+	for k, v := range vars {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+	return env
+}
+
+// runPattern returns the -run expression of go test for the package, or an
+// empty string to run all tests. When several package configs set it, the
+// last one wins.
+func (c *config) runPattern(p *listPackage) string {
+	run := ""
+	for _, pc := range c.Packages {
+		if pc.Run != "" && c.match(pc.Pattern, p) {
+			run = pc.Run
+		}
+	}
+	return run
+}
+
+// preHooks returns hooks to run before tests of the package.
+func (c *config) preHooks(p *listPackage) []hookConfig {
+	var hooks []hookConfig
+	for _, pc := range c.Packages {
+		if c.match(pc.Pattern, p) {
+			hooks = append(hooks, pc.Pre...)
+		}
+	}
+	return hooks
+}
+
+// postHooks returns hooks to run after tests of the package.
+func (c *config) postHooks(p *listPackage) []hookConfig {
+	var hooks []hookConfig
+	for _, pc := range c.Packages {
+		if c.match(pc.Pattern, p) {
+			hooks = append(hooks, pc.Post...)
+		}
+	}
+	return hooks
+}
+
+func isRelativePattern(pattern string) bool {
+	return pattern == "." || pattern == ".." ||
+		strings.HasPrefix(pattern, "./") || strings.HasPrefix(pattern, "../")
+}
+
+// matchPattern reports whether name matches the package pattern in the same
+// way as "go list". "..." matches any string and "x/..." also matches "x".
+func matchPattern(pattern, name string) bool {
+	re := regexp.QuoteMeta(pattern)
+	re = strings.Replace(re, `\.\.\.`, `.*`, -1)
+	if strings.HasSuffix(re, `/.*`) {
+		re = strings.TrimSuffix(re, `/.*`) + `(/.*)?`
+	}
+	return regexp.MustCompile(`^` + re + `$`).MatchString(name)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"github.com/me/app", "github.com/me/app", true},
+		{"github.com/me/app", "github.com/me/app/store", false},
+		{"github.com/me/app/...", "github.com/me/app", true},
+		{"github.com/me/app/...", "github.com/me/app/store", true},
+		{"github.com/me/app/...", "github.com/me/application", false},
+		{"github.com/me/.../store", "github.com/me/app/store", true},
+		{"...", ".", true},
+		{"store/...", "store/user", true},
+	}
+	for _, tt := range tests {
+		if got := matchPattern(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchPattern(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, defaultConfigFile)
+	const content = `
+packages:
+  - pattern: ./store/...
+    env:
+      DATABASE_URL: postgres://localhost/test
+      DEBUG: "1"
+  - pattern: example.com/app/store/cache
+    env:
+      DEBUG: "0"
+`
+	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		pkg  *listPackage
+		want []string
+	}{
+		{
+			pkg:  &listPackage{ImportPath: "example.com/app/store", Dir: filepath.Join(dir, "store")},
+			want: []string{"DATABASE_URL=postgres://localhost/test", "DEBUG=1"},
+		},
+		{
+			pkg:  &listPackage{ImportPath: "example.com/app/store/cache", Dir: filepath.Join(dir, "store", "cache")},
+			want: []string{"DATABASE_URL=postgres://localhost/test", "DEBUG=0"},
+		},
+		{
+			pkg:  &listPackage{ImportPath: "example.com/app/web", Dir: filepath.Join(dir, "web")},
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		if got := cfg.env(tt.pkg); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("env(%s) = %v, want %v", tt.pkg.ImportPath, got, tt.want)
+		}
+	}
+}
+
+func TestConfigRunPattern(t *testing.T) {
+	cfg := &config{dir: filepath.FromSlash("/app"), Packages: []packageConfig{
+		{Pattern: "./legacy/...", Run: "TestUnit.*"},
+		{Pattern: "./legacy/db", Env: map[string]string{"DB": "1"}},
+		{Pattern: "example.com/app/legacy/api", Run: "TestAPI"},
+	}}
+	tests := []struct {
+		pkg  *listPackage
+		want string
+	}{
+		{&listPackage{ImportPath: "example.com/app/legacy/db", Dir: filepath.FromSlash("/app/legacy/db")}, "TestUnit.*"},
+		{&listPackage{ImportPath: "example.com/app/legacy/api", Dir: filepath.FromSlash("/app/legacy/api")}, "TestAPI"},
+		{&listPackage{ImportPath: "example.com/app/web", Dir: filepath.FromSlash("/app/web")}, ""},
+	}
+	for _, tt := range tests {
+		if got := cfg.runPattern(tt.pkg); got != tt.want {
+			t.Errorf("runPattern(%s) = %q, want %q", tt.pkg.ImportPath, got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfig_default(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("./example/root"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	cfg, err := loadConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Packages) != 0 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if _, err := loadConfig("not-exist.yml"); err == nil {
+		t.Error("got nil error for missing config given explicitly")
+	}
+}
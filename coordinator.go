@@ -0,0 +1,163 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/cover"
+)
+
+// runCoordinator serves an HTTP endpoint which merges profiles uploaded by
+// sharded CI jobs as they arrive, and serves the combined profile once all
+// shards reported.
+func runCoordinator(args []string) error {
+	fs := newFlagSet("coordinator", "-shards n [-addr :7777] [-token-env GOVERAGE_COORDINATOR_TOKEN] [-o coverage.out]")
+	addr := fs.String("addr", ":7777", "Address to listen on")
+	shards := fs.Int("shards", 0, "Number of shards expected to upload profiles")
+	tokenEnv := fs.String("token-env", "GOVERAGE_COORDINATOR_TOKEN", "Environment variable which has the bearer token required for requests")
+	out := fs.String("o", "", "Write the combined profile to the file once all shards reported")
+	fs.Parse(args)
+	if *shards < 1 {
+		fs.Usage()
+		return errors.New("goverage coordinator: -shards must be positive")
+	}
+	token := os.Getenv(*tokenEnv)
+	if token == "" {
+		return fmt.Errorf("goverage coordinator: $%s is empty; a token is required", *tokenEnv)
+	}
+	log.Printf("waiting for %d shard(s) on %s", *shards, *addr)
+	return http.ListenAndServe(*addr, newCoordinator(*shards, token, *out))
+}
+
+// coordinator is the HTTP handler of "goverage coordinator". Every request
+// needs the bearer token.
+//
+//	PUT /shards/<name>  uploads the text profile of the shard. Uploading
+//	                    the same shard again replaces its profile.
+//	GET /status         returns the status as JSON.
+//	GET /profile        returns the combined profile once all shards reported.
+type coordinator struct {
+	token    string
+	expected int
+	// out is the file to write the combined profile to. It's optional.
+	out string
+
+	mu     sync.Mutex
+	shards map[string][]*cover.Profile
+	merged []*cover.Profile
+}
+
+func newCoordinator(expected int, token, out string) *coordinator {
+	return &coordinator{
+		token:    token,
+		expected: expected,
+		out:      out,
+		shards:   make(map[string][]*cover.Profile),
+	}
+}
+
+// coordinatorStatus is the response of GET /status.
+type coordinatorStatus struct {
+	Expected int      `json:"expected"`
+	Reported []string `json:"reported"`
+	Complete bool     `json:"complete"`
+	// Coverage is the total statement coverage of the combined profile. It's
+	// only set once all shards reported.
+	Coverage *float64 `json:"coverage,omitempty"`
+}
+
+func (c *coordinator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+c.token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/shards/") && r.Method == http.MethodPut:
+		c.upload(w, r, strings.TrimPrefix(r.URL.Path, "/shards/"))
+	case r.URL.Path == "/status" && r.Method == http.MethodGet:
+		c.mu.Lock()
+		s := c.status()
+		c.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s)
+	case r.URL.Path == "/profile" && r.Method == http.MethodGet:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if len(c.shards) < c.expected {
+			http.Error(w, fmt.Sprintf("%d of %d shard(s) reported", len(c.shards), c.expected), http.StatusConflict)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		dumpcp(w, c.merged)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (c *coordinator) upload(w http.ResponseWriter, r *http.Request, name string) {
+	if name == "" || strings.Contains(name, "/") {
+		http.Error(w, "invalid shard name", http.StatusBadRequest)
+		return
+	}
+	profiles, err := cover.ParseProfilesFromReader(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot parse the profile: %v", err), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.shards[name]; !ok && len(c.shards) >= c.expected {
+		http.Error(w, fmt.Sprintf("all %d shard(s) already reported", c.expected), http.StatusConflict)
+		return
+	}
+	names := make([]string, 0, len(c.shards)+1)
+	for n := range c.shards {
+		if n != name {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	cpss := [][]*cover.Profile{profiles}
+	for _, n := range names {
+		cpss = append(cpss, c.shards[n])
+	}
+	if _, err := checkModes(cpss, ""); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.shards[name] = profiles
+	c.merged = mergeProfiles(cpss)
+	s := c.status()
+	log.Printf("shard %s reported (%d/%d)", name, len(s.Reported), c.expected)
+	if s.Complete && c.out != "" {
+		if err := writeProfile(c.out, c.merged); err != nil {
+			log.Printf("failed to write %s: %v", c.out, err)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s)
+}
+
+// status returns the status. It must be called with mu held.
+func (c *coordinator) status() *coordinatorStatus {
+	s := &coordinatorStatus{Expected: c.expected, Reported: []string{}}
+	for n := range c.shards {
+		s.Reported = append(s.Reported, n)
+	}
+	sort.Strings(s.Reported)
+	if len(c.shards) >= c.expected {
+		s.Complete = true
+		stats := statementStats(c.merged)
+		total := stats.percent()
+		s.Coverage = &total
+	}
+	return s
+}
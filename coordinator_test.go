@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCoordinator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-coordinator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "coverage.out")
+	ts := httptest.NewServer(newCoordinator(2, "secret", out))
+	defer ts.Close()
+	do := func(method, path, token, body string) (int, string) {
+		t.Helper()
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(b)
+	}
+
+	if code, _ := do("PUT", "/shards/1", "wrong", "mode: set\n"); code != http.StatusUnauthorized {
+		t.Errorf("upload with a wrong token: got %d", code)
+	}
+	if code, _ := do("PUT", "/shards/1", "secret", "mode: set\na.go:1.1,2.2 1 1\n"); code != http.StatusOK {
+		t.Errorf("upload shard 1: got %d", code)
+	}
+	if code, _ := do("GET", "/profile", "secret", ""); code != http.StatusConflict {
+		t.Errorf("profile before all shards reported: got %d", code)
+	}
+	if code, _ := do("PUT", "/shards/2", "secret", "mode: count\na.go:3.1,4.2 1 0\n"); code != http.StatusBadRequest {
+		t.Errorf("upload of a different mode: got %d", code)
+	}
+	if code, _ := do("PUT", "/shards/2", "secret", "mode: set\na.go:1.1,2.2 1 0\na.go:3.1,4.2 1 0\n"); code != http.StatusOK {
+		t.Errorf("upload shard 2: got %d", code)
+	}
+	if code, _ := do("PUT", "/shards/3", "secret", "mode: set\n"); code != http.StatusConflict {
+		t.Errorf("upload of an unexpected shard: got %d", code)
+	}
+	code, body := do("GET", "/status", "secret", "")
+	if code != http.StatusOK {
+		t.Fatalf("status: got %d", code)
+	}
+	var s coordinatorStatus
+	if err := json.Unmarshal([]byte(body), &s); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Complete || len(s.Reported) != 2 || s.Coverage == nil || *s.Coverage != 50 {
+		t.Errorf("status: got %s", body)
+	}
+	want := "mode: set\na.go:1.1,2.2 1 1\na.go:3.1,4.2 1 0\n"
+	if code, body := do("GET", "/profile", "secret", ""); code != http.StatusOK || body != want {
+		t.Errorf("profile: got %d %q, want %q", code, body, want)
+	}
+	if b, err := ioutil.ReadFile(out); err != nil || string(b) != want {
+		t.Errorf("written profile: got %q (%v), want %q", b, err, want)
+	}
+}
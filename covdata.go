@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/cover"
+)
+
+// covdataProfiles converts binary coverage data files in the GOCOVERDIR
+// directory written by instrumented binaries (Go 1.20+) to cover profiles by
+// "go tool covdata textfmt". It returns nil if dir has no coverage data.
+func covdataProfiles(dir string) ([]*cover.Profile, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+	out, err := tmpProfileName()
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(out)
+	stderr := new(bytes.Buffer)
+	cmd := exec.Command("go", "tool", "covdata", "textfmt", "-i", dir, "-o", out)
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to run 'go tool covdata textfmt -i %s': %v\n%s", dir, err, stderr.Bytes())
+	}
+	profiles, err := cover.ParseProfiles(out)
+	if err != nil {
+		return nil, &ProfileParseError{File: out, Err: err}
+	}
+	return profiles, nil
+}
+
+// nativeMergeProfiles merges binary coverage data in dirs by "go tool covdata
+// merge" and converts the result to cover profiles. Empty directories are
+// ignored.
+func nativeMergeProfiles(dirs []string) ([]*cover.Profile, error) {
+	inputs, err := nonEmptyDirs(dirs)
+	if err != nil || len(inputs) == 0 {
+		return nil, err
+	}
+	merged, err := ioutil.TempDir("", "goverage-merged")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(merged)
+	if err := covdataMerge(inputs, merged); err != nil {
+		return nil, err
+	}
+	return covdataProfiles(merged)
+}
+
+// mergeCovdata merges binary coverage data in dirs into the directory out by
+// "go tool covdata merge". Data already in out is merged too, so that results
+// of several runs accumulate in it.
+func mergeCovdata(dirs []string, out string) error {
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return err
+	}
+	inputs, err := nonEmptyDirs(append(dirs[:len(dirs):len(dirs)], out))
+	if err != nil || len(inputs) == 0 {
+		return err
+	}
+	merged, err := ioutil.TempDir("", "goverage-merged")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(merged)
+	if err := covdataMerge(inputs, merged); err != nil {
+		return err
+	}
+	// Replace data in out with the merged data.
+	old, err := ioutil.ReadDir(out)
+	if err != nil {
+		return err
+	}
+	for _, f := range old {
+		if strings.HasPrefix(f.Name(), "covmeta.") || strings.HasPrefix(f.Name(), "covcounters.") {
+			if err := os.Remove(filepath.Join(out, f.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	files, err := ioutil.ReadDir(merged)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		b, err := ioutil.ReadFile(filepath.Join(merged, f.Name()))
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(out, f.Name()), b, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// covdataMerge merges binary coverage data in inputs into out by "go tool
+// covdata merge".
+func covdataMerge(inputs []string, out string) error {
+	stderr := new(bytes.Buffer)
+	cmd := exec.Command("go", "tool", "covdata", "merge", "-i", strings.Join(inputs, ","), "-o", out)
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run 'go tool covdata merge': %v\n%s", err, stderr.Bytes())
+	}
+	return nil
+}
+
+// nonEmptyDirs returns directories in dirs which have files.
+func nonEmptyDirs(dirs []string) ([]string, error) {
+	var result []string
+	for _, dir := range dirs {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) > 0 {
+			result = append(result, dir)
+		}
+	}
+	return result, nil
+}
+
+// goSupportsCovdata reports whether the go command supports binary coverage
+// data (Go 1.20+).
+func goSupportsCovdata() bool {
+	out, err := exec.Command("go", "env", "GOVERSION").Output()
+	if err != nil {
+		// GOVERSION is not available before Go 1.16.
+		return false
+	}
+	return goVersionAtLeast(strings.TrimSpace(string(out)), 1, 20)
+}
+
+var goVersionRe = regexp.MustCompile(`^go(\d+)\.(\d+)`)
+
+// goVersionAtLeast reports whether the version string such as "go1.21.3" is
+// major.minor or later. Development versions are always considered new enough.
+func goVersionAtLeast(version string, major, minor int) bool {
+	if strings.HasPrefix(version, "devel") {
+		return true
+	}
+	m := goVersionRe.FindStringSubmatch(version)
+	if m == nil {
+		return false
+	}
+	maj, _ := strconv.Atoi(m[1])
+	min, _ := strconv.Atoi(m[2])
+	return maj > major || maj == major && min >= minor
+}
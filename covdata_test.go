@@ -0,0 +1,89 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCovdataProfiles_empty(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-covdata-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	profiles, err := covdataProfiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profiles != nil {
+		t.Errorf("got %v, want nil", profiles)
+	}
+}
+
+func TestGoVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{"go1.20", true},
+		{"go1.21.3", true},
+		{"go1.19.13", false},
+		{"go2.0", true},
+		{"devel go1.23-abcdef", true},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := goVersionAtLeast(tt.version, 1, 20); got != tt.want {
+			t.Errorf("goVersionAtLeast(%q, 1, 20) = %v, want %v", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestNonEmptyDirs(t *testing.T) {
+	root, err := ioutil.TempDir("", "goverage-covdata-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	empty := filepath.Join(root, "empty")
+	full := filepath.Join(root, "full")
+	for _, dir := range []string{empty, full} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(full, "covmeta.x"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := nonEmptyDirs([]string{empty, full})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != full {
+		t.Errorf("got %v, want [%s]", got, full)
+	}
+	if _, err := nonEmptyDirs([]string{filepath.Join(root, "missing")}); err == nil {
+		t.Error("want error for a missing directory")
+	}
+}
+
+func TestMergeCovdata_empty(t *testing.T) {
+	root, err := ioutil.TempDir("", "goverage-covdata-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	in := filepath.Join(root, "in")
+	if err := os.Mkdir(in, 0755); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(root, "out")
+	if err := mergeCovdata([]string{in}, out); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(out); err != nil || !fi.IsDir() {
+		t.Errorf("want directory %s to be created: %v", out, err)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Values of -coverpkg-mode, which controls packages instrumented for tests of
+// each package.
+const (
+	// coverpkgAll instruments all target packages. It's the default.
+	coverpkgAll = "all"
+	// coverpkgModule instruments target packages in the module of the
+	// package.
+	coverpkgModule = "module"
+	// coverpkgDeps instruments the package and target packages in its module
+	// which it depends on.
+	coverpkgDeps = "deps"
+	// coverpkgSelf instruments only the package.
+	coverpkgSelf = "self"
+)
+
+// coverpkgs returns the -coverpkg argument for tests of each package in pkgs,
+// which are target packages, keyed by the import path. Packages in GOPATH
+// mode belong to the same module.
+func coverpkgs(mode string, pkgs []*listPackage) (map[string]string, error) {
+	modulePath := func(p *listPackage) string {
+		if p.Module == nil {
+			return ""
+		}
+		return p.Module.Path
+	}
+	result := make(map[string]string, len(pkgs))
+	switch mode {
+	case "", coverpkgAll:
+		importPaths := make([]string, 0, len(pkgs))
+		for _, p := range pkgs {
+			importPaths = append(importPaths, p.ImportPath)
+		}
+		all := strings.Join(importPaths, ",")
+		for _, p := range pkgs {
+			result[p.ImportPath] = all
+		}
+	case coverpkgModule:
+		modules := make(map[string][]string)
+		for _, p := range pkgs {
+			modules[modulePath(p)] = append(modules[modulePath(p)], p.ImportPath)
+		}
+		for _, p := range pkgs {
+			result[p.ImportPath] = strings.Join(modules[modulePath(p)], ",")
+		}
+	case coverpkgDeps:
+		for _, p := range pkgs {
+			deps := make(map[string]bool, len(p.Deps))
+			for _, d := range p.Deps {
+				deps[d] = true
+			}
+			importPaths := []string{p.ImportPath}
+			for _, q := range pkgs {
+				if deps[q.ImportPath] && modulePath(q) == modulePath(p) {
+					importPaths = append(importPaths, q.ImportPath)
+				}
+			}
+			result[p.ImportPath] = strings.Join(importPaths, ",")
+		}
+	case coverpkgSelf:
+		for _, p := range pkgs {
+			result[p.ImportPath] = p.ImportPath
+		}
+	default:
+		return nil, fmt.Errorf("invalid -coverpkg-mode %q: must be all, module, deps or self", mode)
+	}
+	return result, nil
+}
+
+// addCoverDeps adds package patterns of -cover-deps to each coverpkg.
+// "go test" matches them against packages the test depends on, so
+// dependencies in the module cache can be instrumented too.
+func addCoverDeps(coverpkgOf map[string]string, deps string) {
+	var patterns []string
+	for _, pattern := range strings.Split(deps, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	if len(patterns) == 0 {
+		return
+	}
+	for pkg, coverpkg := range coverpkgOf {
+		coverpkgOf[pkg] = coverpkg + "," + strings.Join(patterns, ",")
+	}
+}
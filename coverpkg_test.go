@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCoverpkgs(t *testing.T) {
+	app := &listModule{Path: "example.com/app"}
+	tools := &listModule{Path: "example.com/app/tools"}
+	pkgs := []*listPackage{
+		{ImportPath: "example.com/app", Module: app, Deps: []string{"example.com/app/store", "fmt"}},
+		{ImportPath: "example.com/app/store", Module: app},
+		{ImportPath: "example.com/app/tools/gen", Module: tools, Deps: []string{"example.com/app/store"}},
+	}
+	tests := []struct {
+		mode string
+		want map[string]string
+	}{
+		{"all", map[string]string{
+			"example.com/app":           "example.com/app,example.com/app/store,example.com/app/tools/gen",
+			"example.com/app/store":     "example.com/app,example.com/app/store,example.com/app/tools/gen",
+			"example.com/app/tools/gen": "example.com/app,example.com/app/store,example.com/app/tools/gen",
+		}},
+		{"module", map[string]string{
+			"example.com/app":           "example.com/app,example.com/app/store",
+			"example.com/app/store":     "example.com/app,example.com/app/store",
+			"example.com/app/tools/gen": "example.com/app/tools/gen",
+		}},
+		{"deps", map[string]string{
+			"example.com/app":           "example.com/app,example.com/app/store",
+			"example.com/app/store":     "example.com/app/store",
+			"example.com/app/tools/gen": "example.com/app/tools/gen",
+		}},
+		{"self", map[string]string{
+			"example.com/app":           "example.com/app",
+			"example.com/app/store":     "example.com/app/store",
+			"example.com/app/tools/gen": "example.com/app/tools/gen",
+		}},
+	}
+	for _, tt := range tests {
+		got, err := coverpkgs(tt.mode, pkgs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("coverpkgs(%q) = %v, want %v", tt.mode, got, tt.want)
+		}
+	}
+	if _, err := coverpkgs("none", pkgs); err == nil {
+		t.Error("want error for invalid mode")
+	}
+}
+
+func TestAddCoverDeps(t *testing.T) {
+	coverpkgOf := map[string]string{"example.com/app": "example.com/app", "example.com/app/store": "example.com/app/store"}
+	addCoverDeps(coverpkgOf, "github.com/partner/sdk/..., github.com/partner/auth,")
+	want := map[string]string{
+		"example.com/app":       "example.com/app,github.com/partner/sdk/...,github.com/partner/auth",
+		"example.com/app/store": "example.com/app/store,github.com/partner/sdk/...,github.com/partner/auth",
+	}
+	if !reflect.DeepEqual(coverpkgOf, want) {
+		t.Errorf("got %v, want %v", coverpkgOf, want)
+	}
+}
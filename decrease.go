@@ -0,0 +1,69 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// baselineKey returns the key of the baseline of runs of the package patterns
+// in the state. Runs with -short or build tags have their own baselines, so
+// that e.g. suites of -suites are not compared with each other.
+func baselineKey(patterns []string, short bool, tags string) string {
+	ps := append([]string{}, patterns...)
+	sort.Strings(ps)
+	key := strings.Join(ps, " ")
+	if short {
+		key += " -short"
+	}
+	if tags != "" {
+		key += " -tags=" + tags
+	}
+	return key
+}
+
+// raiseBaseline records coverage as the baseline of the key if it's higher
+// than the current one. The baseline is never lowered, so that coverage
+// cannot drop by -max-decrease in each of successive runs.
+func (s *runState) raiseBaseline(key string, coverage float64) {
+	if s.Baselines == nil {
+		s.Baselines = make(map[string]float64)
+	}
+	if b, ok := s.Baselines[key]; !ok || coverage > b {
+		s.Baselines[key] = coverage
+	}
+}
+
+// baselineCoverage returns the total coverage to compare with for
+// -max-decrease. It's the coverage of the baseline profile if filename is not
+// empty, in which files excluded by the config are removed and the profile is
+// rewritten as the result of the run. Otherwise, it's the highest coverage of
+// runs without failures of the key in the state. ok is false if there is no
+// baseline yet.
+func baselineCoverage(filename string, state *runState, key string, cfg *config) (coverage float64, ok bool, err error) {
+	if filename == "" {
+		coverage, ok = state.Baselines[key]
+		return coverage, ok, nil
+	}
+	profiles, err := readProfiles(filename)
+	if err != nil {
+		return 0, false, err
+	}
+	resolve := newFileResolver().resolve
+	if !cfg.Exclude.empty() {
+		resolve = newProfileResolver(profiles).resolve
+	}
+	// Ignore directives of the current source don't apply to lines of the
+	// baseline, which may have been different.
+	profiles = rewriteProfiles(cfg.excludeProfiles(profiles, resolve), rewrites)
+	s := statementStats(profiles)
+	return s.percent(), true, nil
+}
+
+// checkDecrease returns a CoverageDecreaseError if coverage decreased from
+// baseline by more than maxDecrease percentage points.
+func checkDecrease(coverage, baseline, maxDecrease float64) error {
+	if baseline-coverage > maxDecrease {
+		return &CoverageDecreaseError{Coverage: coverage, Baseline: baseline, MaxDecrease: maxDecrease}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckDecrease(t *testing.T) {
+	tests := []struct {
+		coverage, baseline, max float64
+		wantErr                 bool
+	}{
+		{80, 80, 0, false},
+		{80.2, 80, 0, false},
+		{79.6, 80, 0.5, false},
+		{79.4, 80, 0.5, true},
+		{79.9, 80, 0, true},
+	}
+	for _, tt := range tests {
+		err := checkDecrease(tt.coverage, tt.baseline, tt.max)
+		if (err != nil) != tt.wantErr || err != nil && !errors.Is(err, ErrThreshold) {
+			t.Errorf("checkDecrease(%v, %v, %v) = %v, want error %v", tt.coverage, tt.baseline, tt.max, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBaselineKey(t *testing.T) {
+	if got, want := baselineKey([]string{"./b/...", "./a"}, true, "integration"), "./a ./b/... -short -tags=integration"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if baselineKey([]string{"./a", "./b"}, false, "") != baselineKey([]string{"./b", "./a"}, false, "") {
+		t.Error("the key depends on the order of patterns")
+	}
+}
+
+func TestBaselineCoverage(t *testing.T) {
+	cfg := &config{dir: "."}
+	if _, ok, err := baselineCoverage("", &runState{}, "./...", cfg); ok || err != nil {
+		t.Errorf("got baseline without history: %v, %v", ok, err)
+	}
+	state := &runState{}
+	state.raiseBaseline("./...", 60)
+	state.raiseBaseline("./...", 55)
+	state.raiseBaseline("./store/...", 90)
+	if got, ok, err := baselineCoverage("", state, "./...", cfg); !ok || err != nil || got != 60 {
+		t.Errorf("baselineCoverage() = %v, %v, %v; want 60", got, ok, err)
+	}
+	if _, ok, _ := baselineCoverage("", state, "./... -short", cfg); ok {
+		t.Error("got a baseline of another key")
+	}
+	dir, err := ioutil.TempDir("", "goverage-baseline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	profile := filepath.Join(dir, "base.out")
+	if err := ioutil.WriteFile(profile, []byte("mode: set\nexample.com/a/a.go:3.10,5.2 3 1\nexample.com/a/a.go:6.10,7.2 1 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok, err := baselineCoverage(profile, state, "./...", cfg); !ok || err != nil || got != 75 {
+		t.Errorf("baselineCoverage(%s) = %v, %v, %v; want 75", profile, got, ok, err)
+	}
+}
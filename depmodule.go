@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// moduleDirs is directories of -dir keyed by paths of their modules. Tests of
+// packages of the modules run there instead of the module cache. It
+// implements flag.Value to be given several times.
+type moduleDirs map[string]string
+
+func (d *moduleDirs) String() string {
+	var dirs []string
+	for _, dir := range *d {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return strings.Join(dirs, ",")
+}
+
+func (d *moduleDirs) Set(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	mod, err := readModulePath(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return fmt.Errorf("invalid -dir %s: %v", dir, err)
+	}
+	if *d == nil {
+		*d = make(moduleDirs)
+	}
+	(*d)[mod] = dir
+	return nil
+}
+
+// readModulePath returns the module path declared in the go.mod file.
+func readModulePath(gomod string) (string, error) {
+	f, err := os.Open(gomod)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 2 || fields[0] != "module" {
+			continue
+		}
+		if mod, err := strconv.Unquote(fields[1]); err == nil {
+			return mod, nil
+		}
+		return fields[1], nil
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no module directive in %s", gomod)
+}
+
+// external reports whether p is a package outside of the main module, e.g. a
+// dependency in the module cache. Packages in GOPATH mode are not external.
+func external(p *listPackage) bool {
+	return p.Module != nil && !p.Module.Main
+}
+
+// apply moves directories of packages of modules with -dir to the
+// directories, so that their tests run and their sources are read there.
+func (d moduleDirs) apply(pkgs []*listPackage) {
+	for _, p := range pkgs {
+		if !external(p) {
+			continue
+		}
+		dir, ok := d[p.Module.Path]
+		if !ok {
+			continue
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(p.ImportPath, p.Module.Path), "/")
+		m := *p.Module
+		m.Dir = dir
+		p.Module = &m
+		p.Dir = filepath.Join(dir, filepath.FromSlash(rel))
+	}
+}
+
+// testDir returns the directory to run tests of p in, which is the root of its
+// module for external packages. It's empty for other packages, whose tests
+// run in the working directory.
+func testDir(p *listPackage) string {
+	if !external(p) {
+		return ""
+	}
+	return p.Module.Dir
+}
+
+// restrictExternalCoverpkg restricts coverpkg of external packages to target
+// packages in their modules, since tests of them run in their modules, which
+// cannot see packages of the main module.
+func restrictExternalCoverpkg(coverpkgOf map[string]string, pkgs []*listPackage) {
+	for _, p := range pkgs {
+		if !external(p) {
+			continue
+		}
+		var importPaths []string
+		for _, pkg := range strings.Split(coverpkgOf[p.ImportPath], ",") {
+			if pkg == p.Module.Path || strings.HasPrefix(pkg, p.Module.Path+"/") {
+				importPaths = append(importPaths, pkg)
+			}
+		}
+		if len(importPaths) == 0 {
+			importPaths = []string{p.ImportPath}
+		}
+		coverpkgOf[p.ImportPath] = strings.Join(importPaths, ",")
+	}
+}
+
+// hasExternal reports whether any of pkgs is external.
+func hasExternal(pkgs []*listPackage) bool {
+	for _, p := range pkgs {
+		if external(p) {
+			return true
+		}
+	}
+	return false
+}
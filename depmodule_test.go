@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestModuleDirs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-depmodule")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("// SDK\nmodule \"example.com/sdk\"\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var d moduleDirs
+	if err := d.Set(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Set(filepath.Join(dir, "missing")); err == nil {
+		t.Error("want error for a directory without go.mod")
+	}
+	main := &listPackage{ImportPath: "example.com/app", Dir: "/src/app", Module: &listModule{Path: "example.com/app", Dir: "/src/app", Main: true}}
+	cache := &listModule{Path: "example.com/sdk", Dir: "/mod/example.com/sdk@v1.0.0"}
+	sdk := &listPackage{ImportPath: "example.com/sdk/client", Dir: "/mod/example.com/sdk@v1.0.0/client", Module: cache}
+	other := &listPackage{ImportPath: "example.com/other", Dir: "/mod/example.com/other@v1.0.0", Module: &listModule{Path: "example.com/other", Dir: "/mod/example.com/other@v1.0.0"}}
+	d.apply([]*listPackage{main, sdk, other})
+	if want := filepath.Join(dir, "client"); sdk.Dir != want {
+		t.Errorf("got %s, want %s", sdk.Dir, want)
+	}
+	if cache.Dir != "/mod/example.com/sdk@v1.0.0" {
+		t.Errorf("apply modified the module of go list: %s", cache.Dir)
+	}
+	for _, tt := range []struct {
+		p    *listPackage
+		want string
+	}{
+		{main, ""},
+		{sdk, dir},
+		{other, "/mod/example.com/other@v1.0.0"},
+		{&listPackage{ImportPath: "gopath/pkg"}, ""},
+	} {
+		if got := testDir(tt.p); got != tt.want {
+			t.Errorf("testDir(%s) = %q, want %q", tt.p.ImportPath, got, tt.want)
+		}
+	}
+}
+
+func TestRestrictExternalCoverpkg(t *testing.T) {
+	pkgs := []*listPackage{
+		{ImportPath: "example.com/app", Module: &listModule{Path: "example.com/app", Main: true}},
+		{ImportPath: "example.com/sdk/client", Module: &listModule{Path: "example.com/sdk"}},
+		{ImportPath: "example.com/other", Module: &listModule{Path: "example.com/other"}},
+	}
+	all := "example.com/app,example.com/sdk/client,example.com/sdk"
+	coverpkgOf := map[string]string{"example.com/app": all, "example.com/sdk/client": all, "example.com/other": all}
+	restrictExternalCoverpkg(coverpkgOf, pkgs)
+	want := map[string]string{
+		"example.com/app":        all,
+		"example.com/sdk/client": "example.com/sdk/client,example.com/sdk",
+		"example.com/other":      "example.com/other",
+	}
+	for pkg, w := range want {
+		if got := coverpkgOf[pkg]; got != w {
+			t.Errorf("coverpkg of %s = %q, want %q", pkg, got, w)
+		}
+	}
+	if !hasExternal(pkgs) || hasExternal(pkgs[:1]) {
+		t.Error("hasExternal is wrong")
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+)
+
+// moduleRoot is the directory mounted into containers of -docker or copied to
+// -workers, which is the root of the main module or the current directory.
+// It's set by run.
+var moduleRoot string
+
+// mainModuleDir returns the directory of the main module of pkgs. It's empty
+// in GOPATH mode.
+func mainModuleDir(pkgs []*listPackage) string {
+	for _, p := range pkgs {
+		if p.Module != nil && p.Module.Main {
+			return p.Module.Dir
+		}
+	}
+	return ""
+}
+
+// containerSeq numbers containers of the process to name them uniquely.
+var containerSeq int64
+
+// dockerCommand returns a command to run the go command with args in a
+// container of the image, and the name of the container. root, the working
+// directory and the temporary directory, where profiles are written, are
+// mounted at the same paths as the host, so that paths in args and profiles
+// are valid on both sides. The module cache of the host, which has
+// dependencies downloaded by "go list" of goverage, is mounted read-only. env
+// is set in the container, and the container has no network if noNetwork is
+// true.
+func dockerCommand(image, root string, args, env []string, noNetwork bool) (*exec.Cmd, string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, "", err
+	}
+	tmp := os.TempDir()
+	name := fmt.Sprintf("goverage-%d-%d", os.Getpid(), atomic.AddInt64(&containerSeq, 1))
+	// --init forwards signals to "go test" and reaps test binaries, and the
+	// container is removed even if it's killed by name on interrupts.
+	dockerArgs := []string{"run", "--rm", "--init", "--name", name,
+		"-v", root + ":" + root,
+		"-v", tmp + ":" + tmp,
+		"-w", wd,
+		// The build cache in the mounted temporary directory is shared by
+		// containers.
+		"-e", "GOCACHE=" + filepath.Join(tmp, "goverage-docker-gocache"),
+	}
+	if _, ok := relPath(root, wd); !ok {
+		dockerArgs = append(dockerArgs, "-v", wd+":"+wd)
+	}
+	if modCache := hostModCache(); modCache != "" && isDir(modCache) {
+		dockerArgs = append(dockerArgs, "-v", modCache+":"+modCache+":ro", "-e", "GOMODCACHE="+modCache)
+	}
+	if uid, gid := os.Getuid(), os.Getgid(); uid >= 0 {
+		// Run as the current user not to leave files owned by root.
+		dockerArgs = append(dockerArgs, "-u", strconv.Itoa(uid)+":"+strconv.Itoa(gid))
+	}
+	if goflags := os.Getenv("GOFLAGS"); goflags != "" {
+		dockerArgs = append(dockerArgs, "-e", "GOFLAGS="+goflags)
+	}
+	for _, kv := range env {
+		dockerArgs = append(dockerArgs, "-e", kv)
+	}
+	if noNetwork {
+		// Fail fast on missing modules instead of waiting for the proxy.
+		dockerArgs = append(dockerArgs, "--network", "none", "-e", "GOPROXY=off")
+	}
+	dockerArgs = append(append(dockerArgs, image, "go"), args...)
+	return exec.Command("docker", dockerArgs...), name, nil
+}
+
+// hostModCache returns the module cache of the host, which is GOMODCACHE or
+// pkg/mod in the first GOPATH entry.
+func hostModCache() string {
+	if dir := os.Getenv("GOMODCACHE"); dir != "" {
+		return dir
+	}
+	gopath := os.Getenv("GOPATH")
+	if gopath == "" {
+		gopath = build.Default.GOPATH
+	}
+	if list := filepath.SplitList(gopath); len(list) > 0 && list[0] != "" {
+		return filepath.Join(list[0], "pkg", "mod")
+	}
+	return ""
+}
+
+// killContainer kills the container by name, since killing the docker client
+// doesn't stop it.
+func killContainer(name string) {
+	if err := exec.Command("docker", "kill", name).Run(); err != nil {
+		log.Printf("failed to kill container %s: %v", name, err)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestDockerCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("containers run as the current user only on Unix")
+	}
+	defer os.Setenv("GOFLAGS", os.Getenv("GOFLAGS"))
+	os.Setenv("GOFLAGS", "-mod=mod")
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp := os.TempDir()
+	modCache, err := ioutil.TempDir("", "goverage-modcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(modCache)
+	defer os.Setenv("GOMODCACHE", os.Getenv("GOMODCACHE"))
+	os.Setenv("GOMODCACHE", modCache)
+	cmd, name, err := dockerCommand("golang:1.22", wd, []string{"test", "./...", "-coverprofile", "/tmp/c.out"}, []string{"A=1"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"docker", "run", "--rm", "--init", "--name", name,
+		"-v", wd + ":" + wd,
+		"-v", tmp + ":" + tmp,
+		"-w", wd,
+		"-e", "GOCACHE=" + tmp + "/goverage-docker-gocache",
+		"-v", modCache + ":" + modCache + ":ro",
+		"-e", "GOMODCACHE=" + modCache,
+		"-u", strconv.Itoa(os.Getuid()) + ":" + strconv.Itoa(os.Getgid()),
+		"-e", "GOFLAGS=-mod=mod",
+		"-e", "A=1",
+		"--network", "none",
+		"-e", "GOPROXY=off",
+		"golang:1.22", "go", "test", "./...", "-coverprofile", "/tmp/c.out",
+	}
+	if !reflect.DeepEqual(cmd.Args, want) {
+		t.Errorf("got %q, want %q", cmd.Args, want)
+	}
+	if _, other, _ := dockerCommand("golang:1.22", wd, nil, nil, false); other == name {
+		t.Errorf("got the same container name %q twice", name)
+	}
+}
+
+func TestMainModuleDir(t *testing.T) {
+	pkgs := []*listPackage{
+		{ImportPath: "example.com/dep", Module: &listModule{Path: "example.com/dep", Dir: "/mod/dep"}},
+		{ImportPath: "example.com/app", Module: &listModule{Path: "example.com/app", Dir: "/src/app", Main: true}},
+	}
+	if got := mainModuleDir(pkgs); got != "/src/app" {
+		t.Errorf("got %q, want /src/app", got)
+	}
+	if got := mainModuleDir([]*listPackage{{ImportPath: "a"}}); got != "" {
+		t.Errorf("got %q in GOPATH mode", got)
+	}
+}
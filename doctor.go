@@ -0,0 +1,183 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Statuses of doctor checks.
+const (
+	doctorOK   = "ok"
+	doctorWarn = "WARN"
+	doctorFail = "FAIL"
+)
+
+// doctorResult is the result of a check of goverage doctor. Hint tells how to
+// fix the problem.
+type doctorResult struct {
+	Status string
+	Name   string
+	Msg    string
+	Hint   string
+}
+
+// runDoctor checks the environment goverage runs in and the given goverage
+// flags, and prints diagnostics with hints to fix problems.
+func runDoctor(args []string) error {
+	// Flags are those of goverage, so that flags of a failing run can be
+	// checked as they are.
+	flag.CommandLine.Parse(args)
+	results := []doctorResult{
+		checkGoToolchain(),
+		checkGoTool("cover", doctorFail, "coverage profiles cannot be written by 'go test'"),
+		checkCovdata(),
+		checkTempDir(),
+		checkModuleMode(),
+		checkFlags(),
+	}
+	if failed := writeDoctor(os.Stdout, results); failed > 0 {
+		return fmt.Errorf("goverage doctor: %d problem(s) found", failed)
+	}
+	return nil
+}
+
+// writeDoctor prints results and returns the number of failed checks.
+func writeDoctor(w io.Writer, results []doctorResult) int {
+	failed := 0
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s: %s\n", r.Status, r.Name, r.Msg)
+		if r.Hint != "" && r.Status != doctorOK {
+			fmt.Fprintf(w, "\t%s\n", r.Hint)
+		}
+		if r.Status == doctorFail {
+			failed++
+		}
+	}
+	return failed
+}
+
+// checkGoToolchain checks the go command and -go-binary.
+func checkGoToolchain() doctorResult {
+	r := doctorResult{Name: "go toolchain"}
+	v, err := goVersion()
+	if err != nil {
+		r.Status, r.Msg = doctorFail, fmt.Sprintf("cannot run 'go version': %v", err)
+		r.Hint = "Install Go from https://go.dev/dl/ and make sure 'go' is in PATH"
+		return r
+	}
+	r.Status, r.Msg = doctorOK, strings.TrimPrefix(v, "go version ")
+	if gobinary != "go" {
+		if _, err := exec.LookPath(gobinary); err != nil {
+			r.Status, r.Msg = doctorFail, fmt.Sprintf("-go-binary %s is not found: %v", gobinary, err)
+			r.Hint = "Install it or remove -go-binary to run tests by 'go'"
+		}
+	}
+	return r
+}
+
+// checkGoTool checks the tool of "go tool" is available. status is the status
+// if it's not, whose impact is described by impact.
+func checkGoTool(tool, status, impact string) doctorResult {
+	r := doctorResult{Name: "go tool " + tool}
+	out, err := exec.Command("go", "tool", "-n", tool).Output()
+	if err != nil {
+		r.Status, r.Msg = status, fmt.Sprintf("not available (%v): %s", err, impact)
+		r.Hint = "Reinstall Go, since the distribution may be incomplete"
+		return r
+	}
+	r.Status, r.Msg = doctorOK, strings.TrimSpace(string(out))
+	return r
+}
+
+// checkCovdata checks binary coverage data is supported. It's only needed by
+// some features, so it only warns.
+func checkCovdata() doctorResult {
+	impact := "-native-merge, -covdir, -subprocess-coverage, build and collect need it"
+	if !goSupportsCovdata() {
+		return doctorResult{
+			Status: doctorWarn,
+			Name:   "go tool covdata",
+			Msg:    "binary coverage data requires Go 1.20 or later: " + impact,
+			Hint:   "Upgrade Go to use these features",
+		}
+	}
+	return checkGoTool("covdata", doctorWarn, impact)
+}
+
+// checkTempDir checks the temporary directory, where profiles of packages are
+// written, is writable.
+func checkTempDir() doctorResult {
+	r := doctorResult{Name: "temporary directory"}
+	f, err := ioutil.TempFile("", "goverage-doctor")
+	if err != nil {
+		r.Status, r.Msg = doctorFail, fmt.Sprintf("%s is not writable: %v", os.TempDir(), err)
+		r.Hint = "Set TMPDIR (TMP on Windows) to a writable directory"
+		return r
+	}
+	f.Close()
+	os.Remove(f.Name())
+	r.Status, r.Msg = doctorOK, os.TempDir()
+	return r
+}
+
+// checkModuleMode reports whether goverage runs in module mode or GOPATH mode,
+// and warns if packages are not found in either.
+func checkModuleMode() doctorResult {
+	r := doctorResult{Name: "module mode"}
+	out, err := exec.Command("go", "env", "GOMOD", "GO111MODULE", "GOPATH").Output()
+	if err != nil {
+		r.Status, r.Msg = doctorFail, fmt.Sprintf("cannot run 'go env': %v", err)
+		return r
+	}
+	env := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	for len(env) < 3 {
+		env = append(env, "")
+	}
+	gomod, gomodule, gopath := env[0], env[1], env[2]
+	switch {
+	case gomod != "" && gomod != os.DevNull:
+		r.Status, r.Msg = doctorOK, "module mode with "+gomod
+	case gomodule == "off":
+		r.Status, r.Msg = doctorOK, "GOPATH mode with GOPATH="+gopath
+		if wd, err := os.Getwd(); err == nil && !inGOPATH(wd, gopath) {
+			r.Status, r.Msg = doctorWarn, fmt.Sprintf("GOPATH mode, but %s is outside of GOPATH=%s", wd, gopath)
+			r.Hint = "Packages get local import paths like _/path/to/pkg. Move the repository under $GOPATH/src or add go.mod"
+		}
+	default:
+		r.Status, r.Msg = doctorWarn, "module mode without go.mod"
+		r.Hint = "Run goverage in a module, create one by 'go mod init', or set GO111MODULE=off for GOPATH mode"
+	}
+	return r
+}
+
+// inGOPATH reports whether dir is in src of any entry of gopath.
+func inGOPATH(dir, gopath string) bool {
+	for _, p := range strings.Split(gopath, string(os.PathListSeparator)) {
+		if _, ok := relPath(p+string(os.PathSeparator)+"src", dir); ok && p != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFlags checks conflicts of the given goverage flags.
+func checkFlags() doctorResult {
+	r := doctorResult{Name: "flags", Status: doctorOK, Msg: "no conflicts"}
+	if err := checkFlagConflicts(covermode); err != nil {
+		r.Status, r.Msg = doctorFail, err.Error()
+		r.Hint = "Remove one of the conflicting flags"
+		return r
+	}
+	if noNetwork && dockerImage == "" {
+		if err := checkNetworkSandbox(); err != nil {
+			r.Status, r.Msg = doctorFail, fmt.Sprintf("-no-network: %v", err)
+			r.Hint = "Use -docker to run tests without network in containers"
+		}
+	}
+	return r
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteDoctor(t *testing.T) {
+	var buf bytes.Buffer
+	failed := writeDoctor(&buf, []doctorResult{
+		{Status: doctorOK, Name: "go toolchain", Msg: "go1.22.1", Hint: "unused"},
+		{Status: doctorWarn, Name: "module mode", Msg: "module mode without go.mod", Hint: "Run goverage in a module"},
+		{Status: doctorFail, Name: "flags", Msg: "conflict", Hint: "Remove one"},
+	})
+	if failed != 1 {
+		t.Errorf("got %d failed, want 1", failed)
+	}
+	want := "ok\tgo toolchain: go1.22.1\n" +
+		"WARN\tmodule mode: module mode without go.mod\n\tRun goverage in a module\n" +
+		"FAIL\tflags: conflict\n\tRemove one\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestCheckTempDir(t *testing.T) {
+	if r := checkTempDir(); r.Status != doctorOK {
+		t.Errorf("got %+v", r)
+	}
+}
+
+func TestCheckFlags(t *testing.T) {
+	defer func(c, p bool) { useCache, impact = c, p }(useCache, impact)
+	useCache, impact = false, false
+	if r := checkFlags(); r.Status != doctorOK {
+		t.Errorf("got %+v", r)
+	}
+	useCache, impact = true, true
+	if r := checkFlags(); r.Status != doctorFail || r.Hint == "" {
+		t.Errorf("got %+v for conflicting flags", r)
+	}
+}
+
+func TestInGOPATH(t *testing.T) {
+	gopath := filepath.Join(os.TempDir(), "gopath1") + string(os.PathListSeparator) + filepath.Join(os.TempDir(), "gopath2")
+	if !inGOPATH(filepath.Join(os.TempDir(), "gopath2", "src", "example.com", "a"), gopath) {
+		t.Error("want a directory in the second GOPATH entry to be in GOPATH")
+	}
+	if inGOPATH(filepath.Join(os.TempDir(), "gopath1", "pkg"), gopath) {
+		t.Error("want a directory outside of src not to be in GOPATH")
+	}
+}
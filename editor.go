@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/cover"
+)
+
+// editorReport is the output of "goverage report -editor-json", a compact
+// document for editor plugins to render uncovered code.
+type editorReport struct {
+	Mode string `json:"mode"`
+	// Root is the root directory which paths of Files are relative to.
+	Root string `json:"root"`
+	// Revision is the git commit of the working tree when the report was
+	// generated. It's empty outside of git repositories.
+	Revision string `json:"revision,omitempty"`
+	// Dirty is true if the working tree had uncommitted changes, in which
+	// case ranges may not match Revision.
+	Dirty bool `json:"dirty,omitempty"`
+	// Files maps a slash-separated path to uncovered ranges in the file.
+	Files map[string][]editorRange `json:"files"`
+}
+
+// editorRange is a range of uncovered code. Positions are 1-based [line,
+// column] pairs and End is exclusive.
+type editorRange struct {
+	Start [2]int `json:"start"`
+	End   [2]int `json:"end"`
+	// Statements is the number of uncovered statements in the range.
+	Statements int `json:"statements"`
+}
+
+// writeEditorJSON writes uncovered regions of profiles with paths relative to
+// root.
+func writeEditorJSON(w io.Writer, profiles []*cover.Profile, resolve func(string) (string, error), root, revision string, dirty bool) error {
+	report := editorReport{Root: root, Revision: revision, Dirty: dirty, Files: make(map[string][]editorRange)}
+	for _, p := range profiles {
+		report.Mode = p.Mode
+		filename, err := resolve(p.FileName)
+		if err != nil {
+			log.Printf("skip %s: %v", p.FileName, err)
+			continue
+		}
+		name := filepath.ToSlash(filename)
+		if rel, ok := relPath(root, filename); ok {
+			name = rel
+		}
+		ranges := []editorRange{}
+		for _, r := range uncoveredRegions(p) {
+			ranges = append(ranges, editorRange{
+				Start:      [2]int{r.StartLine, r.StartCol},
+				End:        [2]int{r.EndLine, r.EndCol},
+				Statements: r.NumStmt,
+			})
+		}
+		report.Files[name] = ranges
+	}
+	return json.NewEncoder(w).Encode(report)
+}
+
+// gitRevision returns the commit of HEAD and whether the working tree has
+// uncommitted changes in the repository at root.
+func gitRevision(root string) (revision string, dirty bool, err error) {
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil {
+		return "", false, err
+	}
+	cmd = exec.Command("git", "status", "--porcelain", "--untracked-files=no")
+	cmd.Dir = root
+	status, err := cmd.Output()
+	if err != nil {
+		return "", false, err
+	}
+	return strings.TrimSpace(string(out)), len(status) > 0, nil
+}
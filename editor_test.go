@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestWriteEditorJSON(t *testing.T) {
+	profiles := []*cover.Profile{
+		{FileName: "example.com/app/store/user.go", Mode: "set", Blocks: []cover.ProfileBlock{
+			{StartLine: 3, StartCol: 2, EndLine: 5, EndCol: 3, NumStmt: 2, Count: 0},
+			{StartLine: 6, StartCol: 2, EndLine: 7, EndCol: 3, NumStmt: 1, Count: 1},
+		}},
+		{FileName: "example.com/app/main.go", Mode: "set", Blocks: []cover.ProfileBlock{
+			{StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 2, NumStmt: 1, Count: 1},
+		}},
+	}
+	resolve := func(name string) (string, error) { return "/repo/" + name[len("example.com/app/"):], nil }
+	var buf bytes.Buffer
+	if err := writeEditorJSON(&buf, profiles, resolve, "/repo", "0123abc", true); err != nil {
+		t.Fatal(err)
+	}
+	var got editorReport
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := editorReport{
+		Mode:     "set",
+		Root:     "/repo",
+		Revision: "0123abc",
+		Dirty:    true,
+		Files: map[string][]editorRange{
+			"store/user.go": {{Start: [2]int{3, 2}, End: [2]int{5, 3}, Statements: 2}},
+			"main.go":       {},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
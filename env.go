@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// envVars is environment variables of -env. It implements flag.Value to be
+// given several times as "KEY=VALUE".
+type envVars []string
+
+func (e *envVars) String() string {
+	return strings.Join(*e, ",")
+}
+
+func (e *envVars) Set(s string) error {
+	if i := strings.Index(s, "="); i <= 0 {
+		return fmt.Errorf("invalid environment variable %q: must be KEY=VALUE", s)
+	}
+	*e = append(*e, s)
+	return nil
+}
+
+// redacted returns the variables with their values replaced by "***".
+func (e *envVars) redacted() string {
+	keys := make([]string, len(*e))
+	for i, kv := range *e {
+		keys[i] = kv[:strings.Index(kv, "=")] + "=***"
+	}
+	return strings.Join(keys, ",")
+}
+
+// setenv sets the variables in the environment of goverage, so that every
+// command run by goverage inherits them.
+func (e envVars) setenv() error {
+	for _, kv := range e {
+		i := strings.Index(kv, "=")
+		if err := os.Setenv(kv[:i], kv[i+1:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// testEnv returns environment variables to add to "go test" of the package:
+// ones of the config followed by ones of -env, which take precedence.
+func testEnv(cfg *config, p *listPackage) []string {
+	return append(cfg.env(p), testEnvVars...)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestEnvVars(t *testing.T) {
+	var e envVars
+	for _, s := range []string{"A=1", "B=x=y", "C="} {
+		if err := e.Set(s); err != nil {
+			t.Fatalf("Set(%q): %v", s, err)
+		}
+	}
+	for _, s := range []string{"A", "=1", ""} {
+		if err := e.Set(s); err == nil {
+			t.Errorf("Set(%q): want an error", s)
+		}
+	}
+	if want := (envVars{"A=1", "B=x=y", "C="}); !reflect.DeepEqual(e, want) {
+		t.Errorf("got %v, want %v", e, want)
+	}
+	defer os.Unsetenv("GOVERAGE_TEST_ENV")
+	if err := (envVars{"GOVERAGE_TEST_ENV=a=b"}).setenv(); err != nil {
+		t.Fatal(err)
+	}
+	if got := os.Getenv("GOVERAGE_TEST_ENV"); got != "a=b" {
+		t.Errorf("got %q, want %q", got, "a=b")
+	}
+}
+
+func TestTestEnv(t *testing.T) {
+	defer func(e envVars) { testEnvVars = e }(testEnvVars)
+	testEnvVars = envVars{"DB=test"}
+	cfg := &config{Packages: []packageConfig{{Pattern: "...", Env: map[string]string{"DB": "config", "X": "1"}}}}
+	got := testEnv(cfg, &listPackage{ImportPath: "a"})
+	if want := []string{"DB=config", "X=1", "DB=test"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
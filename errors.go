@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Sentinel errors to check the kind of an error returned by goverage with
+// errors.Is. Use errors.As with the corresponding error type to get details.
+var (
+	ErrBuild        = errors.New("build failed")
+	ErrTestFailure  = errors.New("test failed")
+	ErrThreshold    = errors.New("coverage below threshold")
+	ErrProfileParse = errors.New("cannot parse cover profile")
+)
+
+// ExitError is an error with an explicit exit status.
+type ExitError struct {
+	Msg  string
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return e.Msg
+}
+
+// BuildError is returned when some packages or their tests failed to build.
+type BuildError struct {
+	Packages []string
+}
+
+func (e *BuildError) Error() string {
+	return fmt.Sprintf("build failed: %s", strings.Join(e.Packages, ", "))
+}
+
+func (e *BuildError) Is(target error) bool { return target == ErrBuild }
+
+// TestFailure is returned when "go test" failed for some packages.
+type TestFailure struct {
+	Packages []string
+}
+
+func (e *TestFailure) Error() string {
+	return fmt.Sprintf("test failed: %s", strings.Join(e.Packages, ", "))
+}
+
+func (e *TestFailure) Is(target error) bool { return target == ErrTestFailure }
+
+// ThresholdError is returned when coverage of Target is below Threshold.
+// Coverage and Threshold are percentages.
+type ThresholdError struct {
+	Target    string
+	Coverage  float64
+	Threshold float64
+}
+
+func (e *ThresholdError) Error() string {
+	return fmt.Sprintf("coverage of %s is %.1f%%, below threshold %.1f%%", e.Target, e.Coverage, e.Threshold)
+}
+
+func (e *ThresholdError) Is(target error) bool { return target == ErrThreshold }
+
+// CoverageDecreaseError is returned when the total coverage decreased from
+// Baseline by more than MaxDecrease percentage points.
+type CoverageDecreaseError struct {
+	Coverage    float64
+	Baseline    float64
+	MaxDecrease float64
+}
+
+func (e *CoverageDecreaseError) Error() string {
+	return fmt.Sprintf("coverage decreased from %.1f%% to %.1f%% by %.2f points, more than %.2f", e.Baseline, e.Coverage, e.Baseline-e.Coverage, e.MaxDecrease)
+}
+
+func (e *CoverageDecreaseError) Is(target error) bool { return target == ErrThreshold }
+
+// ProfileParseError is returned when a cover profile cannot be parsed.
+type ProfileParseError struct {
+	File string
+	Err  error
+}
+
+func (e *ProfileParseError) Error() string {
+	return fmt.Sprintf("cannot parse cover profile %s: %v", e.File, e.Err)
+}
+
+func (e *ProfileParseError) Unwrap() error { return e.Err }
+
+func (e *ProfileParseError) Is(target error) bool { return target == ErrProfileParse }
+
+// exitStatus returns exit status of goverage for the error returned by run.
+func exitStatus(err error) int {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return 1
+}
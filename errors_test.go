@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorsIsAs(t *testing.T) {
+	tests := []struct {
+		err    error
+		target error
+	}{
+		{&BuildError{Packages: []string{"a"}}, ErrBuild},
+		{&TestFailure{Packages: []string{"a"}}, ErrTestFailure},
+		{&ThresholdError{Target: "total", Coverage: 10, Threshold: 80}, ErrThreshold},
+		{&CoverageDecreaseError{Coverage: 79, Baseline: 80, MaxDecrease: 0.5}, ErrThreshold},
+		{&ProfileParseError{File: "c.out", Err: errors.New("bad mode line")}, ErrProfileParse},
+	}
+	for _, tt := range tests {
+		wrapped := fmt.Errorf("wrapped: %w", tt.err)
+		if !errors.Is(wrapped, tt.target) {
+			t.Errorf("errors.Is(%v, %v) = false, want true", wrapped, tt.target)
+		}
+		if errors.Is(wrapped, ErrBuild) != (tt.target == ErrBuild) {
+			t.Errorf("errors.Is(%v, ErrBuild) = %v", wrapped, !(tt.target == ErrBuild))
+		}
+		if got := exitStatus(wrapped); got != 1 {
+			t.Errorf("exitStatus(%v) = %d, want 1", wrapped, got)
+		}
+	}
+	var perr *ProfileParseError
+	if err := fmt.Errorf("wrapped: %w", &ProfileParseError{File: "c.out"}); !errors.As(err, &perr) || perr.File != "c.out" {
+		t.Errorf("errors.As failed for ProfileParseError: %v", err)
+	}
+	if got := exitStatus(&ExitError{Code: 2}); got != 2 {
+		t.Errorf("exitStatus(ExitError{Code: 2}) = %d, want 2", got)
+	}
+}
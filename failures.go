@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// outputTailLines is the number of lines of "go test" output kept in a
+// failure report.
+const outputTailLines = 50
+
+// Failure types of a package. See classifyFailure.
+const (
+	failureBuild   = "build"
+	failureTimeout = "timeout"
+	failurePanic   = "panic"
+	failureTest    = "test"
+	failureUnknown = "unknown"
+)
+
+// failuresReport is the content of the file written by -failures-json.
+type failuresReport struct {
+	vcsInfo
+	Failures []packageFailure `json:"failures"`
+	// Skipped is packages and patterns missing from the profile.
+	Skipped []skippedPackage `json:"skipped"`
+}
+
+// packageFailure describes a package whose "go test" failed.
+type packageFailure struct {
+	Package    string  `json:"package"`
+	ExitStatus int     `json:"exit_status"`
+	Type       string  `json:"type"`
+	OutputTail string  `json:"output_tail"`
+	Duration   float64 `json:"duration_seconds"`
+	// FailedTests is names of failed tests including subtests.
+	FailedTests []string `json:"failed_tests,omitempty"`
+	// Panic is the first panic message such as "panic: runtime error: ...".
+	Panic string `json:"panic,omitempty"`
+}
+
+// writeFailuresJSON writes failed packages in results and skipped packages to
+// filename as JSON. It writes empty lists when all packages succeeded so that
+// consumers can distinguish "no failures" from "goverage did not run".
+func writeFailuresJSON(filename string, results []*packageResult, skipped []skippedPackage, vcs vcsInfo) error {
+	report := failuresReport{vcsInfo: vcs, Failures: []packageFailure{}, Skipped: append([]skippedPackage{}, skipped...)}
+	for _, r := range results {
+		if r.Success {
+			continue
+		}
+		output := r.output()
+		tests, panicMsg := failureDetails(r)
+		report.Failures = append(report.Failures, packageFailure{
+			Package:     r.Pkg,
+			ExitStatus:  r.ExitCode,
+			Type:        classifyFailure(output),
+			OutputTail:  tailLines(output, outputTailLines),
+			Duration:    r.Duration.Seconds(),
+			FailedTests: tests,
+			Panic:       panicMsg,
+		})
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// failLineRe matches a line of a failed test in the output of "go test".
+var failLineRe = regexp.MustCompile(`^\s*--- FAIL: (\S+)`)
+
+// failureDetails returns names of failed tests and the first panic message of
+// the result. They are taken from events of "go test -json" if any, or from
+// the output otherwise.
+func failureDetails(r *packageResult) (tests []string, panicMsg string) {
+	addPanic := func(line string) {
+		if line = strings.TrimSpace(line); panicMsg == "" && strings.HasPrefix(line, "panic: ") {
+			panicMsg = line
+		}
+	}
+	if len(r.Events) > 0 {
+		for _, b := range r.Events {
+			var e struct {
+				Action string
+				Test   string
+				Output string
+			}
+			if json.Unmarshal(b, &e) != nil {
+				continue
+			}
+			switch {
+			case e.Action == "fail" && e.Test != "":
+				tests = append(tests, e.Test)
+			case e.Action == "output":
+				addPanic(e.Output)
+			}
+		}
+		return tests, panicMsg
+	}
+	s := bufio.NewScanner(bytes.NewReader(r.output()))
+	s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for s.Scan() {
+		if m := failLineRe.FindStringSubmatch(s.Text()); m != nil {
+			tests = append(tests, m[1])
+			continue
+		}
+		addPanic(s.Text())
+	}
+	return tests, panicMsg
+}
+
+// writeFailureSummary writes failed packages in results with their failed
+// tests and panics, so that they are found without reading the output.
+func writeFailureSummary(w io.Writer, results []*packageResult) {
+	for _, r := range results {
+		if r.Success {
+			continue
+		}
+		fmt.Fprintf(w, "FAIL\t%s\n", r.Pkg)
+		tests, panicMsg := failureDetails(r)
+		for _, t := range tests {
+			fmt.Fprintf(w, "    %s\n", t)
+		}
+		if panicMsg != "" {
+			fmt.Fprintf(w, "    %s\n", panicMsg)
+		}
+	}
+}
+
+// classifyFailure guesses why "go test" failed from its output.
+func classifyFailure(output []byte) string {
+	switch {
+	case bytes.Contains(output, []byte("[build failed]")),
+		bytes.Contains(output, []byte("[setup failed]")):
+		return failureBuild
+	case bytes.Contains(output, []byte("panic: test timed out")):
+		return failureTimeout
+	case bytes.Contains(output, []byte("panic: ")):
+		return failurePanic
+	case bytes.Contains(output, []byte("--- FAIL")),
+		bytes.HasPrefix(output, []byte("FAIL")),
+		bytes.Contains(output, []byte("\nFAIL")):
+		return failureTest
+	}
+	return failureUnknown
+}
+
+// tailLines returns the last n lines of b.
+func tailLines(b []byte, n int) string {
+	b = bytes.TrimRight(b, "\n")
+	i := len(b)
+	for ; n > 0 && i > 0; n-- {
+		i = bytes.LastIndexByte(b[:i], '\n')
+		if i < 0 {
+			return string(b)
+		}
+	}
+	if i == len(b) {
+		return ""
+	}
+	return string(b[i+1:])
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestClassifyFailure(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"# pkg\n./a.go:1: syntax error\nFAIL\tpkg [build failed]\n", failureBuild},
+		{"panic: test timed out after 1s\n", failureTimeout},
+		{"panic: runtime error: index out of range\n", failurePanic},
+		{"--- FAIL: TestOk (0.00s)\nFAIL\n", failureTest},
+		{"FAIL\tpkg\t0.01s\n", failureTest},
+		{"signal: killed\n", failureUnknown},
+	}
+	for _, tt := range tests {
+		if got := classifyFailure([]byte(tt.output)); got != tt.want {
+			t.Errorf("classifyFailure(%q) = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestTailLines(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"a\nb\nc\n", 2, "b\nc"},
+		{"a\nb\nc", 5, "a\nb\nc"},
+		{"a\nb\nc", 0, ""},
+		{"", 3, ""},
+	}
+	for _, tt := range tests {
+		if got := tailLines([]byte(tt.in), tt.n); got != tt.want {
+			t.Errorf("tailLines(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFailureDetails(t *testing.T) {
+	output := "=== RUN   TestA\n" +
+		"=== RUN   TestA/sub\n" +
+		"--- FAIL: TestA (0.00s)\n" +
+		"    --- FAIL: TestA/sub (0.00s)\n" +
+		"panic: runtime error: index out of range [recovered]\n" +
+		"\tpanic: runtime error: index out of range\n" +
+		"FAIL\texample.com/a\t0.01s\n"
+	events := [][]byte{
+		[]byte(`{"Action":"run","Test":"TestB"}`),
+		[]byte(`{"Action":"output","Test":"TestB","Output":"panic: boom\n"}`),
+		[]byte(`{"Action":"fail","Test":"TestB"}`),
+		[]byte(`{"Action":"fail"}`),
+	}
+	tests := []struct {
+		r         *packageResult
+		wantTests []string
+		wantPanic string
+	}{
+		{&packageResult{Stdout: []byte(output)}, []string{"TestA", "TestA/sub"}, "panic: runtime error: index out of range [recovered]"},
+		{&packageResult{Stdout: []byte("--- FAIL: ignored (0.00s)\n"), Events: events}, []string{"TestB"}, "panic: boom"},
+		{&packageResult{Stderr: []byte("a.go:1:1: syntax error\n")}, nil, ""},
+	}
+	for _, tt := range tests {
+		gotTests, gotPanic := failureDetails(tt.r)
+		if !reflect.DeepEqual(gotTests, tt.wantTests) || gotPanic != tt.wantPanic {
+			t.Errorf("got %q, %q, want %q, %q", gotTests, gotPanic, tt.wantTests, tt.wantPanic)
+		}
+	}
+}
+
+func TestWriteFailureSummary(t *testing.T) {
+	var buf bytes.Buffer
+	writeFailureSummary(&buf, []*packageResult{
+		{Pkg: "example.com/a", Success: true},
+		{Pkg: "example.com/b", Stdout: []byte("--- FAIL: TestB (0.00s)\npanic: boom\nFAIL\n")},
+		{Pkg: "example.com/c", Stderr: []byte("c.go:1:1: syntax error\n")},
+	})
+	want := "FAIL\texample.com/b\n" +
+		"    TestB\n" +
+		"    panic: boom\n" +
+		"FAIL\texample.com/c\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/cover"
+)
+
+// excludeConfig is files excluded from profiles and all reports and summaries
+// derived from them.
+type excludeConfig struct {
+	// Packages are package patterns as packageConfig.Pattern.
+	Packages []string `yaml:"packages"`
+	// Files are globs of files. A glob with a slash matches the slash
+	// separated path relative to the config file directory, and one without
+	// slashes matches the base name (e.g. "*.pb.go").
+	Files []string `yaml:"files"`
+	// Generated excludes files with the "Code generated ... DO NOT EDIT."
+	// comment.
+	Generated bool `yaml:"generated"`
+	// GeneratedDetection is how Generated detects generated files: "header"
+	// (default) or "deep". See generatedReason.
+	GeneratedDetection string `yaml:"generated_detection"`
+	// TestHelpers are package patterns of test support packages, where a
+	// leading "**/" matches any import path prefix (e.g. "**/testutil").
+	// Unlike Packages, they are neither tested nor instrumented for tests of
+	// other packages.
+	TestHelpers []string `yaml:"test_helpers"`
+}
+
+func (e *excludeConfig) empty() bool {
+	return len(e.Packages) == 0 && len(e.Files) == 0 && !e.Generated && len(e.TestHelpers) == 0
+}
+
+// testHelper reports whether the package is a test support package of
+// exclude.test_helpers.
+func (c *config) testHelper(p *listPackage) bool {
+	for _, pattern := range c.Exclude.TestHelpers {
+		if rest := strings.TrimPrefix(pattern, "**/"); rest != pattern {
+			if matchTrailingPattern(rest, p.ImportPath) {
+				return true
+			}
+		} else if c.match(pattern, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchTrailingPattern reports whether name or its trailing part after any
+// slash matches the package pattern.
+func matchTrailingPattern(pattern, name string) bool {
+	for {
+		if matchPattern(pattern, name) {
+			return true
+		}
+		i := strings.Index(name, "/")
+		if i < 0 {
+			return false
+		}
+		name = name[i+1:]
+	}
+}
+
+// withoutTestHelpers returns pkgs without test support packages and the
+// skipped ones.
+func (c *config) withoutTestHelpers(pkgs []*listPackage) ([]*listPackage, []skippedPackage) {
+	if len(c.Exclude.TestHelpers) == 0 {
+		return pkgs, nil
+	}
+	var result []*listPackage
+	var skipped []skippedPackage
+	for _, p := range pkgs {
+		if c.testHelper(p) {
+			skipped = append(skipped, skippedPackage{Package: p.ImportPath, Reason: skipTestHelper})
+			continue
+		}
+		result = append(result, p)
+	}
+	return result, skipped
+}
+
+// filterProfiles returns profiles without files excluded by the config and
+// blocks in regions ignored by directives of their files. All coverage
+// computations share it, so that they agree on what is excluded. Files which
+// cannot be resolved are only matched by import path patterns and globs of
+// base names.
+func (c *config) filterProfiles(profiles []*cover.Profile, resolve func(string) (string, error)) []*cover.Profile {
+	return ignoreDirectiveBlocks(c.excludeProfiles(profiles, resolve), resolve)
+}
+
+// excludeProfiles returns profiles without files excluded by the config. It
+// doesn't apply ignore directives, which are only valid for the current
+// source, e.g. to filter baseline profiles.
+func (c *config) excludeProfiles(profiles []*cover.Profile, resolve func(string) (string, error)) []*cover.Profile {
+	if c.Exclude.empty() {
+		return profiles
+	}
+	result := make([]*cover.Profile, 0, len(profiles))
+	for _, p := range profiles {
+		if !c.excluded(p.FileName, resolve) {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// excluded reports whether the file in a profile is excluded.
+func (c *config) excluded(fileName string, resolve func(string) (string, error)) bool {
+	for _, pattern := range c.Exclude.Files {
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, path.Base(filepath.ToSlash(fileName))); ok {
+				return true
+			}
+		}
+	}
+	filename, err := resolve(fileName)
+	if err != nil {
+		filename = ""
+	}
+	pkg := &listPackage{ImportPath: path.Dir(fileName)}
+	if filename != "" {
+		pkg.Dir = filepath.Dir(filename)
+	}
+	for _, pattern := range c.Exclude.Packages {
+		if c.match(pattern, pkg) {
+			return true
+		}
+	}
+	if c.testHelper(pkg) {
+		return true
+	}
+	if filename == "" {
+		return false
+	}
+	if rel, ok := relPath(c.dir, filename); ok {
+		for _, pattern := range c.Exclude.Files {
+			if !strings.Contains(pattern, "/") {
+				continue
+			}
+			if ok, _ := path.Match(strings.TrimPrefix(pattern, "./"), rel); ok {
+				return true
+			}
+		}
+	}
+	if c.Exclude.Generated {
+		detector, _, err := c.generatedReason(filename)
+		if err != nil {
+			log.Printf("cannot check whether %s is generated: %v", fileName, err)
+		}
+		return detector != ""
+	}
+	return false
+}
+
+// generatedRe matches the comment of generated files. See
+// https://golang.org/s/generatedcode.
+var generatedRe = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// isGenerated reports whether the Go file has the comment of generated files
+// before the package clause.
+func isGenerated(filename string) (bool, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if generatedRe.MatchString(line) {
+			return true, nil
+		}
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+	}
+	return false, s.Err()
+}
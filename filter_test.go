@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestFilterProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-filter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"main.go":                "package main\n",
+		"api/api.pb.go":          "package api\n",
+		"store/gen.go":           "// Code generated by stringer; DO NOT EDIT.\n\npackage store\n",
+		"store/user.go":          "package store\n\n// Code generated by hand. DO NOT EDIT.\n",
+		"mocks/store.go":         "package mocks\n",
+		"internal/testutil/t.go": "package testutil\n",
+	}
+	var profiles []*cover.Profile
+	for name, content := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		profiles = append(profiles, &cover.Profile{FileName: "example.com/app/" + name})
+	}
+	profiles = append(profiles, &cover.Profile{FileName: "example.com/unknown/x.pb.go"})
+	resolve := func(name string) (string, error) {
+		return filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(name, "example.com/app/"))), nil
+	}
+	cfg := &config{dir: dir, Exclude: excludeConfig{
+		Packages:  []string{"./internal/testutil/..."},
+		Files:     []string{"*.pb.go", "mocks/*.go"},
+		Generated: true,
+	}}
+	var got []string
+	for _, p := range cfg.filterProfiles(profiles, resolve) {
+		got = append(got, p.FileName)
+	}
+	want := map[string]bool{"example.com/app/main.go": true, "example.com/app/store/user.go": true}
+	if len(got) != len(want) || !want[got[0]] || !want[got[1]] {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := (&config{dir: dir}).filterProfiles(profiles, resolve); !reflect.DeepEqual(got, profiles) {
+		t.Errorf("profiles are filtered without exclusions: %v", got)
+	}
+}
+
+func TestWithoutTestHelpers(t *testing.T) {
+	dir := filepath.FromSlash("/app")
+	cfg := &config{dir: dir, Exclude: excludeConfig{TestHelpers: []string{"**/testutil", "**/internal/testing/...", "./fixtures"}}}
+	var pkgs []*listPackage
+	for _, rel := range []string{"store", "testutil", "store/testutil", "internal/testing", "internal/testing/mock", "fixtures", "mytestutil"} {
+		pkgs = append(pkgs, &listPackage{ImportPath: "example.com/app/" + rel, Dir: filepath.Join(dir, filepath.FromSlash(rel))})
+	}
+	got, skipped := cfg.withoutTestHelpers(pkgs)
+	var kept []string
+	for _, p := range got {
+		kept = append(kept, p.ImportPath)
+	}
+	if want := []string{"example.com/app/store", "example.com/app/mytestutil"}; !reflect.DeepEqual(kept, want) {
+		t.Errorf("got %v, want %v", kept, want)
+	}
+	if len(skipped) != 5 || skipped[0].Package != "example.com/app/testutil" || skipped[0].Reason != skipTestHelper {
+		t.Errorf("unexpected skipped packages: %+v", skipped)
+	}
+	profiles := []*cover.Profile{{FileName: "example.com/app/store/testutil/db.go"}, {FileName: "example.com/app/store/store.go"}}
+	resolve := func(name string) (string, error) {
+		return filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(name, "example.com/app/"))), nil
+	}
+	if got := cfg.filterProfiles(profiles, resolve); len(got) != 1 || got[0].FileName != "example.com/app/store/store.go" {
+		t.Errorf("unexpected profiles: %v", got)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/cover"
+)
+
+// testOutcomes maps names of tests, including subtests, to whether they
+// passed in an attempt. Skipped tests are not included.
+type testOutcomes map[string]bool
+
+// outcomesOf returns outcomes of tests in events of the result.
+func outcomesOf(r *packageResult) testOutcomes {
+	o := make(testOutcomes)
+	for _, b := range r.Events {
+		var e struct {
+			Action string
+			Test   string
+		}
+		if json.Unmarshal(b, &e) != nil || e.Test == "" {
+			continue
+		}
+		switch e.Action {
+		case "pass":
+			o[e.Test] = true
+		case "fail":
+			o[e.Test] = false
+		}
+	}
+	return o
+}
+
+// runAttempts runs tests of a package by run at least minAttempts times, and
+// reruns them up to retries more times while the last attempt fails for
+// other reasons than build errors. The result is the last attempt with
+// profiles and durations of all attempts, and Attempts has outcomes of tests
+// in each attempt.
+func runAttempts(run func() (*packageResult, error), minAttempts, retries int) (*packageResult, error) {
+	if minAttempts < 1 {
+		minAttempts = 1
+	}
+	var attempts []testOutcomes
+	var cpss [][]*cover.Profile
+	var d time.Duration
+	for n := 1; ; n++ {
+		r, err := run()
+		if r == nil {
+			return r, err
+		}
+		attempts = append(attempts, outcomesOf(r))
+		cpss = append(cpss, r.Profiles)
+		d += r.Duration
+		failed := !r.Success && classifyFailure(r.output()) != failureBuild
+		if n < minAttempts || failed && n < minAttempts+retries {
+			if failed {
+				log.Printf("retry tests of package %q (attempt %d)", r.Pkg, n+1)
+			}
+			continue
+		}
+		r.Attempts = attempts
+		r.Profiles = mergeProfiles(cpss)
+		r.Duration = d
+		return r, err
+	}
+}
+
+// flakyReport is the content of the file written by -flaky-report.
+type flakyReport struct {
+	vcsInfo
+	Tests []testStability `json:"tests"`
+}
+
+// testStability is outcomes of a test across attempts.
+type testStability struct {
+	Package string `json:"package"`
+	Test    string `json:"test"`
+	// Pattern has a letter per attempt: "P" for passed, "F" for failed and
+	// "-" for not run, e.g. "FP" for a test which passed on the retry.
+	Pattern string `json:"pattern"`
+	Passed  int    `json:"passed"`
+	Failed  int    `json:"failed"`
+	// Stability is the percentage of passed attempts among attempts the test
+	// ran.
+	Stability float64 `json:"stability"`
+	// Flaky is true if the test both passed and failed.
+	Flaky bool `json:"flaky"`
+}
+
+// testStabilities returns outcomes of tests across attempts of results,
+// sorted by packages and tests.
+func testStabilities(results []*packageResult) []testStability {
+	ss := []testStability{}
+	for _, r := range results {
+		names := make(map[string]bool)
+		for _, o := range r.Attempts {
+			for name := range o {
+				names[name] = true
+			}
+		}
+		for name := range names {
+			s := testStability{Package: r.Pkg, Test: name}
+			var pattern strings.Builder
+			for _, o := range r.Attempts {
+				passed, ok := o[name]
+				switch {
+				case !ok:
+					pattern.WriteByte('-')
+				case passed:
+					pattern.WriteByte('P')
+					s.Passed++
+				default:
+					pattern.WriteByte('F')
+					s.Failed++
+				}
+			}
+			s.Pattern = pattern.String()
+			if n := s.Passed + s.Failed; n > 0 {
+				s.Stability = float64(s.Passed) * 100 / float64(n)
+			}
+			s.Flaky = s.Passed > 0 && s.Failed > 0
+			ss = append(ss, s)
+		}
+	}
+	sort.Slice(ss, func(i, j int) bool {
+		if ss[i].Package != ss[j].Package {
+			return ss[i].Package < ss[j].Package
+		}
+		return ss[i].Test < ss[j].Test
+	})
+	return ss
+}
+
+// writeFlakySummary writes flaky tests among ss with their patterns.
+func writeFlakySummary(w io.Writer, ss []testStability) {
+	for _, s := range ss {
+		if s.Flaky {
+			fmt.Fprintf(w, "FLAKY\t%s\t%s\t%s (%.1f%% passed)\n", s.Package, s.Test, s.Pattern, s.Stability)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestRunAttempts(t *testing.T) {
+	event := func(action, test string) []byte {
+		return []byte(`{"Action":"` + action + `","Test":"` + test + `"}`)
+	}
+	tests := []struct {
+		name        string
+		results     []bool
+		minAttempts int
+		retries     int
+		want        []testOutcomes
+		success     bool
+	}{
+		{name: "pass", results: []bool{true}, retries: 2, want: []testOutcomes{{"TestA": true}}, success: true},
+		{name: "retry", results: []bool{false, false, true}, retries: 2, want: []testOutcomes{{"TestA": false}, {"TestA": false}, {"TestA": true}}, success: true},
+		{name: "give up", results: []bool{false, false, false}, retries: 1, want: []testOutcomes{{"TestA": false}, {"TestA": false}}},
+		{name: "detect", results: []bool{true, false, true}, minAttempts: 3, want: []testOutcomes{{"TestA": true}, {"TestA": false}, {"TestA": true}}, success: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := 0
+			r, err := runAttempts(func() (*packageResult, error) {
+				ok := tt.results[n]
+				n++
+				action := "fail"
+				if ok {
+					action = "pass"
+				}
+				return &packageResult{Pkg: "p", Success: ok, Events: [][]byte{event("run", "TestA"), event(action, "TestA")}}, nil
+			}, tt.minAttempts, tt.retries)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(r.Attempts, tt.want) {
+				t.Errorf("Attempts = %v, want %v", r.Attempts, tt.want)
+			}
+			if r.Success != tt.success {
+				t.Errorf("Success = %v, want %v", r.Success, tt.success)
+			}
+		})
+	}
+}
+
+func TestRunAttempts_build_failure(t *testing.T) {
+	n := 0
+	r, _ := runAttempts(func() (*packageResult, error) {
+		n++
+		return &packageResult{Pkg: "p", Stdout: []byte("FAIL\tp [build failed]\n")}, nil
+	}, 0, 3)
+	if n != 1 {
+		t.Errorf("run %d times, want 1", n)
+	}
+	if len(r.Attempts) != 1 {
+		t.Errorf("got %d attempts, want 1", len(r.Attempts))
+	}
+}
+
+func TestTestStabilities(t *testing.T) {
+	results := []*packageResult{
+		{Pkg: "b", Attempts: []testOutcomes{{"TestX": true}}},
+		{Pkg: "a", Attempts: []testOutcomes{
+			{"TestA": false, "TestB": true},
+			{"TestA": true, "TestB": true, "TestA/sub": true},
+			{"TestA": true, "TestB": true},
+		}},
+		{Pkg: "c"},
+	}
+	got := testStabilities(results)
+	want := []testStability{
+		{Package: "a", Test: "TestA", Pattern: "FPP", Passed: 2, Failed: 1, Stability: 200.0 / 3, Flaky: true},
+		{Package: "a", Test: "TestA/sub", Pattern: "-P-", Passed: 1, Stability: 100},
+		{Package: "a", Test: "TestB", Pattern: "PPP", Passed: 3, Stability: 100},
+		{Package: "b", Test: "TestX", Pattern: "P", Passed: 1, Stability: 100},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("testStabilities() = %+v, want %+v", got, want)
+	}
+	var buf bytes.Buffer
+	writeFlakySummary(&buf, got)
+	if w := "FLAKY\ta\tTestA\tFPP (66.7% passed)\n"; buf.String() != w {
+		t.Errorf("writeFlakySummary() = %q, want %q", buf.String(), w)
+	}
+}
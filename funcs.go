@@ -0,0 +1,94 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/cover"
+)
+
+// funcExtent is the position and statement coverage of a function in a
+// source file.
+type funcExtent struct {
+	Name      string
+	StartLine int
+	StartCol  int
+	EndLine   int
+	EndCol    int
+	Covered   int
+	Total     int
+}
+
+// funcExtents returns functions in the source file with statement coverage of
+// them in the profile of the file, in order of appearance. Methods are named
+// like "T.Method" and "(*T).Method".
+func funcExtents(filename string, p *cover.Profile) ([]*funcExtent, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	var funcs []*funcExtent
+	ast.Inspect(f, func(n ast.Node) bool {
+		fn, ok := n.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			return true
+		}
+		start, end := fset.Position(fn.Pos()), fset.Position(fn.End())
+		funcs = append(funcs, &funcExtent{
+			Name:      funcName(fn),
+			StartLine: start.Line,
+			StartCol:  start.Column,
+			EndLine:   end.Line,
+			EndCol:    end.Column,
+		})
+		return false
+	})
+	for _, fn := range funcs {
+		fn.count(p.Blocks)
+	}
+	return funcs, nil
+}
+
+// count adds statements of blocks in the extent to Covered and Total.
+func (fn *funcExtent) count(blocks []cover.ProfileBlock) {
+	for _, b := range blocks {
+		if !fn.contains(b) {
+			continue
+		}
+		fn.Total += b.NumStmt
+		if b.Count > 0 {
+			fn.Covered += b.NumStmt
+		}
+	}
+}
+
+// contains reports whether the block is in the function.
+func (fn *funcExtent) contains(b cover.ProfileBlock) bool {
+	afterStart := b.StartLine > fn.StartLine || b.StartLine == fn.StartLine && b.StartCol >= fn.StartCol
+	beforeEnd := b.EndLine < fn.EndLine || b.EndLine == fn.EndLine && b.EndCol <= fn.EndCol
+	return afterStart && beforeEnd
+}
+
+func funcName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+	typ := fn.Recv.List[0].Type
+	ptr := false
+	if star, ok := typ.(*ast.StarExpr); ok {
+		ptr = true
+		typ = star.X
+	}
+	if index, ok := typ.(*ast.IndexExpr); ok {
+		// Drop the type parameter of a generic type.
+		typ = index.X
+	}
+	name := types.ExprString(typ)
+	if ptr {
+		return "(*" + name + ")." + fn.Name.Name
+	}
+	return name + "." + fn.Name.Name
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+// funcsTestSource is a source file used by tests of funcExtents.
+const funcsTestSource = `package a
+
+func F() int {
+	return 1
+}
+
+type T struct{}
+
+func (t *T) M() {
+	if true {
+		println()
+	}
+}
+
+func (T) N() {}
+`
+
+func writeFuncsTestSource(t *testing.T) (filename string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "goverage-funcs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename = filepath.Join(dir, "a.go")
+	if err := ioutil.WriteFile(filename, []byte(funcsTestSource), 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return filename, func() { os.RemoveAll(dir) }
+}
+
+func TestFuncExtents(t *testing.T) {
+	filename, cleanup := writeFuncsTestSource(t)
+	defer cleanup()
+	p := &cover.Profile{Blocks: []cover.ProfileBlock{
+		{StartLine: 3, StartCol: 14, EndLine: 5, EndCol: 2, NumStmt: 1, Count: 1},
+		{StartLine: 9, StartCol: 17, EndLine: 10, EndCol: 9, NumStmt: 1, Count: 1},
+		{StartLine: 10, StartCol: 9, EndLine: 12, EndCol: 3, NumStmt: 1, Count: 0},
+	}}
+	funcs, err := funcExtents(filename, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []funcExtent{
+		{Name: "F", StartLine: 3, StartCol: 1, EndLine: 5, EndCol: 2, Covered: 1, Total: 1},
+		{Name: "(*T).M", StartLine: 9, StartCol: 1, EndLine: 13, EndCol: 2, Covered: 1, Total: 2},
+		{Name: "T.N", StartLine: 15, StartCol: 1, EndLine: 15, EndCol: 16},
+	}
+	if len(funcs) != len(want) {
+		t.Fatalf("got %d funcs, want %d", len(funcs), len(want))
+	}
+	for i, fn := range funcs {
+		if *fn != want[i] {
+			t.Errorf("got %+v, want %+v", *fn, want[i])
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/cover"
+)
+
+// Values of exclude.generated_detection.
+const (
+	// generatedHeader detects generated files only by the standard comment.
+	// It's the default.
+	generatedHeader = "header"
+	// generatedDeep also detects files by non-standard generated comments
+	// and outputs of //go:generate directives.
+	generatedDeep = "deep"
+)
+
+// Detectors of generated files in the report of -generated-report.
+const (
+	detectHeader     = "header"
+	detectComment    = "comment"
+	detectGoGenerate = "go_generate"
+)
+
+// generatedFile is a file classified as generated with the reason.
+type generatedFile struct {
+	File     string `json:"file"`
+	Path     string `json:"path"`
+	Detector string `json:"detector"`
+	Reason   string `json:"reason"`
+}
+
+// generatedOutputFlags are flags of generators whose values are output files,
+// such as -output of stringer and -destination of mockgen.
+var generatedOutputFlags = map[string]bool{"o": true, "out": true, "output": true, "destination": true, "dst": true}
+
+// generatedReason returns the detector and the reason why the Go file is
+// classified as generated. The detector is empty if it's not generated.
+func (c *config) generatedReason(filename string) (detector, reason string, err error) {
+	ok, err := isGenerated(filename)
+	if err != nil {
+		return "", "", err
+	}
+	if ok {
+		return detectHeader, "standard generated comment", nil
+	}
+	if c.Exclude.GeneratedDetection != generatedDeep {
+		return "", "", nil
+	}
+	comment, err := generatedComment(filename)
+	if err != nil {
+		return "", "", err
+	}
+	if comment != "" {
+		return detectComment, fmt.Sprintf("comment %q before the package clause", comment), nil
+	}
+	if c.goGenerate == nil {
+		c.goGenerate = make(map[string]map[string]string)
+	}
+	dir := filepath.Dir(filename)
+	outputs, ok := c.goGenerate[dir]
+	if !ok {
+		if outputs, err = goGenerateOutputs(dir); err != nil {
+			return "", "", err
+		}
+		c.goGenerate[dir] = outputs
+	}
+	if src, ok := outputs[filepath.Base(filename)]; ok {
+		return detectGoGenerate, "output of //go:generate in " + src, nil
+	}
+	return "", "", nil
+}
+
+// generatedComment returns the first line of a comment before the package
+// clause of the Go file which says it's generated but not in the standard
+// form, such as "// Autogenerated by protoc. DO NOT EDIT" or a comment after
+// build constraints, or an empty string if there is no such comment. The
+// package doc and comments after it are not headers of generators.
+func generatedComment(filename string) (string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), filename, nil, parser.PackageClauseOnly|parser.ParseComments)
+	if err != nil {
+		return "", err
+	}
+	end := f.Package
+	if f.Doc != nil {
+		end = f.Doc.Pos()
+	}
+	for _, g := range f.Comments {
+		if g.Pos() >= end {
+			break
+		}
+		text := g.Text()
+		lower := strings.ToLower(text)
+		if strings.Contains(lower, "do not edit") || strings.Contains(lower, "generated by") ||
+			strings.Contains(lower, "autogenerated") || strings.Contains(lower, "auto-generated") {
+			return strings.TrimSpace(strings.SplitN(text, "\n", 2)[0]), nil
+		}
+	}
+	return "", nil
+}
+
+// goGenerateOutputs returns base names of files in dir written by
+// //go:generate directives of Go files in dir, mapped to the base names of
+// the files with the directives. Outputs are values of generatedOutputFlags.
+func goGenerateOutputs(dir string) (map[string]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	outputs := make(map[string]string)
+	for _, fi := range files {
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), ".go") {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			line := strings.TrimSpace(s.Text())
+			if !strings.HasPrefix(line, "//go:generate ") {
+				continue
+			}
+			for _, out := range directiveOutputs(strings.Fields(strings.TrimPrefix(line, "//go:generate "))) {
+				// Outputs in other directories are not in dir.
+				if filepath.Dir(filepath.Join(dir, out)) == dir {
+					outputs[filepath.Base(out)] = fi.Name()
+				}
+			}
+		}
+		err = s.Err()
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return outputs, nil
+}
+
+// directiveOutputs returns Go files given to generatedOutputFlags in the args
+// of a //go:generate directive, as -flag=value or -flag value.
+func directiveOutputs(args []string) []string {
+	var outputs []string
+	for i, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		value := ""
+		if j := strings.Index(name, "="); j >= 0 {
+			name, value = name[:j], name[j+1:]
+		} else if i+1 < len(args) {
+			value = args[i+1]
+		}
+		value = strings.Trim(value, `"'`)
+		if generatedOutputFlags[name] && strings.HasSuffix(value, ".go") {
+			outputs = append(outputs, filepath.FromSlash(value))
+		}
+	}
+	return outputs
+}
+
+// generatedFiles returns files of profiles classified as generated, sorted by
+// files, for auditing exclusions by exclude.generated. Files which cannot be
+// resolved are skipped.
+func (c *config) generatedFiles(profiles []*cover.Profile, resolve func(string) (string, error)) []generatedFile {
+	files := []generatedFile{}
+	for _, p := range profiles {
+		filename, err := resolve(p.FileName)
+		if err != nil {
+			continue
+		}
+		detector, reason, err := c.generatedReason(filename)
+		if err != nil {
+			log.Printf("cannot check whether %s is generated: %v", p.FileName, err)
+			continue
+		}
+		if detector != "" {
+			files = append(files, generatedFile{File: p.FileName, Path: filename, Detector: detector, Reason: reason})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].File < files[j].File })
+	return files
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestGeneratedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-generated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"kind.go":        "package app\n\n//go:generate stringer -type=Kind -output=kind_string.go\n//go:generate mockgen -destination ../mocks/kind.go . Kind\n",
+		"kind_string.go": "package app\n",
+		"std.go":         "// Code generated by tool. DO NOT EDIT.\n\npackage app\n",
+		"proto.go":       "//go:build linux\n\n// Autogenerated by protoc-gen-foo. Do not edit.\n// Source: a.proto\n\npackage app\n",
+		"main.go":        "// Package app is generated by no one.\npackage app\n\n// DO NOT EDIT after the package clause.\n",
+		"doc.go":         "// Copyright 2020 The App Authors.\n\n// Package app is hand-written. DO NOT EDIT the docs.\npackage app\n",
+	}
+	var profiles []*cover.Profile
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		profiles = append(profiles, &cover.Profile{FileName: "example.com/app/" + name})
+	}
+	resolve := func(name string) (string, error) {
+		return filepath.Join(dir, strings.TrimPrefix(name, "example.com/app/")), nil
+	}
+
+	cfg := &config{dir: dir}
+	got := cfg.generatedFiles(profiles, resolve)
+	want := []generatedFile{
+		{File: "example.com/app/std.go", Path: filepath.Join(dir, "std.go"), Detector: detectHeader, Reason: "standard generated comment"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("header: got %+v, want %+v", got, want)
+	}
+
+	cfg.Exclude.GeneratedDetection = generatedDeep
+	got = cfg.generatedFiles(profiles, resolve)
+	want = []generatedFile{
+		{File: "example.com/app/kind_string.go", Path: filepath.Join(dir, "kind_string.go"), Detector: detectGoGenerate, Reason: "output of //go:generate in kind.go"},
+		{File: "example.com/app/proto.go", Path: filepath.Join(dir, "proto.go"), Detector: detectComment, Reason: `comment "Autogenerated by protoc-gen-foo. Do not edit." before the package clause`},
+		{File: "example.com/app/std.go", Path: filepath.Join(dir, "std.go"), Detector: detectHeader, Reason: "standard generated comment"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("deep: got %+v, want %+v", got, want)
+	}
+}
+
+func TestDirectiveOutputs(t *testing.T) {
+	got := directiveOutputs(strings.Fields(`go run gen.go -o out.go --output="b.go" -v -dst x.txt -destination mocks/m.go`))
+	want := []string{"out.go", "b.go", filepath.FromSlash("mocks/m.go")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/tools/cover"
+)
+
+// gerritRobotID is the robot_id of robot comments by goverage.
+const gerritRobotID = "goverage"
+
+// gerritReview is a ReviewInput of the Gerrit REST API with robot comments,
+// to be posted to /changes/<change>/revisions/<patchset>/review.
+type gerritReview struct {
+	Message       string                               `json:"message"`
+	Tag           string                               `json:"tag"`
+	RobotComments map[string][]gerritRobotCommentInput `json:"robot_comments"`
+}
+
+// gerritRobotCommentInput is a RobotCommentInput of the Gerrit REST API.
+type gerritRobotCommentInput struct {
+	RobotID    string `json:"robot_id"`
+	RobotRunID string `json:"robot_run_id"`
+	Line       int    `json:"line"`
+	Message    string `json:"message"`
+}
+
+// writeGerritReview writes robot comments on uncovered lines of profiles
+// which are changed, in changed of changedLines, with paths relative to
+// root. The message has the patch coverage, the ratio of covered lines among
+// changed lines with statements. change and patchset identify the run.
+func writeGerritReview(w io.Writer, profiles []*cover.Profile, resolve func(string) (string, error), root string, changed map[string]map[int]bool, change, patchset string) error {
+	runID := "goverage"
+	if change != "" {
+		runID += "-" + change
+		if patchset != "" {
+			runID += "-" + patchset
+		}
+	}
+	review := gerritReview{Tag: "autogenerated:goverage", RobotComments: make(map[string][]gerritRobotCommentInput)}
+	var covered, total int
+	for _, p := range profiles {
+		filename, err := resolve(p.FileName)
+		if err != nil {
+			log.Printf("skip %s: %v", p.FileName, err)
+			continue
+		}
+		rel, ok := relPath(root, filename)
+		if !ok {
+			continue
+		}
+		lines := changed[filename]
+		if lines == nil {
+			lines = changed[filepath.Join(root, filepath.FromSlash(rel))]
+		}
+		var uncovered []int
+		for line, count := range lineHits(p) {
+			if !lines[line] {
+				continue
+			}
+			total++
+			if count > 0 {
+				covered++
+			} else {
+				uncovered = append(uncovered, line)
+			}
+		}
+		sort.Ints(uncovered)
+		for _, r := range splitLineRanges(uncovered) {
+			msg := fmt.Sprintf("Changed line %d is not covered by tests.", r[0])
+			if r[1] > r[0] {
+				msg = fmt.Sprintf("Changed lines %d-%d are not covered by tests.", r[0], r[1])
+			}
+			review.RobotComments[rel] = append(review.RobotComments[rel], gerritRobotCommentInput{
+				RobotID:    gerritRobotID,
+				RobotRunID: runID,
+				Line:       r[0],
+				Message:    msg,
+			})
+		}
+	}
+	patch := coverStats{Covered: covered, Total: total}
+	review.Message = fmt.Sprintf("goverage: patch coverage %.1f%% (%d/%d changed lines with statements)", patch.percent(), covered, total)
+	if total == 0 {
+		review.Message = "goverage: no changed lines with statements"
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(review)
+}
+
+// splitLineRanges returns ranges of consecutive lines in sorted lines as
+// their first and last lines.
+func splitLineRanges(lines []int) [][2]int {
+	var ranges [][2]int
+	for _, l := range lines {
+		if n := len(ranges); n > 0 && ranges[n-1][1] == l-1 {
+			ranges[n-1][1] = l
+			continue
+		}
+		ranges = append(ranges, [2]int{l, l})
+	}
+	return ranges
+}
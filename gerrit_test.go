@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestParseDiffLines(t *testing.T) {
+	diff := `diff --git a/a.go b/a.go
+index 1111111..2222222 100644
+--- a/a.go
++++ b/a.go
+@@ -3 +3,2 @@ func A() {
+-	old()
++	x()
++	y()
+@@ -10,0 +12 @@ func A() {
++	z()
+@@ -20,2 +22,0 @@ func A() {
+-	gone()
+-	gone()
+diff --git a/old.go b/old.go
+deleted file mode 100644
+--- a/old.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package old
+diff --git "a/sp ace.go" "b/sp ace.go"
+--- "a/sp ace.go"
++++ "b/sp ace.go"
+@@ -1 +1 @@
+-package a
++package b
+`
+	got, err := parseDiffLines(strings.NewReader(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[int]bool{
+		"a.go":      {3: true, 4: true, 12: true},
+		"sp ace.go": {1: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDiffLines() = %v, want %v", got, want)
+	}
+}
+
+func TestWriteGerritReview(t *testing.T) {
+	root := filepath.FromSlash("/repo")
+	profiles := []*cover.Profile{
+		{FileName: "example.com/app/a.go", Blocks: []cover.ProfileBlock{
+			{StartLine: 1, EndLine: 2, NumStmt: 1, Count: 1},
+			{StartLine: 3, EndLine: 5, NumStmt: 2, Count: 0},
+			{StartLine: 8, EndLine: 8, NumStmt: 1, Count: 0},
+		}},
+		{FileName: "example.com/app/b.go", Blocks: []cover.ProfileBlock{
+			{StartLine: 1, EndLine: 1, NumStmt: 1, Count: 0},
+		}},
+	}
+	resolve := func(name string) (string, error) {
+		return filepath.Join(root, filepath.Base(name)), nil
+	}
+	changed := map[string]map[int]bool{
+		filepath.Join(root, "a.go"): {2: true, 3: true, 4: true, 8: true, 20: true},
+	}
+	var buf bytes.Buffer
+	if err := writeGerritReview(&buf, profiles, resolve, root, changed, "123", "4"); err != nil {
+		t.Fatal(err)
+	}
+	var got gerritReview
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := gerritReview{
+		Message: "goverage: patch coverage 25.0% (1/4 changed lines with statements)",
+		Tag:     "autogenerated:goverage",
+		RobotComments: map[string][]gerritRobotCommentInput{
+			"a.go": {
+				{RobotID: "goverage", RobotRunID: "goverage-123-4", Line: 3, Message: "Changed lines 3-4 are not covered by tests."},
+				{RobotID: "goverage", RobotRunID: "goverage-123-4", Line: 8, Message: "Changed line 8 is not covered by tests."},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
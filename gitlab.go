@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"golang.org/x/tools/cover"
+)
+
+// gitlabCoberturaFile is the Cobertura report written by -gitlab. Configure it
+// as artifacts:reports:coverage_report of the job.
+const gitlabCoberturaFile = "coverage.xml"
+
+// writeGitLabReport writes a Cobertura report of profiles to
+// gitlabCoberturaFile and the total coverage line to w in the same form as
+// "go test", which the coverage regex of GitLab for Go matches.
+func writeGitLabReport(w io.Writer, profiles []*cover.Profile, resolve func(string) (string, error)) error {
+	source, err := gitRoot()
+	if err != nil {
+		if source, err = os.Getwd(); err != nil {
+			return err
+		}
+	}
+	f, err := os.Create(gitlabCoberturaFile)
+	if err != nil {
+		return err
+	}
+	if err := writeCobertura(f, profiles, resolve, source, time.Now()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	s := statementStats(profiles)
+	_, err = fmt.Fprintf(w, "coverage: %.1f%% of statements\n", s.percent())
+	return err
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// ownedGoFlags is flags of "go test" which goverage sets by itself to collect
+// profiles. They are dropped from GOFLAGS since they break runs.
+var ownedGoFlags = map[string]bool{
+	"c":            true,
+	"o":            true,
+	"json":         true,
+	"coverpkg":     true,
+	"coverprofile": true,
+}
+
+// sharedGoFlags is flags of "go test" which goverage has flags of the same
+// names for. Their values in GOFLAGS are used unless given to goverage.
+var sharedGoFlags = map[string]bool{
+	"covermode": true,
+	"cpu":       true,
+	"parallel":  true,
+	"timeout":   true,
+	"short":     true,
+	"v":         true,
+	"x":         true,
+	"race":      true,
+	"failfast":  true,
+}
+
+// goFlag is a flag in GOFLAGS.
+type goFlag struct {
+	Name  string
+	Value string
+	// Raw is the flag as written in GOFLAGS.
+	Raw string
+}
+
+// parseGOFLAGS parses GOFLAGS, a space separated list of -flag or -flag=value,
+// as the go command does.
+func parseGOFLAGS(s string) ([]goFlag, error) {
+	var flags []goFlag
+	for _, f := range strings.Fields(s) {
+		if !strings.HasPrefix(f, "-") || f == "-" || f == "--" {
+			return nil, fmt.Errorf("invalid GOFLAGS: non-flag %q", f)
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(f, "-"), "-")
+		value := "true"
+		if i := strings.Index(name, "="); i >= 0 {
+			name, value = name[:i], name[i+1:]
+		}
+		flags = append(flags, goFlag{Name: name, Value: value, Raw: f})
+	}
+	return flags, nil
+}
+
+// mergeGOFLAGS merges GOFLAGS into flags of goverage in fs, which have been
+// parsed. Flags given to goverage take precedence over GOFLAGS, which takes
+// precedence over defaults of goverage. Flags set by goverage itself or
+// merged into fs are removed from GOFLAGS for commands run by goverage, and
+// conflicts are warned.
+func mergeGOFLAGS(fs *flag.FlagSet) error {
+	goflags := os.Getenv("GOFLAGS")
+	if goflags == "" {
+		return nil
+	}
+	flags, err := parseGOFLAGS(goflags)
+	if err != nil {
+		return err
+	}
+	set := setFlagNames(fs)
+	var rest []string
+	for _, f := range flags {
+		switch {
+		case ownedGoFlags[f.Name]:
+			log.Printf("ignore %s in GOFLAGS: goverage sets -%s by itself", f.Raw, f.Name)
+		case sharedGoFlags[f.Name] && set[f.Name]:
+			if v := fs.Lookup(f.Name).Value.String(); v != f.Value {
+				log.Printf("ignore %s in GOFLAGS: overridden by -%s=%s", f.Raw, f.Name, v)
+			}
+		case sharedGoFlags[f.Name]:
+			if err := fs.Set(f.Name, f.Value); err != nil {
+				return fmt.Errorf("invalid GOFLAGS: %s: %v", f.Raw, err)
+			}
+		default:
+			rest = append(rest, f.Raw)
+		}
+	}
+	return os.Setenv("GOFLAGS", strings.Join(rest, " "))
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseGOFLAGS(t *testing.T) {
+	got, err := parseGOFLAGS(" -mod=mod  --race -tags=a,b ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []goFlag{
+		{Name: "mod", Value: "mod", Raw: "-mod=mod"},
+		{Name: "race", Value: "true", Raw: "--race"},
+		{Name: "tags", Value: "a,b", Raw: "-tags=a,b"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if _, err := parseGOFLAGS("-mod mod"); err == nil {
+		t.Error("want an error for a non-flag")
+	}
+}
+
+func TestMergeGOFLAGS(t *testing.T) {
+	defer os.Setenv("GOFLAGS", os.Getenv("GOFLAGS"))
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	covermode := fs.String("covermode", "", "")
+	timeout := fs.String("timeout", "", "")
+	race := fs.Bool("race", false, "")
+	if err := fs.Parse([]string{"-timeout", "1m"}); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("GOFLAGS", "-mod=mod -coverprofile=c.out -covermode=atomic -race -timeout=5m -json")
+	if err := mergeGOFLAGS(fs); err != nil {
+		t.Fatal(err)
+	}
+	if *covermode != "atomic" || !*race {
+		t.Errorf("GOFLAGS are not merged: covermode=%q race=%v", *covermode, *race)
+	}
+	if *timeout != "1m" {
+		t.Errorf("timeout = %q, want the value given to goverage", *timeout)
+	}
+	if got, want := os.Getenv("GOFLAGS"), "-mod=mod"; got != want {
+		t.Errorf("GOFLAGS = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/cover"
+)
+
+// harbormasterUnit is a unit test result of harbormaster.sendmessage of
+// Phabricator, which carries line coverage of files.
+type harbormasterUnit struct {
+	Name   string `json:"name"`
+	Result string `json:"result"`
+	// Coverage maps paths relative to the repository to coverage strings.
+	// See harbormasterCoverage.
+	Coverage map[string]string `json:"coverage"`
+}
+
+// writeHarbormaster writes line coverage of profiles as a list of a unit
+// result for harbormaster.sendmessage, whose paths are relative to root. Files
+// outside of root are reported by their absolute paths.
+func writeHarbormaster(w io.Writer, profiles []*cover.Profile, resolve func(string) (string, error), root string) error {
+	unit := harbormasterUnit{Name: "goverage", Result: "pass", Coverage: make(map[string]string)}
+	for _, p := range profiles {
+		filename, err := resolve(p.FileName)
+		if err != nil {
+			log.Printf("skip %s: %v", p.FileName, err)
+			continue
+		}
+		src, err := ioutil.ReadFile(filename)
+		if err != nil {
+			log.Printf("skip %s: %v", p.FileName, err)
+			continue
+		}
+		name := filepath.ToSlash(filename)
+		if rel, ok := relPath(root, filename); ok {
+			name = rel
+		}
+		unit.Coverage[name] = harbormasterCoverage(src, p)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode([]harbormasterUnit{unit})
+}
+
+// harbormasterCoverage returns the coverage string of src, which has a
+// character per line: "C" for covered, "U" for uncovered and "N" for lines
+// without statements.
+func harbormasterCoverage(src []byte, p *cover.Profile) string {
+	n := bytes.Count(src, []byte("\n"))
+	if len(src) > 0 && src[len(src)-1] != '\n' {
+		n++
+	}
+	hits := lineHits(p)
+	var b strings.Builder
+	for line := 1; line <= n; line++ {
+		count, ok := hits[line]
+		switch {
+		case !ok:
+			b.WriteByte('N')
+		case count > 0:
+			b.WriteByte('C')
+		default:
+			b.WriteByte('U')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestHarbormasterCoverage(t *testing.T) {
+	p := &cover.Profile{Blocks: []cover.ProfileBlock{
+		{StartLine: 3, EndLine: 4, NumStmt: 1, Count: 1},
+		{StartLine: 5, EndLine: 5, NumStmt: 1, Count: 0},
+	}}
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{src: "1\n2\n3\n4\n5\n6\n", want: "NNCCUN"},
+		{src: "1\n2\n3\n4\n5\n6", want: "NNCCUN"},
+		{src: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := harbormasterCoverage([]byte(tt.src), p); got != tt.want {
+			t.Errorf("harbormasterCoverage(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestWriteHarbormaster(t *testing.T) {
+	root, err := ioutil.TempDir("", "goverage-harbormaster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if err := os.Mkdir(filepath.Join(root, "a"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "a", "a.go"), []byte("package a\n\nfunc A() {\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profiles := []*cover.Profile{
+		{FileName: "example.com/a/a.go", Blocks: []cover.ProfileBlock{{StartLine: 3, EndLine: 4, NumStmt: 1, Count: 0}}},
+		{FileName: "example.com/b/b.go"},
+	}
+	resolve := func(name string) (string, error) {
+		return filepath.Join(root, filepath.Base(filepath.Dir(name)), filepath.Base(name)), nil
+	}
+	var buf bytes.Buffer
+	if err := writeHarbormaster(&buf, profiles, resolve, root); err != nil {
+		t.Fatal(err)
+	}
+	var got []harbormasterUnit
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []harbormasterUnit{{Name: "goverage", Result: "pass", Coverage: map[string]string{"a/a.go": "NNUU"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// Values of hookConfig.OnFailure.
+const (
+	// hookFail fails the package. It's the default.
+	hookFail = "fail"
+	// hookIgnore logs the failure and continues.
+	hookIgnore = "ignore"
+	// hookSkip skips tests of the package without failing it. It's same as
+	// hookIgnore for post hooks.
+	hookSkip = "skip"
+)
+
+// hookConfig is a command run before or after tests of a package. The command
+// is run by the shell in the directory of the config file with
+// GOVERAGE_PACKAGE, GOVERAGE_PACKAGE_DIR and GOVERAGE_PROFILE environment
+// variables.
+type hookConfig struct {
+	Run       string `yaml:"run"`
+	OnFailure string `yaml:"on_failure"`
+}
+
+// runHooks runs hooks in order in dir with env added to the environment. skip
+// is true if a failed hook requests to skip tests of the package. It returns
+// an error for the first failed hook with hookFail semantics.
+func runHooks(hooks []hookConfig, dir string, env []string) (skip bool, err error) {
+	for _, h := range hooks {
+		herr := shellCommand(h.Run, dir, env).Run()
+		if herr == nil {
+			continue
+		}
+		switch h.OnFailure {
+		case "", hookFail:
+			return false, fmt.Errorf("hook %q failed: %v", h.Run, herr)
+		case hookIgnore:
+			log.Printf("ignore failed hook %q: %v", h.Run, herr)
+		case hookSkip:
+			log.Printf("hook %q failed: %v", h.Run, herr)
+			skip = true
+		default:
+			return false, fmt.Errorf("hook %q failed: %v (unknown on_failure %q)", h.Run, herr, h.OnFailure)
+		}
+	}
+	return skip, nil
+}
+
+// shellCommand returns a command to run the command line by the shell.
+func shellCommand(cmdline, dir string, env []string) *exec.Cmd {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", cmdline)
+	} else {
+		cmd = exec.Command("sh", "-c", cmdline)
+	}
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = testOutput
+	cmd.Stderr = os.Stderr
+	return cmd
+}
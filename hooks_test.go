@@ -0,0 +1,83 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestRunHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks in tests are written for sh")
+	}
+	tests := []struct {
+		hooks    []hookConfig
+		wantSkip bool
+		wantErr  bool
+	}{
+		{hooks: []hookConfig{{Run: "true"}}},
+		{hooks: []hookConfig{{Run: "false"}, {Run: "true"}}, wantErr: true},
+		{hooks: []hookConfig{{Run: "exit 3", OnFailure: hookIgnore}}},
+		{hooks: []hookConfig{{Run: "false", OnFailure: hookSkip}, {Run: "true"}}, wantSkip: true},
+		{hooks: []hookConfig{{Run: "false", OnFailure: "unknown"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		skip, err := runHooks(tt.hooks, ".", nil)
+		if skip != tt.wantSkip || (err != nil) != tt.wantErr {
+			t.Errorf("runHooks(%+v) = %v, %v; want skip=%v, error=%v", tt.hooks, skip, err, tt.wantSkip, tt.wantErr)
+		}
+	}
+}
+
+func TestRunHooks_env(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks in tests are written for sh")
+	}
+	dir, err := ioutil.TempDir("", "goverage-hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	hooks := []hookConfig{{Run: `echo "$GOVERAGE_PACKAGE" > out.txt`}}
+	if _, err := runHooks(hooks, dir, []string{"GOVERAGE_PACKAGE=example.com/a"}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "out.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); got != "example.com/a\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestTestPackage_postHooksAfterFailedPreHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks in tests are written for sh")
+	}
+	dir, err := ioutil.TempDir("", "goverage-hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, onFailure := range []string{hookFail, hookSkip} {
+		os.Remove(filepath.Join(dir, "post.txt"))
+		cfg := &config{dir: dir, Packages: []packageConfig{{
+			Pattern: "example.com/a",
+			Pre:     []hookConfig{{Run: "false", OnFailure: onFailure}},
+			Post:    []hookConfig{{Run: `echo "$GOVERAGE_PACKAGE" > post.txt`}},
+		}}}
+		r, err := testPackage(cfg, &listPackage{ImportPath: "example.com/a"}, nil, "", false)
+		if wantErr := onFailure == hookFail; (err != nil) != wantErr || r == nil {
+			t.Errorf("on_failure %s: got %+v, %v", onFailure, r, err)
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, "post.txt"))
+		if err != nil {
+			t.Errorf("on_failure %s: post hook didn't run: %v", onFailure, err)
+		} else if got := string(b); got != "example.com/a\n" {
+			t.Errorf("on_failure %s: got %q", onFailure, got)
+		}
+	}
+}
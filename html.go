@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"go/scanner"
+	"go/token"
+	"html"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"log"
+	"strconv"
+	"time"
+
+	"golang.org/x/tools/cover"
+)
+
+// htmlReport is the data of the HTML report.
+type htmlReport struct {
+	Revision  string
+	Generated string
+	Total     float64
+	Files     []htmlFile
+}
+
+// htmlFile is a file of the HTML report with its source annotated by
+// coverage.
+type htmlFile struct {
+	Name    string
+	Covered int
+	Total   int
+	Percent float64
+	Source  template.HTML
+}
+
+// writeHTML writes a self-contained HTML report of profiles, which has CSS,
+// JavaScript and snapshots of sources inline, so that it's viewable as a
+// single file even after sources change. revision is the git revision of the
+// sources, if any. Files whose sources are not found are skipped.
+func writeHTML(w io.Writer, profiles []*cover.Profile, resolve func(string) (string, error), revision string) error {
+	total := statementStats(profiles)
+	report := htmlReport{
+		Revision:  revision,
+		Generated: time.Now().UTC().Format(time.RFC3339),
+		Total:     total.percent(),
+	}
+	for _, p := range profiles {
+		filename, err := resolve(p.FileName)
+		if err != nil {
+			log.Printf("skip %s: %v", p.FileName, err)
+			continue
+		}
+		src, err := ioutil.ReadFile(filename)
+		if err != nil {
+			log.Printf("skip %s: %v", p.FileName, err)
+			continue
+		}
+		stats := statementStats([]*cover.Profile{p})
+		report.Files = append(report.Files, htmlFile{
+			Name:    p.FileName,
+			Covered: stats.Covered,
+			Total:   stats.Total,
+			Percent: stats.percent(),
+			Source:  annotateSource(src, p),
+		})
+	}
+	return htmlTemplate.Execute(w, report)
+}
+
+// annotateSource returns the HTML escaped src with blocks of the profile in
+// spans of classes "cov0" (not covered) or "cov1" (covered), whose titles are
+// execution counts. Tokens are highlighted by spans in them. See
+// highlightClasses.
+func annotateSource(src []byte, p *cover.Profile) template.HTML {
+	var buf bytes.Buffer
+	classes := highlightClasses(src)
+	bs := p.Boundaries(src)
+	// cls is the class of the open span of the token.
+	cls := ""
+	for i := 0; i <= len(src); i++ {
+		for ; len(bs) > 0 && bs[0].Offset == i; bs = bs[1:] {
+			// Spans of tokens must be closed to nest in spans of blocks.
+			if cls != "" {
+				buf.WriteString("</span>")
+				cls = ""
+			}
+			switch b := bs[0]; {
+			case !b.Start:
+				buf.WriteString("</span>")
+			case b.Count == 0:
+				buf.WriteString(`<span class="cov0" title="0">`)
+			default:
+				buf.WriteString(`<span class="cov1" title="` + strconv.Itoa(b.Count) + `">`)
+			}
+		}
+		if i == len(src) {
+			break
+		}
+		if classes[i] != cls {
+			if cls != "" {
+				buf.WriteString("</span>")
+			}
+			if cls = classes[i]; cls != "" {
+				buf.WriteString(`<span class="` + cls + `">`)
+			}
+		}
+		buf.WriteString(html.EscapeString(string(src[i : i+1])))
+	}
+	if cls != "" {
+		buf.WriteString("</span>")
+	}
+	return template.HTML(buf.String())
+}
+
+// highlightClasses returns CSS classes of each byte of the Go source src for
+// syntax highlighting: "kw" for keywords, "str" for string and character
+// literals, "num" for numbers and "com" for comments. Other bytes have empty
+// classes.
+func highlightClasses(src []byte) []string {
+	classes := make([]string, len(src))
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, scanner.ScanComments)
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		var cls string
+		switch {
+		case tok.IsKeyword():
+			cls = "kw"
+		case tok == token.STRING || tok == token.CHAR:
+			cls = "str"
+		case tok == token.INT || tok == token.FLOAT || tok == token.IMAG:
+			cls = "num"
+		case tok == token.COMMENT:
+			cls = "com"
+		default:
+			continue
+		}
+		off := file.Offset(pos)
+		n := len(lit)
+		if n == 0 {
+			n = len(tok.String())
+		}
+		for i := off; i < off+n && i < len(src); i++ {
+			classes[i] = cls
+		}
+	}
+	return classes
+}
+
+var htmlTemplate = template.Must(template.New("html").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Coverage {{printf "%.1f" .Total}}%</title>
+<style>
+:root {
+	--bg: #fff; --fg: #24292f; --bar: #24292f; --bar-fg: #eee; --header: #f0f2f4;
+	--cov0-bg: #ffe3e3; --cov0: #b31d28; --cov1-bg: #e3f7e3; --cov1: #1a7f37;
+	--kw: #cf222e; --str: #0a3069; --num: #0550ae; --com: #6e7781; --current: #bf8700;
+}
+:root.dark {
+	--bg: #0d1117; --fg: #c9d1d9; --bar: #161b22; --bar-fg: #c9d1d9; --header: #21262d;
+	--cov0-bg: #4a1d21; --cov0: #ffa198; --cov1-bg: #12361f; --cov1: #7ee787;
+	--kw: #ff7b72; --str: #a5d6ff; --num: #79c0ff; --com: #8b949e; --current: #e3b341;
+}
+body { margin: 0; font-family: -apple-system, "Segoe UI", sans-serif; background: var(--bg); color: var(--fg); }
+#bar { position: sticky; top: 0; z-index: 2; height: 40px; box-sizing: border-box; padding: 8px 16px; background: var(--bar); color: var(--bar-fg); display: flex; align-items: center; gap: 12px; }
+#bar select { font-size: 14px; max-width: 50%; }
+#bar .meta { font-size: 12px; opacity: 0.7; flex: 1; }
+#bar button { font-size: 12px; }
+h2 { position: sticky; top: 40px; z-index: 1; margin: 0; padding: 6px 16px; font-size: 14px; font-family: Menlo, monospace; background: var(--header); border-bottom: 1px solid var(--com); }
+h2 .pct { float: right; font-weight: normal; }
+pre { margin: 0; padding: 16px; font-family: Menlo, monospace; font-size: 13px; line-height: 1.4; tab-size: 4; }
+.cov0 { background: var(--cov0-bg); color: var(--cov0); }
+.cov1 { background: var(--cov1-bg); color: var(--cov1); }
+.cov0.current { outline: 2px solid var(--current); }
+.kw { color: var(--kw); }
+.str { color: var(--str); }
+.num { color: var(--num); }
+.com { color: var(--com); font-style: italic; }
+.cov0 .kw, .cov0 .str, .cov0 .num, .cov0 .com { color: var(--cov0); }
+</style>
+</head>
+<body>
+<div id="bar">
+<select id="files">
+{{range $i, $f := .Files}}<option value="file{{$i}}">{{$f.Name}} ({{printf "%.1f" $f.Percent}}%)</option>
+{{end}}</select>
+<span class="meta">total {{printf "%.1f" .Total}}%{{if .Revision}} at {{.Revision}}{{end}}, generated {{.Generated}}. n/p: next/previous uncovered region</span>
+<button id="theme">Dark mode</button>
+</div>
+{{range $i, $f := .Files}}<section id="file{{$i}}">
+<h2>{{$f.Name}}<span class="pct">{{printf "%.1f" $f.Percent}}% ({{$f.Covered}}/{{$f.Total}} statements)</span></h2>
+<pre>{{$f.Source}}</pre>
+</section>
+{{end}}<script>
+(function() {
+	var root = document.documentElement;
+	var theme = document.getElementById("theme");
+	function setDark(dark) {
+		root.classList.toggle("dark", dark);
+		theme.textContent = dark ? "Light mode" : "Dark mode";
+	}
+	var saved = null;
+	try { saved = localStorage.getItem("goverage-theme"); } catch (e) {}
+	setDark(saved ? saved === "dark" : window.matchMedia && window.matchMedia("(prefers-color-scheme: dark)").matches);
+	theme.addEventListener("click", function() {
+		var dark = !root.classList.contains("dark");
+		setDark(dark);
+		try { localStorage.setItem("goverage-theme", dark ? "dark" : "light"); } catch (e) {}
+	});
+
+	var files = document.getElementById("files");
+	files.addEventListener("change", function() {
+		document.getElementById(files.value).scrollIntoView();
+	});
+
+	var uncovered = document.getElementsByClassName("cov0");
+	var current = -1;
+	function move(d) {
+		if (uncovered.length === 0) {
+			return;
+		}
+		if (current >= 0) {
+			uncovered[current].classList.remove("current");
+		}
+		current = (current + d + uncovered.length) % uncovered.length;
+		var el = uncovered[current];
+		el.classList.add("current");
+		el.scrollIntoView({block: "center"});
+		files.value = el.closest("section").id;
+	}
+	document.addEventListener("keydown", function(e) {
+		if (e.ctrlKey || e.metaKey || e.altKey || e.target.tagName === "SELECT") {
+			return;
+		}
+		if (e.key === "n" || e.key === "j") {
+			move(1);
+		} else if (e.key === "p" || e.key === "k") {
+			move(-1);
+		}
+	});
+})();
+</script>
+</body>
+</html>
+`))
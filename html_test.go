@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestAnnotateSource(t *testing.T) {
+	src := []byte("package p\n\nfunc f(a int) {\n\tif a < 0 {\n\t\tpanic(\"<neg>\")\n\t}\n}\n")
+	p := &cover.Profile{FileName: "p/p.go", Mode: "set", Blocks: []cover.ProfileBlock{
+		{StartLine: 3, StartCol: 15, EndLine: 4, EndCol: 11, NumStmt: 1, Count: 1},
+		{StartLine: 4, StartCol: 11, EndLine: 6, EndCol: 3, NumStmt: 1, Count: 0},
+	}}
+	got := string(annotateSource(src, p))
+	want := `<span class="kw">package</span> p
+
+<span class="kw">func</span> f(a int) <span class="cov1" title="1">{
+	<span class="kw">if</span> a &lt; <span class="num">0</span> </span><span class="cov0" title="0">{
+		panic(<span class="str">&#34;&lt;neg&gt;&#34;</span>)
+	}</span>
+}
+`
+	if got != want {
+		t.Errorf("annotateSource() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestHighlightClasses(t *testing.T) {
+	src := []byte("x := 1 // c\nreturn")
+	got := highlightClasses(src)
+	want := []string{"", "", "", "", "", "num", "", "com", "com", "com", "com", "", "kw", "kw", "kw", "kw", "kw", "kw"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("highlightClasses() = %q, want %q", got, want)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "a.go")
+	if err := ioutil.WriteFile(filename, []byte("package a\n\nfunc A() {\n\tprintln(\"é\")\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profiles := []*cover.Profile{
+		{FileName: "example.com/a/a.go", Mode: "set", Blocks: []cover.ProfileBlock{{StartLine: 3, StartCol: 10, EndLine: 5, EndCol: 2, NumStmt: 1, Count: 1}}},
+		{FileName: "example.com/b/b.go", Mode: "set", Blocks: []cover.ProfileBlock{{StartLine: 1, StartCol: 1, EndLine: 1, EndCol: 2, NumStmt: 1}}},
+	}
+	resolve := func(name string) (string, error) {
+		if name == "example.com/a/a.go" {
+			return filename, nil
+		}
+		return filepath.Join(dir, "missing.go"), nil
+	}
+	var buf bytes.Buffer
+	if err := writeHTML(&buf, profiles, resolve, "abc123"); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, s := range []string{
+		"<title>Coverage 50.0%</title>",
+		`<option value="file0">example.com/a/a.go (100.0%)</option>`,
+		"at abc123",
+		`<span class="cov1" title="1">{` + "\n\tprintln(<span class=\"str\">&#34;é&#34;</span>)\n}</span>",
+		"(1/1 statements)",
+		"<style>",
+		"<script>",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("output does not contain %q:\n%s", s, out)
+		}
+	}
+	if strings.Contains(out, "b.go") {
+		t.Errorf("output contains a file without sources:\n%s", out)
+	}
+}
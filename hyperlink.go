@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// linkFunc returns text which links to target, a file path or a URL.
+type linkFunc func(text, target string) string
+
+// noLink returns text as is.
+func noLink(text, target string) string { return text }
+
+// osc8Link wraps text in an OSC 8 hyperlink escape sequence to target.
+func osc8Link(text, target string) string {
+	if !strings.Contains(target, "://") {
+		target = fileURL(target)
+	}
+	return "\x1b]8;;" + target + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
+
+// terminalLinker returns osc8Link if f is a terminal which likely supports
+// hyperlinks and noLink otherwise. FORCE_HYPERLINK=1 or 0 overrides the
+// detection.
+func terminalLinker(f *os.File, getenv func(string) string) linkFunc {
+	if force := getenv("FORCE_HYPERLINK"); force != "" {
+		if force == "0" {
+			return noLink
+		}
+		return osc8Link
+	}
+	if fi, err := f.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return noLink
+	}
+	if supportsHyperlinks(getenv) {
+		return osc8Link
+	}
+	return noLink
+}
+
+// supportsHyperlinks guesses whether the terminal supports OSC 8 hyperlinks
+// from environment variables set by terminals.
+func supportsHyperlinks(getenv func(string) string) bool {
+	term := getenv("TERM")
+	if term == "dumb" || getenv("CI") != "" {
+		return false
+	}
+	switch getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm", "vscode", "ghostty":
+		return true
+	}
+	if v, err := strconv.Atoi(getenv("VTE_VERSION")); err == nil && v >= 5000 {
+		return true
+	}
+	if getenv("WT_SESSION") != "" || getenv("KONSOLE_VERSION") != "" {
+		return true
+	}
+	for _, t := range []string{"kitty", "alacritty", "foot"} {
+		if strings.Contains(term, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileURL returns the file:// URL of the path.
+func fileURL(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// Windows drive letter.
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestOSC8Link(t *testing.T) {
+	if got, want := osc8Link("a", "https://example.com/a"), "\x1b]8;;https://example.com/a\x1b\\a\x1b]8;;\x1b\\"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := osc8Link("a.go", "/src/a b/a.go"), "\x1b]8;;file:///src/a%20b/a.go\x1b\\a.go\x1b]8;;\x1b\\"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSupportsHyperlinks(t *testing.T) {
+	tests := []struct {
+		env  map[string]string
+		want bool
+	}{
+		{map[string]string{"TERM_PROGRAM": "iTerm.app"}, true},
+		{map[string]string{"VTE_VERSION": "6003"}, true},
+		{map[string]string{"VTE_VERSION": "4000"}, false},
+		{map[string]string{"TERM": "xterm-kitty"}, true},
+		{map[string]string{"TERM": "xterm-256color"}, false},
+		{map[string]string{"TERM_PROGRAM": "vscode", "CI": "true"}, false},
+		{map[string]string{"TERM": "dumb", "WT_SESSION": "x"}, false},
+	}
+	for _, tt := range tests {
+		if got := supportsHyperlinks(func(k string) string { return tt.env[k] }); got != tt.want {
+			t.Errorf("supportsHyperlinks(%v) = %v, want %v", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestTerminalLinker(t *testing.T) {
+	f, err := ioutil.TempFile("", "goverage-link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	env := map[string]string{"TERM_PROGRAM": "iTerm.app"}
+	getenv := func(k string) string { return env[k] }
+	if got := terminalLinker(f, getenv)("a", "/a"); got != "a" {
+		t.Errorf("got %q for a regular file", got)
+	}
+	env["FORCE_HYPERLINK"] = "1"
+	if got := terminalLinker(f, getenv)("a", "/a"); got == "a" {
+		t.Error("FORCE_HYPERLINK=1 should enable links")
+	}
+}
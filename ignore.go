@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/cover"
+)
+
+// Comments of regions of source files ignored by coverage. Blocks entirely
+// between them are removed from profiles as if they were not instrumented.
+const (
+	ignoreStartDirective = "//goverage:ignore-start"
+	ignoreEndDirective   = "//goverage:ignore-end"
+)
+
+// ignoreRegion is a region of a source file between ignore directives, from
+// the line of the start directive to the line of the end one.
+type ignoreRegion struct {
+	StartLine int
+	EndLine   int
+}
+
+// ignoredRegion is a region in the report of -exclusions-report with the
+// number of statements removed by it.
+type ignoredRegion struct {
+	File       string `json:"file"`
+	Path       string `json:"path"`
+	StartLine  int    `json:"start_line"`
+	EndLine    int    `json:"end_line"`
+	Statements int    `json:"statements"`
+}
+
+// ignoreRegions returns regions between ignore directives of the Go file. A
+// start directive without the end ignores the rest of the file. Unpaired
+// end directives are ignored with a warning.
+func ignoreRegions(filename string) ([]ignoreRegion, error) {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Contains(src, []byte(ignoreStartDirective)) {
+		return nil, nil
+	}
+	var regions []ignoreRegion
+	start := 0
+	s := bufio.NewScanner(bytes.NewReader(src))
+	l := 0
+	for s.Scan() {
+		l++
+		switch strings.TrimSpace(s.Text()) {
+		case ignoreStartDirective:
+			if start == 0 {
+				start = l
+			}
+		case ignoreEndDirective:
+			if start == 0 {
+				log.Printf("%s:%d: %s without %s", filename, l, ignoreEndDirective, ignoreStartDirective)
+				continue
+			}
+			regions = append(regions, ignoreRegion{StartLine: start, EndLine: l})
+			start = 0
+		}
+	}
+	if start != 0 {
+		regions = append(regions, ignoreRegion{StartLine: start, EndLine: l})
+	}
+	return regions, s.Err()
+}
+
+// ignoreBlocks returns the profile without blocks entirely in regions, and the
+// number of statements removed by each region. It returns p itself if no
+// blocks are removed.
+func ignoreBlocks(p *cover.Profile, regions []ignoreRegion) (*cover.Profile, []int) {
+	removed := make([]int, len(regions))
+	var blocks []cover.ProfileBlock
+	for _, b := range p.Blocks {
+		in := -1
+		for i, r := range regions {
+			if r.StartLine <= b.StartLine && b.EndLine <= r.EndLine {
+				in = i
+				break
+			}
+		}
+		if in < 0 {
+			blocks = append(blocks, b)
+			continue
+		}
+		removed[in] += b.NumStmt
+	}
+	if len(blocks) == len(p.Blocks) {
+		return p, removed
+	}
+	q := *p
+	q.Blocks = blocks
+	return &q, removed
+}
+
+// fileIgnoreRegions returns ignore regions of the file of the profile, or nil
+// if it cannot be resolved or read.
+func fileIgnoreRegions(p *cover.Profile, resolve func(string) (string, error)) (string, []ignoreRegion) {
+	filename, err := resolve(p.FileName)
+	if err != nil {
+		return "", nil
+	}
+	regions, err := ignoreRegions(filename)
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("cannot read ignore directives of %s: %v", p.FileName, err)
+	}
+	return filename, regions
+}
+
+// ignoreDirectiveBlocks returns profiles without blocks in regions ignored by
+// directives of their files.
+func ignoreDirectiveBlocks(profiles []*cover.Profile, resolve func(string) (string, error)) []*cover.Profile {
+	result := make([]*cover.Profile, 0, len(profiles))
+	for _, p := range profiles {
+		_, regions := fileIgnoreRegions(p, resolve)
+		p, _ = ignoreBlocks(p, regions)
+		result = append(result, p)
+	}
+	return result
+}
+
+// ignoredRegions returns ignore regions of files in profiles, which are not
+// excluded by the config, with statements removed by them, sorted by files
+// and lines, so that reviewers can audit ignored code.
+func (c *config) ignoredRegions(profiles []*cover.Profile, resolve func(string) (string, error)) []ignoredRegion {
+	result := []ignoredRegion{}
+	for _, p := range profiles {
+		if !c.Exclude.empty() && c.excluded(p.FileName, resolve) {
+			continue
+		}
+		filename, regions := fileIgnoreRegions(p, resolve)
+		_, removed := ignoreBlocks(p, regions)
+		for i, r := range regions {
+			result = append(result, ignoredRegion{File: p.FileName, Path: filename, StartLine: r.StartLine, EndLine: r.EndLine, Statements: removed[i]})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].File != result[j].File {
+			return result[i].File < result[j].File
+		}
+		return result[i].StartLine < result[j].StartLine
+	})
+	return result
+}
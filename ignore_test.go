@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+const ignoreSrc = `package app
+
+func f(err error) {
+	//goverage:ignore-start
+	if err != nil {
+		panic(err)
+	}
+	//goverage:ignore-end
+	g()
+}
+
+func g() {
+	//goverage:ignore-start
+	println()
+}
+`
+
+func TestIgnoreRegions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-ignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "app.go")
+	if err := ioutil.WriteFile(filename, []byte(ignoreSrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	regions, err := ignoreRegions(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []ignoreRegion{{4, 8}, {13, 15}}; !reflect.DeepEqual(regions, want) {
+		t.Errorf("got %v, want %v", regions, want)
+	}
+
+	profiles := []*cover.Profile{{FileName: "example.com/app/app.go", Mode: "set", Blocks: []cover.ProfileBlock{
+		{StartLine: 3, StartCol: 19, EndLine: 5, EndCol: 16, NumStmt: 1, Count: 1},
+		{StartLine: 5, StartCol: 16, EndLine: 7, EndCol: 3, NumStmt: 1, Count: 0},
+		{StartLine: 9, StartCol: 2, EndLine: 9, EndCol: 5, NumStmt: 1, Count: 1},
+		{StartLine: 12, StartCol: 10, EndLine: 14, EndCol: 11, NumStmt: 1, Count: 0},
+	}}}
+	resolve := func(name string) (string, error) {
+		return filepath.Join(dir, strings.TrimPrefix(name, "example.com/app/")), nil
+	}
+	cfg := &config{dir: dir}
+	got := cfg.filterProfiles(profiles, resolve)
+	if len(got) != 1 || len(got[0].Blocks) != 3 || got[0].Blocks[1].StartLine != 9 {
+		t.Errorf("unexpected profiles: %+v", got[0])
+	}
+	if len(profiles[0].Blocks) != 4 {
+		t.Error("the original profile is modified")
+	}
+	// Baselines are filtered without directives of the current source.
+	if got := cfg.excludeProfiles(profiles, resolve); len(got) != 1 || len(got[0].Blocks) != 4 {
+		t.Errorf("excludeProfiles() applied ignore directives: %+v", got)
+	}
+	want := []ignoredRegion{
+		{File: "example.com/app/app.go", Path: filename, StartLine: 4, EndLine: 8, Statements: 1},
+		{File: "example.com/app/app.go", Path: filename, StartLine: 13, EndLine: 15, Statements: 0},
+	}
+	if got := cfg.ignoredRegions(profiles, resolve); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// impactFile is the name of the test impact database in the state
+// directory.
+const impactFile = "impact.db"
+
+// impactSchema creates tables of the impact database. Files are the ones in
+// profiles, such as "github.com/me/app/store/user.go". Tests covering no
+// files are in tests only, so that they're affected by their test files.
+const impactSchema = `
+CREATE TABLE IF NOT EXISTS tests (
+	package TEXT NOT NULL,
+	test TEXT NOT NULL,
+	PRIMARY KEY (package, test)
+);
+CREATE TABLE IF NOT EXISTS covered (
+	package TEXT NOT NULL,
+	test TEXT NOT NULL,
+	file TEXT NOT NULL,
+	PRIMARY KEY (package, test, file)
+);
+CREATE INDEX IF NOT EXISTS covered_file ON covered (file);
+`
+
+// impactDB is the SQLite database of packages and their top-level tests with
+// files covered by the tests.
+type impactDB struct {
+	db *sql.DB
+}
+
+// openImpactDB opens the impact database in dir, creating it if it doesn't
+// exist.
+func openImpactDB(dir string) (*impactDB, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	// Wait for other runs of goverage updating the database.
+	db, err := sql.Open("sqlite3", filepath.Join(dir, impactFile)+"?_busy_timeout=10000")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(impactSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("invalid %s: %v", impactFile, err)
+	}
+	return &impactDB{db: db}, nil
+}
+
+func (db *impactDB) Close() error {
+	return db.db.Close()
+}
+
+// update replaces tests of packages run with per-test attribution in results,
+// so that the database is updated incrementally by runs of some packages.
+func (db *impactDB) update(results []*packageResult) error {
+	pkgs := make(map[string]map[string][]string)
+	for _, r := range results {
+		if r.TestProfiles == nil {
+			continue
+		}
+		tests := make(map[string][]string, len(r.TestProfiles))
+		for name, profiles := range r.TestProfiles {
+			var files []string
+			for _, p := range profiles {
+				for _, b := range p.Blocks {
+					if b.Count > 0 {
+						files = append(files, p.FileName)
+						break
+					}
+				}
+			}
+			tests[name] = files
+		}
+		pkgs[r.Pkg] = tests
+	}
+	return db.replace(pkgs)
+}
+
+// replace replaces tests of the packages in a transaction. Rows of other
+// packages are kept as they are.
+func (db *impactDB) replace(pkgs map[string]map[string][]string) (err error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	for pkg, tests := range pkgs {
+		if _, err := tx.Exec("DELETE FROM covered WHERE package = ?", pkg); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM tests WHERE package = ?", pkg); err != nil {
+			return err
+		}
+		for name, files := range tests {
+			if _, err := tx.Exec("INSERT INTO tests (package, test) VALUES (?, ?)", pkg, name); err != nil {
+				return err
+			}
+			for _, f := range files {
+				if _, err := tx.Exec("INSERT OR IGNORE INTO covered (package, test, file) VALUES (?, ?, ?)", pkg, name, f); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// updateImpactDB updates the impact database in dir with per-test
+// attribution of results, if any.
+func updateImpactDB(dir string, results []*packageResult) error {
+	attributed := false
+	for _, r := range results {
+		attributed = attributed || r.TestProfiles != nil
+	}
+	if !attributed {
+		return nil
+	}
+	db, err := openImpactDB(dir)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.update(results)
+}
+
+// loadImpactDB opens the existing impact database in dir for a query by the
+// subcommand.
+func loadImpactDB(dir, subcmd string) (*impactDB, error) {
+	if !isExist(filepath.Join(dir, impactFile)) {
+		return nil, fmt.Errorf("goverage %s: no impact database in %s; run goverage with -impact first", subcmd, dir)
+	}
+	return openImpactDB(dir)
+}
+
+// impactedTest is a test affected by changed files.
+type impactedTest struct {
+	Package string
+	Test    string
+}
+
+// affected returns tests which cover any of files, names in profiles, and
+// all tests of packages in testPkgs, whose test files changed. They are
+// sorted by packages and tests.
+func (db *impactDB) affected(files []string, testPkgs []string) ([]impactedTest, error) {
+	if len(files) == 0 && len(testPkgs) == 0 {
+		return nil, nil
+	}
+	var args []interface{}
+	for _, f := range files {
+		args = append(args, f)
+	}
+	for _, p := range testPkgs {
+		args = append(args, p)
+	}
+	query := "SELECT package, test FROM covered WHERE file IN (" + placeholders(len(files)) + ")" +
+		" UNION SELECT package, test FROM tests WHERE package IN (" + placeholders(len(testPkgs)) + ")" +
+		" ORDER BY package, test"
+	rows, err := db.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []impactedTest
+	for rows.Next() {
+		var t impactedTest
+		if err := rows.Scan(&t.Package, &t.Test); err != nil {
+			return nil, err
+		}
+		result = append(result, t)
+	}
+	return result, rows.Err()
+}
+
+// placeholders returns n comma separated placeholders of a query.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// runImpact prints tests affected by changed files according to the impact
+// database, which is updated by runs with -impact, -per-test or -test-map.
+func runImpact(args []string) error {
+	fs := newFlagSet("impact", "[-state-dir .goverage] [-packages] file...")
+	dir := fs.String("state-dir", defaultStateDir, "Directory of the impact database")
+	pkgsOnly := fs.Bool("packages", false, "Print affected packages instead of tests")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("goverage impact: changed files are required")
+	}
+	*dir = fromModuleRoot(fs, "state-dir", *dir)
+	db, err := loadImpactDB(*dir, "impact")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	tests, _, err := affectedByFiles(db, fs.Args())
+	if err != nil {
+		return err
+	}
+	return writeImpacted(os.Stdout, tests, *pkgsOnly)
+}
+
+// affectedByFiles returns tests in db affected by the changed files, and
+// packages of the changed Go files. Changes of files which cannot be
+// attributed to tests, such as non-Go files, are logged.
+func affectedByFiles(db *impactDB, changed []string) (tests []impactedTest, changedPkgs []string, err error) {
+	var patterns []string
+	for _, f := range changed {
+		abs, err := filepath.Abs(filepath.Dir(f))
+		if err != nil {
+			return nil, nil, err
+		}
+		patterns = append(patterns, abs)
+	}
+	pkgs, err := resolvePkgs(patterns, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	seen := make(map[string]bool)
+	var files, testPkgs []string
+	for _, f := range changed {
+		pkg, ok := packageOfFile(pkgs, f)
+		if !ok || !strings.HasSuffix(f, ".go") {
+			log.Printf("cannot tell tests affected by %s", f)
+			continue
+		}
+		if !seen[pkg] {
+			seen[pkg] = true
+			changedPkgs = append(changedPkgs, pkg)
+		}
+		if strings.HasSuffix(f, "_test.go") {
+			testPkgs = append(testPkgs, pkg)
+			continue
+		}
+		files = append(files, path.Join(pkg, filepath.Base(f)))
+	}
+	tests, err = db.affected(files, testPkgs)
+	return tests, changedPkgs, err
+}
+
+// writeImpacted writes tests as "package<TAB>test" per line, or packages of
+// them if pkgsOnly is true.
+func writeImpacted(w io.Writer, tests []impactedTest, pkgsOnly bool) error {
+	seen := make(map[string]bool)
+	for _, t := range tests {
+		var err error
+		if !pkgsOnly {
+			_, err = fmt.Fprintf(w, "%s\t%s\n", t.Package, t.Test)
+		} else if !seen[t.Package] {
+			seen[t.Package] = true
+			_, err = fmt.Fprintln(w, t.Package)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestImpactDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-impact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if _, err := loadImpactDB(dir, "impact"); err == nil {
+		t.Error("want error for a missing database")
+	}
+	profile := func(name string, count int) *cover.Profile {
+		return &cover.Profile{FileName: name, Mode: "set", Blocks: []cover.ProfileBlock{
+			{StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 2, NumStmt: 1, Count: count},
+		}}
+	}
+	if err := updateImpactDB(dir, []*packageResult{
+		{Pkg: "example.com/a", TestProfiles: map[string][]*cover.Profile{
+			"TestA":   {profile("example.com/a/a.go", 1), profile("example.com/b/b.go", 1)},
+			"TestOld": {profile("example.com/a/a.go", 1)},
+		}},
+		{Pkg: "example.com/b", TestProfiles: map[string][]*cover.Profile{
+			"TestB": {profile("example.com/a/a.go", 0), profile("example.com/b/b.go", 1)},
+		}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Tests of example.com/a are replaced, and ones of example.com/b are kept.
+	if err := updateImpactDB(dir, []*packageResult{
+		{Pkg: "example.com/a", TestProfiles: map[string][]*cover.Profile{
+			"TestA": {profile("example.com/a/a.go", 1), profile("example.com/b/b.go", 1)},
+		}},
+		{Pkg: "example.com/c"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := loadImpactDB(dir, "impact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	all, err := db.affected(nil, []string{"example.com/a", "example.com/b", "example.com/c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []impactedTest{{"example.com/a", "TestA"}, {"example.com/b", "TestB"}}; !reflect.DeepEqual(all, want) {
+		t.Errorf("got %v, want %v", all, want)
+	}
+
+	tests := []struct {
+		files, testPkgs []string
+		want            []impactedTest
+	}{
+		{[]string{"example.com/a/a.go"}, nil, []impactedTest{{"example.com/a", "TestA"}}},
+		{[]string{"example.com/b/b.go"}, nil, []impactedTest{{"example.com/a", "TestA"}, {"example.com/b", "TestB"}}},
+		{nil, []string{"example.com/b"}, []impactedTest{{"example.com/b", "TestB"}}},
+		{[]string{"example.com/c/c.go"}, nil, nil},
+	}
+	for _, tt := range tests {
+		if got, err := db.affected(tt.files, tt.testPkgs); err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("affected(%v, %v) = %v, %v, want %v", tt.files, tt.testPkgs, got, err, tt.want)
+		}
+	}
+}
+
+func TestWriteImpacted(t *testing.T) {
+	tests := []impactedTest{{"example.com/a", "TestA"}, {"example.com/a", "TestB"}, {"example.com/b", "TestC"}}
+	var buf bytes.Buffer
+	if err := writeImpacted(&buf, tests, false); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "example.com/a\tTestA\nexample.com/a\tTestB\nexample.com/b\tTestC\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	buf.Reset()
+	if err := writeImpacted(&buf, tests, true); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "example.com/a\nexample.com/b\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestAffectedByFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-impact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := openImpactDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.replace(map[string]map[string][]string{
+		"github.com/haya14busa/goverage/example/root": {
+			"TestCoveredFromRoot": {"github.com/haya14busa/goverage/example/root/root.go"},
+			"TestCoverSub":        {"github.com/haya14busa/goverage/example/root/root.go", "github.com/haya14busa/goverage/example/root/sub/sub.go"},
+		},
+		"github.com/haya14busa/goverage/example/root/sub": {
+			"TestCoveredFromSub": {"github.com/haya14busa/goverage/example/root/sub/sub.go"},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	tests, changedPkgs, err := affectedByFiles(db, []string{"example/root/sub/sub.go", "example/root/sub/sub_test.go", "example/root/coverage.ok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []impactedTest{
+		{"github.com/haya14busa/goverage/example/root", "TestCoverSub"},
+		{"github.com/haya14busa/goverage/example/root/sub", "TestCoveredFromSub"},
+	}
+	if !reflect.DeepEqual(tests, want) {
+		t.Errorf("got %v, want %v", tests, want)
+	}
+	if want := []string{"github.com/haya14busa/goverage/example/root/sub"}; !reflect.DeepEqual(changedPkgs, want) {
+		t.Errorf("changed packages: got %v, want %v", changedPkgs, want)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/tools/cover"
+)
+
+// istanbulFile is coverage of a file in the Istanbul coverage JSON, such as
+// coverage-final.json of nyc.
+type istanbulFile struct {
+	Path         string                     `json:"path"`
+	StatementMap map[string]istanbulRange   `json:"statementMap"`
+	FnMap        map[string]istanbulFn      `json:"fnMap"`
+	BranchMap    map[string]json.RawMessage `json:"branchMap"`
+	S            map[string]int             `json:"s"`
+	F            map[string]int             `json:"f"`
+	B            map[string][]int           `json:"b"`
+}
+
+// istanbulRange is a range in a source file. Lines are 1-based and columns
+// are 0-based.
+type istanbulRange struct {
+	Start istanbulPos `json:"start"`
+	End   istanbulPos `json:"end"`
+}
+
+type istanbulPos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type istanbulFn struct {
+	Name string        `json:"name"`
+	Decl istanbulRange `json:"decl"`
+	Loc  istanbulRange `json:"loc"`
+	Line int           `json:"line"`
+}
+
+// writeIstanbul writes profiles as the Istanbul coverage JSON keyed by
+// absolute paths of files. Statements are those counted by the cover tool,
+// each with the count of its block, so that percentages match the profile.
+// Functions are counted by their first blocks. Branches are not reported.
+func writeIstanbul(w io.Writer, profiles []*cover.Profile, resolve func(string) (string, error)) error {
+	files := make(map[string]*istanbulFile)
+	for _, p := range profiles {
+		filename, err := resolve(p.FileName)
+		if err != nil {
+			log.Printf("skip %s: %v", p.FileName, err)
+			continue
+		}
+		if filename, err = filepath.Abs(filename); err != nil {
+			return err
+		}
+		extents, err := stmtExtents(filename)
+		if err != nil {
+			log.Printf("skip %s: %v", p.FileName, err)
+			continue
+		}
+		funcs, err := funcExtents(filename, p)
+		if err != nil {
+			log.Printf("skip %s: %v", p.FileName, err)
+			continue
+		}
+		files[filename] = istanbulCoverage(filename, p, extents, funcs)
+	}
+	return json.NewEncoder(w).Encode(files)
+}
+
+// istanbulCoverage returns coverage of the file of the profile p, whose
+// statements are extents and functions are funcs. A statement belongs to the
+// innermost block containing its start. Blocks without statements found in
+// extents, e.g. because the source changed, are reported as statements.
+func istanbulCoverage(filename string, p *cover.Profile, extents [][2]srcPos, funcs []*funcExtent) *istanbulFile {
+	f := &istanbulFile{
+		Path:         filename,
+		StatementMap: make(map[string]istanbulRange),
+		FnMap:        make(map[string]istanbulFn),
+		BranchMap:    make(map[string]json.RawMessage),
+		S:            make(map[string]int),
+		F:            make(map[string]int),
+		B:            make(map[string][]int),
+	}
+	rng := func(start, end srcPos) istanbulRange {
+		return istanbulRange{Start: istanbulPos{start.line, start.col - 1}, End: istanbulPos{end.line, end.col - 1}}
+	}
+	// stmts is indexes of extents in each block.
+	stmts := make([][]int, len(p.Blocks))
+	for i, e := range extents {
+		in := -1
+		for j, b := range p.Blocks {
+			if b.NumStmt > 0 && !e[0].before(blockStart(b)) && e[0].before(blockEnd(b)) && (in < 0 || blockStart(p.Blocks[in]).before(blockStart(b))) {
+				in = j
+			}
+		}
+		if in >= 0 {
+			stmts[in] = append(stmts[in], i)
+		}
+	}
+	n := 0
+	for i, b := range p.Blocks {
+		if b.NumStmt == 0 {
+			continue
+		}
+		if len(stmts[i]) == 0 {
+			f.StatementMap[strconv.Itoa(n)] = rng(blockStart(b), blockEnd(b))
+			f.S[strconv.Itoa(n)] = b.Count
+			n++
+			continue
+		}
+		for _, j := range stmts[i] {
+			f.StatementMap[strconv.Itoa(n)] = rng(extents[j][0], extents[j][1])
+			f.S[strconv.Itoa(n)] = b.Count
+			n++
+		}
+	}
+	for i, fn := range funcs {
+		loc := rng(srcPos{fn.StartLine, fn.StartCol}, srcPos{fn.EndLine, fn.EndCol})
+		f.FnMap[strconv.Itoa(i)] = istanbulFn{Name: fn.Name, Decl: loc, Loc: loc, Line: fn.StartLine}
+		count := 0
+		var first *cover.ProfileBlock
+		for j, b := range p.Blocks {
+			if fn.contains(b) && (first == nil || blockStart(b).before(blockStart(*first))) {
+				first = &p.Blocks[j]
+			}
+		}
+		if first != nil {
+			count = first.Count
+		}
+		f.F[strconv.Itoa(i)] = count
+	}
+	return f
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestWriteIstanbul(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-istanbul")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "a.go")
+	src := "package a\n\nfunc A(x int) int {\n\ty := x\n\tif x > 0 {\n\t\treturn y\n\t}\n\treturn 0\n}\n\nfunc B() {}\n"
+	if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profiles := []*cover.Profile{
+		{FileName: "example.com/a/a.go", Mode: "count", Blocks: []cover.ProfileBlock{
+			{StartLine: 3, StartCol: 20, EndLine: 5, EndCol: 11, NumStmt: 2, Count: 3},
+			{StartLine: 5, StartCol: 11, EndLine: 7, EndCol: 3, NumStmt: 1, Count: 0},
+			{StartLine: 8, StartCol: 2, EndLine: 8, EndCol: 10, NumStmt: 1, Count: 3},
+			{StartLine: 11, StartCol: 11, EndLine: 11, EndCol: 12, NumStmt: 0, Count: 0},
+		}},
+	}
+	var buf bytes.Buffer
+	if err := writeIstanbul(&buf, profiles, func(string) (string, error) { return filename, nil }); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]*istanbulFile
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	f := got[filename]
+	if f == nil {
+		t.Fatalf("no coverage of %s: %s", filename, buf.Bytes())
+	}
+	r := func(l1, c1, l2, c2 int) istanbulRange {
+		return istanbulRange{Start: istanbulPos{l1, c1}, End: istanbulPos{l2, c2}}
+	}
+	wantStmts := map[string]istanbulRange{
+		"0": r(4, 1, 4, 7),
+		"1": r(5, 1, 7, 2),
+		"2": r(6, 2, 6, 10),
+		"3": r(8, 1, 8, 9),
+	}
+	if !reflect.DeepEqual(f.StatementMap, wantStmts) {
+		t.Errorf("statementMap = %v, want %v", f.StatementMap, wantStmts)
+	}
+	if want := map[string]int{"0": 3, "1": 3, "2": 0, "3": 3}; !reflect.DeepEqual(f.S, want) {
+		t.Errorf("s = %v, want %v", f.S, want)
+	}
+	if f.FnMap["0"].Name != "A" || f.FnMap["1"].Name != "B" || f.FnMap["0"].Loc != r(3, 0, 9, 1) {
+		t.Errorf("unexpected fnMap: %+v", f.FnMap)
+	}
+	if want := map[string]int{"0": 3, "1": 0}; !reflect.DeepEqual(f.F, want) {
+		t.Errorf("f = %v, want %v", f.F, want)
+	}
+}
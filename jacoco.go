@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/cover"
+)
+
+// jacocoDoctype is the DOCTYPE of JaCoCo XML reports.
+const jacocoDoctype = `<!DOCTYPE report PUBLIC "-//JACOCO//DTD Report 1.1//EN" "report.dtd">`
+
+type jacocoReport struct {
+	XMLName     xml.Name          `xml:"report"`
+	Name        string            `xml:"name,attr"`
+	SessionInfo jacocoSessionInfo `xml:"sessioninfo"`
+	Packages    []*jacocoPackage  `xml:"package"`
+	Counters    []jacocoCounter   `xml:"counter"`
+}
+
+type jacocoSessionInfo struct {
+	ID    string `xml:"id,attr"`
+	Start int64  `xml:"start,attr"`
+	Dump  int64  `xml:"dump,attr"`
+}
+
+type jacocoPackage struct {
+	Name        string              `xml:"name,attr"`
+	Classes     []*jacocoClass      `xml:"class"`
+	SourceFiles []*jacocoSourceFile `xml:"sourcefile"`
+	Counters    []jacocoCounter     `xml:"counter"`
+
+	counts jacocoCounts
+}
+
+// jacocoClass is a Go file as a class, since JaCoCo has methods only in
+// classes.
+type jacocoClass struct {
+	Name           string          `xml:"name,attr"`
+	SourceFileName string          `xml:"sourcefilename,attr"`
+	Methods        []*jacocoMethod `xml:"method"`
+	Counters       []jacocoCounter `xml:"counter"`
+}
+
+type jacocoMethod struct {
+	Name     string          `xml:"name,attr"`
+	Desc     string          `xml:"desc,attr"`
+	Line     int             `xml:"line,attr"`
+	Counters []jacocoCounter `xml:"counter"`
+}
+
+type jacocoSourceFile struct {
+	Name     string          `xml:"name,attr"`
+	Lines    []jacocoLine    `xml:"line"`
+	Counters []jacocoCounter `xml:"counter"`
+}
+
+// jacocoLine is missed and covered instructions and branches of a line.
+type jacocoLine struct {
+	Nr int `xml:"nr,attr"`
+	MI int `xml:"mi,attr"`
+	CI int `xml:"ci,attr"`
+	MB int `xml:"mb,attr"`
+	CB int `xml:"cb,attr"`
+}
+
+type jacocoCounter struct {
+	Type    string `xml:"type,attr"`
+	Missed  int    `xml:"missed,attr"`
+	Covered int    `xml:"covered,attr"`
+}
+
+// jacocoCounts is coverage of instructions, which are statements, lines and
+// methods.
+type jacocoCounts struct {
+	instructions, lines, methods coverStats
+}
+
+func (c *jacocoCounts) add(d jacocoCounts) {
+	c.instructions.Covered += d.instructions.Covered
+	c.instructions.Total += d.instructions.Total
+	c.lines.Covered += d.lines.Covered
+	c.lines.Total += d.lines.Total
+	c.methods.Covered += d.methods.Covered
+	c.methods.Total += d.methods.Total
+}
+
+// counters returns counters of c. Counters without items are omitted as
+// JaCoCo does.
+func (c jacocoCounts) counters() []jacocoCounter {
+	var cs []jacocoCounter
+	for _, s := range []struct {
+		typ   string
+		stats coverStats
+	}{{"INSTRUCTION", c.instructions}, {"LINE", c.lines}, {"METHOD", c.methods}} {
+		if s.stats.Total > 0 {
+			cs = append(cs, jacocoCounter{Type: s.typ, Missed: s.stats.Total - s.stats.Covered, Covered: s.stats.Covered})
+		}
+	}
+	return cs
+}
+
+// writeJaCoCo writes coverage of profiles as a JaCoCo XML report. Statements
+// are reported as instructions and each file is a class with its functions
+// as methods. A line is covered if all statements on it are covered, see
+// lineHits. Methods are omitted for files which cannot be parsed.
+func writeJaCoCo(w io.Writer, profiles []*cover.Profile, resolve func(string) (string, error), timestamp time.Time) error {
+	ms := timestamp.UnixNano() / int64(time.Millisecond)
+	report := jacocoReport{Name: "goverage", SessionInfo: jacocoSessionInfo{ID: "goverage", Start: ms, Dump: ms}}
+	pkgs := make(map[string]*jacocoPackage)
+	var total jacocoCounts
+	for _, p := range profiles {
+		base := path.Base(p.FileName)
+		class := &jacocoClass{Name: strings.TrimSuffix(p.FileName, ".go"), SourceFileName: base}
+		src := &jacocoSourceFile{Name: base}
+		var counts jacocoCounts
+		counts.instructions = statementStats([]*cover.Profile{p})
+		for _, l := range coberturaLines(lineHits(p)) {
+			line := jacocoLine{Nr: l.Number, MI: 1}
+			if l.Hits > 0 {
+				line = jacocoLine{Nr: l.Number, CI: 1}
+				counts.lines.Covered++
+			}
+			counts.lines.Total++
+			src.Lines = append(src.Lines, line)
+		}
+		if filename, err := resolve(p.FileName); err != nil {
+			log.Printf("cannot resolve %s: %v", p.FileName, err)
+		} else if funcs, err := funcExtents(filename, p); err == nil {
+			for _, fn := range funcs {
+				m := jacocoCounts{instructions: coverStats{Covered: fn.Covered, Total: fn.Total}}
+				for _, l := range src.Lines {
+					if fn.StartLine <= l.Nr && l.Nr <= fn.EndLine {
+						m.lines.Total++
+						m.lines.Covered += l.CI
+					}
+				}
+				m.methods.Total = 1
+				if fn.Covered > 0 {
+					m.methods.Covered = 1
+				}
+				counts.methods.Covered += m.methods.Covered
+				counts.methods.Total++
+				class.Methods = append(class.Methods, &jacocoMethod{Name: fn.Name, Desc: "()", Line: fn.StartLine, Counters: m.counters()})
+			}
+		}
+		class.Counters = counts.counters()
+		src.Counters = counts.counters()
+		name := path.Dir(p.FileName)
+		pkg, ok := pkgs[name]
+		if !ok {
+			pkg = &jacocoPackage{Name: name}
+			pkgs[name] = pkg
+		}
+		pkg.Classes = append(pkg.Classes, class)
+		pkg.SourceFiles = append(pkg.SourceFiles, src)
+		pkg.counts.add(counts)
+		total.add(counts)
+	}
+	for _, pkg := range pkgs {
+		pkg.Counters = pkg.counts.counters()
+		report.Packages = append(report.Packages, pkg)
+	}
+	sort.Slice(report.Packages, func(i, j int) bool { return report.Packages[i].Name < report.Packages[j].Name })
+	report.Counters = total.counters()
+	if _, err := fmt.Fprintf(w, "%s%s\n", xml.Header, jacocoDoctype); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
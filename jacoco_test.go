@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestWriteJaCoCo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-jacoco")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "a.go")
+	src := "package a\n\nfunc A() {\n\tprintln()\n}\n\nfunc B() {\n\tprintln()\n}\n"
+	if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profiles := []*cover.Profile{
+		{FileName: "example.com/a/a.go", Mode: "set", Blocks: []cover.ProfileBlock{
+			{StartLine: 3, StartCol: 10, EndLine: 5, EndCol: 2, NumStmt: 1, Count: 1},
+			{StartLine: 7, StartCol: 10, EndLine: 9, EndCol: 2, NumStmt: 2, Count: 0},
+		}},
+	}
+	var buf bytes.Buffer
+	if err := writeJaCoCo(&buf, profiles, func(string) (string, error) { return filename, nil }, time.Unix(1, 0)); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE report PUBLIC "-//JACOCO//DTD Report 1.1//EN" "report.dtd">
+<report name="goverage">
+  <sessioninfo id="goverage" start="1000" dump="1000"></sessioninfo>
+  <package name="example.com/a">
+    <class name="example.com/a/a" sourcefilename="a.go">
+      <method name="A" desc="()" line="3">
+        <counter type="INSTRUCTION" missed="0" covered="1"></counter>
+        <counter type="LINE" missed="0" covered="3"></counter>
+        <counter type="METHOD" missed="0" covered="1"></counter>
+      </method>
+      <method name="B" desc="()" line="7">
+        <counter type="INSTRUCTION" missed="2" covered="0"></counter>
+        <counter type="LINE" missed="3" covered="0"></counter>
+        <counter type="METHOD" missed="1" covered="0"></counter>
+      </method>
+      <counter type="INSTRUCTION" missed="2" covered="1"></counter>
+      <counter type="LINE" missed="3" covered="3"></counter>
+      <counter type="METHOD" missed="1" covered="1"></counter>
+    </class>
+    <sourcefile name="a.go">
+      <line nr="3" mi="0" ci="1" mb="0" cb="0"></line>
+      <line nr="4" mi="0" ci="1" mb="0" cb="0"></line>
+      <line nr="5" mi="0" ci="1" mb="0" cb="0"></line>
+      <line nr="7" mi="1" ci="0" mb="0" cb="0"></line>
+      <line nr="8" mi="1" ci="0" mb="0" cb="0"></line>
+      <line nr="9" mi="1" ci="0" mb="0" cb="0"></line>
+      <counter type="INSTRUCTION" missed="2" covered="1"></counter>
+      <counter type="LINE" missed="3" covered="3"></counter>
+      <counter type="METHOD" missed="1" covered="1"></counter>
+    </sourcefile>
+    <counter type="INSTRUCTION" missed="2" covered="1"></counter>
+    <counter type="LINE" missed="3" covered="3"></counter>
+    <counter type="METHOD" missed="1" covered="1"></counter>
+  </package>
+  <counter type="INSTRUCTION" missed="2" covered="1"></counter>
+  <counter type="LINE" missed="3" covered="3"></counter>
+  <counter type="METHOD" missed="1" covered="1"></counter>
+</report>
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
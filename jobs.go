@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// lowMemoryRatio is the ratio of available memory to total memory below which
+// -j auto is considered to be under memory pressure.
+const lowMemoryRatio = 0.1
+
+// jobLimiter limits the number of packages tested concurrently.
+type jobLimiter struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	max     int
+	running int
+	// pressure reports whether the machine is under memory pressure, in
+	// which case no more packages start until running ones finish. It's nil
+	// unless -j is "auto".
+	pressure func() bool
+}
+
+// newJobLimiter returns a limiter for -j, which is a positive number or
+// "auto". "auto" allows as many packages as CPUs while the machine is not
+// under memory pressure.
+func newJobLimiter(jobs string) (*jobLimiter, error) {
+	l := &jobLimiter{}
+	l.cond = sync.NewCond(&l.mu)
+	if jobs == "auto" {
+		l.max = runtime.NumCPU()
+		l.pressure = newMemoryPressure("/proc")
+		return l, nil
+	}
+	n, err := strconv.Atoi(jobs)
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid -j %q: must be a positive number or auto", jobs)
+	}
+	l.max = n
+	return l, nil
+}
+
+// acquire blocks until another package can be started. At least one package
+// can always run.
+func (l *jobLimiter) acquire() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.running > 0 && (l.running >= l.max || l.pressure != nil && l.pressure()) {
+		l.cond.Wait()
+	}
+	l.running++
+}
+
+func (l *jobLimiter) release() {
+	l.mu.Lock()
+	l.running--
+	l.mu.Unlock()
+	l.cond.Broadcast()
+}
+
+// newMemoryPressure returns a function reporting whether the machine is
+// low on available memory or has started swapping since the last call,
+// based on meminfo and vmstat in procDir. It always reports false where they
+// are not available.
+func newMemoryPressure(procDir string) func() bool {
+	lastSwapOut, _ := readProcCounter(procDir+"/vmstat", "pswpout")
+	return func() bool {
+		if swapOut, ok := readProcCounter(procDir+"/vmstat", "pswpout"); ok {
+			swapping := swapOut > lastSwapOut
+			lastSwapOut = swapOut
+			if swapping {
+				return true
+			}
+		}
+		total, ok1 := readProcCounter(procDir+"/meminfo", "MemTotal:")
+		avail, ok2 := readProcCounter(procDir+"/meminfo", "MemAvailable:")
+		return ok1 && ok2 && total > 0 && float64(avail) < lowMemoryRatio*float64(total)
+	}
+}
+
+// readProcCounter returns the number following the key at the start of a line
+// in the file, such as "pswpout 123" in /proc/vmstat or "MemTotal: 123 kB" in
+// /proc/meminfo.
+func readProcCounter(filename, key string) (uint64, bool) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) >= 2 && fields[0] == key {
+			n, err := strconv.ParseUint(fields[1], 10, 64)
+			return n, err == nil
+		}
+	}
+	return 0, false
+}
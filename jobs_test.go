@@ -0,0 +1,131 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewJobLimiter(t *testing.T) {
+	for _, jobs := range []string{"0", "-1", "x", ""} {
+		if _, err := newJobLimiter(jobs); err == nil {
+			t.Errorf("newJobLimiter(%q) should fail", jobs)
+		}
+	}
+	l, err := newJobLimiter("auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.max < 1 || l.pressure == nil {
+		t.Errorf("unexpected limiter for auto: %+v", l)
+	}
+}
+
+func TestJobLimiter(t *testing.T) {
+	l, err := newJobLimiter("2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var running, peak int
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		l.acquire()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer l.release()
+			mu.Lock()
+			running++
+			if running > peak {
+				peak = running
+			}
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if peak > 2 {
+		t.Errorf("%d packages ran concurrently, want <= 2", peak)
+	}
+
+	// Under memory pressure, a package still runs when nothing is running.
+	l = &jobLimiter{max: 4, pressure: func() bool { return true }}
+	l.cond = sync.NewCond(&l.mu)
+	l.acquire()
+	if l.running != 1 {
+		t.Errorf("running = %d, want 1", l.running)
+	}
+}
+
+func TestMemoryPressure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-proc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("vmstat", "pswpin 0\npswpout 10\n")
+	write("meminfo", "MemTotal:       1000 kB\nMemFree:         100 kB\nMemAvailable:    500 kB\n")
+	pressure := newMemoryPressure(dir)
+	if pressure() {
+		t.Error("want no pressure")
+	}
+	write("vmstat", "pswpin 0\npswpout 20\n")
+	if !pressure() {
+		t.Error("want pressure while swapping")
+	}
+	if pressure() {
+		t.Error("want no pressure after swapping stopped")
+	}
+	write("meminfo", "MemTotal:       1000 kB\nMemAvailable:     50 kB\n")
+	if !pressure() {
+		t.Error("want pressure on low memory")
+	}
+	if newMemoryPressure(filepath.Join(dir, "missing"))() {
+		t.Error("want no pressure without proc files")
+	}
+}
+
+func TestRun_jobs(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("./example/root"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	profiles := make([]string, 2)
+	for i, j := range []string{"1", "4"} {
+		tmpfile, err := ioutil.TempFile("", "goverage-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(tmpfile.Name())
+		jobs = j
+		err = run(tmpfile.Name(), []string{"./..."}, "count", "", "", "", false, false)
+		jobs = "1"
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadFile(tmpfile.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		profiles[i] = string(b)
+	}
+	if profiles[0] != profiles[1] {
+		t.Errorf("-j 1:\n%v\n-j 4:\n%v", profiles[0], profiles[1])
+	}
+}
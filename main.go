@@ -9,14 +9,43 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"golang.org/x/tools/cover"
 )
 
+// version is the version of goverage. Releases set it by
+// -ldflags "-X main.version=<tag>".
+var version = "devel"
+
 const usageMessage = "" +
 	`Usage:	goverage [flags] -coverprofile=coverage.out package...
+	goverage <command> [arguments]
+
+Commands:
+	build		build coverage-instrumented binaries
+	check		check coverage of a profile against thresholds
+	collect		merge coverage data of instrumented binaries into a profile
+	compile		build coverage-instrumented test binaries without running them
+	coordinator	merge profiles uploaded by sharded CI jobs over HTTP
+	doctor		check the environment and flags for common problems
+	exec		run test binaries built by compile and merge their profiles
+	impact		print tests affected by changed files
+	merge		merge text profiles and coverage data directories
+	report		print reports of a profile
+	select		print or run tests affected by changes since a git ref
+	self-update	update goverage to the latest release (builds with a release key only)
+	stats		print statistics of a profile
+	total		print total coverage of a profile
+	validate	check a profile for syntax errors, duplicate blocks and missing files
+
 `
 
 var (
@@ -30,6 +59,68 @@ var (
 	x            bool
 	race         bool
 	gobinary     string
+	failuresJSON string
+	tapFile      string
+	teamcity     bool
+	gitlab       bool
+
+	testJSONOutput string
+	jobs           string
+	outputMode     string
+	uncovered      bool
+	chart          bool
+	appendProfile  bool
+	coerceModeFlag string
+	maxDecrease    float64
+	baselineFile   string
+	relativePaths  bool
+	rewrites       pathRewrites
+	skipCgo        bool
+	metadataFile   string
+	showVersion    bool
+	testEnvVars    envVars
+	envForAll      bool
+	noNetwork      bool
+	dockerImage    string
+	workerHosts    string
+	perTestFile    string
+	testMapFile    string
+	impact         bool
+
+	retries         int
+	detectFlaky     int
+	flakyReportFile string
+
+	buildkiteFile     string
+	buildkiteAnnotate bool
+
+	ignoreUnresolved bool
+	coverTarget      string
+	coverpkgMode     string
+	coverDeps        string
+	pkgFile          string
+	configFile       string
+	depDirs          moduleDirs
+
+	subprocessCoverage bool
+	nativeMerge        bool
+	covdir             string
+	useCache           bool
+	cacheDir           string
+	failfast           bool
+	benchOnly          bool
+	examplesOnly       bool
+	suites             string
+	tagMatrix          string
+	tagProfiles        string
+	generatedReport    string
+	exclusionsReport   string
+	stateDir           string
+	noState            bool
+
+	// tags is build tags of go test, which is set for each tag set of
+	// -tag-matrix.
+	tags string
 )
 
 func init() {
@@ -43,88 +134,759 @@ func init() {
 	flag.BoolVar(&x, "x", false, "sent as x argument to go test")
 	flag.BoolVar(&race, "race", false, "enable data race detection")
 	flag.StringVar(&gobinary, "go-binary", "go", "Use an alternative test runner such as 'richgo'")
+	flag.StringVar(&failuresJSON, "failures-json", "", "Write a JSON report of failed packages and packages missing from the profile to the file")
+	flag.StringVar(&tapFile, "tap", "", "Write per-package test results in TAP to the file ('-' for stdout, which moves test output to stderr)")
+	flag.BoolVar(&teamcity, "teamcity", false, "Print TeamCity service messages for test results and coverage")
+	flag.BoolVar(&gitlab, "gitlab", false, "Write a Cobertura report to "+gitlabCoberturaFile+" and print total coverage for GitLab")
+	flag.StringVar(&jobs, "j", "1", "Number of packages to test concurrently, or 'auto' to adapt it to CPUs and memory pressure")
+	flag.StringVar(&outputMode, "output-mode", "", "How to print output of packages: buffered, stream or group (default stream with -v, buffered otherwise)")
+	flag.BoolVar(&appendProfile, "append", false, "Merge results into the existing profile of -coverprofile instead of overwriting it")
+	flag.StringVar(&coerceModeFlag, "coerce-mode", "", "Convert profiles to the mode (set or count) to append a profile of another mode")
+	flag.BoolVar(&relativePaths, "relative-paths", false, "Write file names relative to their modules (e.g. internal/foo/bar.go) instead of import paths in the profile")
+	flag.Var(&rewrites, "path-rewrite", "Rewrite prefixes of file names in the profile and reports as 'from=>to' (e.g. 'github.com/me/app=>.'). Can be repeated")
+	flag.BoolVar(&showVersion, "version", false, "Print the version of goverage, its VCS revision and the Go version which built it")
+	flag.StringVar(&metadataFile, "metadata", "", "Write metadata of the run (goverage and Go versions, covermode, git revision and branch, and time) as JSON to the file, to keep with the profile")
+	flag.BoolVar(&skipCgo, "skip-cgo-generated", false, "Exclude files generated by cgo which cannot be mapped back to their original files (e.g. _cgo_gotypes.go) from the profile")
+	flag.Float64Var(&maxDecrease, "max-decrease", -1, "Fail if the total coverage decreased by more than the percentage points from the baseline. Negative disables it")
+	flag.StringVar(&baselineFile, "baseline", "", "Baseline profile of -max-decrease (default the coverage of the last run without failures in -state-dir)")
+	flag.BoolVar(&uncovered, "uncovered", false, "Print ranges of uncovered lines per file after tests")
+	flag.BoolVar(&chart, "chart", false, "Print a bar chart of coverage per package after tests")
+	flag.StringVar(&testJSONOutput, "test-json-output", "", "Run tests with 'go test -json' and write events of all packages to the file")
+	flag.StringVar(&buildkiteFile, "buildkite", "", "Write a Buildkite annotation in Markdown summarizing coverage and failed packages to the file")
+	flag.BoolVar(&buildkiteAnnotate, "buildkite-annotate", false, "Annotate the Buildkite build with the summary by 'buildkite-agent annotate'")
+	flag.BoolVar(&ignoreUnresolved, "ignore-unresolved", false, "Log and skip package patterns which cannot be resolved instead of failing")
+	flag.BoolVar(&subprocessCoverage, "subprocess-coverage", false, "Collect coverage of coverage-instrumented binaries run by tests via GOCOVERDIR (Go 1.20+)")
+	flag.BoolVar(&nativeMerge, "native-merge", false, "Merge coverage by 'go tool covdata' instead of merging text profiles (Go 1.20+)")
+	flag.StringVar(&covdir, "covdir", "", "Also merge binary coverage data of tests into the directory for 'go tool covdata', keeping data already in it (Go 1.20+)")
+	flag.BoolVar(&useCache, "cache", false, "Reuse profiles of packages whose test inputs are unchanged since a previous successful run")
+	flag.StringVar(&cacheDir, "cache-dir", "", "Directory of the profile cache (default goverage in the user cache directory)")
+	flag.BoolVar(&benchOnly, "bench-only", false, "Skip tests and examples and run only benchmarks of each package with coverage, as '-run ^$ -bench .'")
+	flag.BoolVar(&examplesOnly, "examples-only", false, "Run only testable examples of each package with coverage, as '-run ^Example', to measure how much of the API examples exercise")
+	flag.StringVar(&suites, "suites", "", "Comma separated suites to run packages for: short (with -short) and full. Profiles of suites are written next to -coverprofile (e.g. coverage.short.out), which gets the merged one")
+	flag.StringVar(&tagMatrix, "tag-matrix", "", "Semicolon separated build tag sets to run packages with, each of which is comma separated tags (e.g. 'integration;integration,postgres'). -coverprofile gets the merged profile")
+	flag.StringVar(&tagProfiles, "tag-profiles", "", "Directory to write the profile of each tag set of -tag-matrix into, as <dir>/<tags>/<coverprofile> ('notags' for the empty set)")
+	flag.StringVar(&generatedReport, "generated-report", "", "Write files of the profile classified as generated with the reasons to the file as JSON, to audit exclude.generated of the config")
+	flag.StringVar(&exclusionsReport, "exclusions-report", "", "Write regions ignored by //goverage:ignore-start and //goverage:ignore-end with their files, lines and removed statements to the file as JSON")
+	flag.BoolVar(&failfast, "failfast", false, "Do not start new tests after the first test failure")
+	flag.StringVar(&stateDir, "state-dir", defaultStateDir, "Directory to store the state of the last run, which is used to run previously failed and slow packages first")
+	flag.BoolVar(&noState, "no-state", false, "Do not read or write the state of runs in -state-dir")
+	flag.StringVar(&configFile, "config", "", "Config file (default \""+defaultConfigFile+"\" if exists)")
+	flag.StringVar(&coverTarget, "cover-target", "", "Only run tests of packages which depend on the package of the file, and measure coverage of the package")
+	flag.StringVar(&coverpkgMode, "coverpkg-mode", coverpkgAll, "Packages to instrument for tests of each package: all (target packages), module (target packages in its module), deps (itself and target packages in its module it depends on) or self")
+	flag.StringVar(&coverDeps, "cover-deps", "", "Comma separated package patterns of dependencies outside of target packages to instrument too (e.g. 'github.com/partner/sdk/...')")
+	flag.Var(&testEnvVars, "env", "Set an environment variable of 'go test' as 'KEY=VALUE'. Can be repeated")
+	flag.BoolVar(&envForAll, "env-all", false, "Also set -env for other commands run by goverage such as 'go list'")
+	flag.BoolVar(&noNetwork, "no-network", false, "Run tests without network in a sandbox (Linux only; fails elsewhere)")
+	flag.StringVar(&dockerImage, "docker", "", "Run tests of each package in a container of the image (e.g. golang:1.22) with the module mounted")
+	flag.StringVar(&workerHosts, "workers", "", "Experimental: comma separated SSH hosts to copy the module to by rsync and run tests of packages on")
+	flag.StringVar(&perTestFile, "per-test", "", "Run each top-level test separately and write statements covered by each test to the file as JSON. It's slow")
+	flag.StringVar(&testMapFile, "test-map", "", "Run each top-level test separately like -per-test and write a JSON map of tests to files and blocks they cover to the file")
+	flag.BoolVar(&impact, "impact", false, "Run each top-level test separately like -per-test and update the test impact database in -state-dir for 'goverage impact'")
+	flag.IntVar(&retries, "retries", 0, "Rerun tests of a failed package up to the number of times until they pass. Tests which passed on a retry are reported as flaky")
+	flag.IntVar(&detectFlaky, "detect-flaky", 0, "Run tests of each package the number of times to detect flaky tests")
+	flag.StringVar(&flakyReportFile, "flaky-report", "", "Write a JSON report of pass/fail patterns and stability of tests across attempts of -retries or -detect-flaky to the file")
+	flag.Var(&depDirs, "dir", "Run tests of packages of the module in the directory, a checkout of a dependency module, instead of the module cache. Can be repeated")
+	flag.StringVar(&pkgFile, "pkg-file", "", "Read target packages from the file, one per line, in addition to arguments. '-' reads from stdin")
 }
 
 func usage() {
-	fmt.Fprintln(os.Stderr, usageMessage)
+	fmt.Fprint(os.Stderr, usageMessage)
 	fmt.Fprintln(os.Stderr, "Flags:")
 	flag.PrintDefaults()
 	os.Exit(2)
 }
 
-type ExitError struct {
-	Msg  string
-	Code int
-}
-
-func (e *ExitError) Error() string {
-	return e.Msg
-}
-
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := subcommands[os.Args[1]]; ok {
+			exit(cmd(os.Args[2:]))
+		}
+	}
 	flag.Usage = usage
 	flag.Parse()
-	if err := run(coverprofile, flag.Args(), covermode, cpu, parallel, timeout, short, v); err != nil {
-		code := 1
-		if err, ok := err.(*ExitError); ok {
-			code = err.Code
-		}
-		if err.Error() != "" {
-			fmt.Fprintln(os.Stderr, err)
+	if showVersion {
+		fmt.Println(readBuildVersion())
+		exit(nil)
+	}
+	if err := mergeGOFLAGS(flag.CommandLine); err != nil {
+		exit(err)
+	}
+	if v {
+		log.Print(readBuildVersion())
+	}
+	if suites != "" {
+		ss, err := parseSuites(suites)
+		if err != nil {
+			exit(err)
 		}
-		os.Exit(code)
+		exit(runSuites(ss, coverprofile, flag.Args()))
+	}
+	if tagMatrix != "" {
+		exit(runTagMatrix(parseTagMatrix(tagMatrix), coverprofile, tagProfiles, flag.Args()))
+	}
+	exit(run(coverprofile, flag.Args(), covermode, cpu, parallel, timeout, short, v))
+}
+
+// exportTraces exports traces of the run which ended with err, if tr is not
+// nil.
+func exportTraces(tr *tracer, err error) {
+	if tr == nil {
+		return
+	}
+	if eerr := tr.export(err); eerr != nil {
+		log.Printf("failed to export traces: %v", eerr)
+	}
+}
+
+// exit exits goverage with the status for err.
+func exit(err error) {
+	if err == nil {
+		os.Exit(0)
+	}
+	if err.Error() != "" {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	os.Exit(exitStatus(err))
+}
+
+// checkFlagConflicts checks flags which cannot be used together. covermode is
+// the covermode of the run.
+func checkFlagConflicts(covermode string) error {
+	if race && covermode != "" && covermode != "atomic" {
+		return fmt.Errorf("cannot use race flag and covermode=%s. See more detail on golang/go#12118.", covermode)
+	}
+	if err := validateOutputMode(outputMode); err != nil {
+		return err
+	}
+	if (perTestFile != "" || testMapFile != "" || impact) && (useCache || nativeMerge || covdir != "") {
+		return fmt.Errorf("-per-test, -test-map and -impact cannot be used with -cache, -native-merge or -covdir")
+	}
+	if workerHosts != "" && (dockerImage != "" || noNetwork || nativeMerge || covdir != "" || subprocessCoverage) {
+		return fmt.Errorf("-workers cannot be used with -docker, -no-network, -native-merge, -covdir or -subprocess-coverage")
 	}
+	if covdir != "" && useCache {
+		return fmt.Errorf("-covdir cannot be used with -cache since cached packages have no binary coverage data")
+	}
+	if benchOnly && examplesOnly {
+		return fmt.Errorf("-bench-only and -examples-only cannot be used together")
+	}
+	if (benchOnly || examplesOnly) && (perTestFile != "" || testMapFile != "" || impact || retries > 0 || detectFlaky > 1) {
+		return fmt.Errorf("-bench-only and -examples-only cannot be used with -per-test, -test-map, -impact, -retries or -detect-flaky, which select tests to run")
+	}
+	if suites != "" && tagMatrix != "" {
+		return fmt.Errorf("-suites cannot be used with -tag-matrix")
+	}
+	if tagProfiles != "" && tagMatrix == "" {
+		return fmt.Errorf("-tag-profiles requires -tag-matrix")
+	}
+	return nil
 }
 
-func run(coverprofile string, args []string, covermode, cpu, parallel, timeout string, short, v bool) error {
+func run(coverprofile string, args []string, covermode, cpu, parallel, timeout string, short, v bool) (err error) {
 	if coverprofile == "" {
 		usage()
 		return nil
 	}
-	if race && covermode != "" && covermode != "atomic" {
-		return fmt.Errorf("cannot use race flag and covermode=%s. See more detail on golang/go#12118.", covermode)
+	// Runs of variants share traces and test events of all of them, and
+	// export traces once in runVariants. Otherwise, traces are exported
+	// however the run ends, such as by errors before tests or interrupts.
+	var tr *tracer
+	if variants != nil {
+		tr = variants.tr
+	} else {
+		tr = newTracer(os.Getenv)
+		defer func() { exportTraces(tr, err) }()
+	}
+	if err := checkFlagConflicts(covermode); err != nil {
+		return err
+	}
+	if tapFile == "-" {
+		testOutput = os.Stderr
 	}
 
-	file, err := os.Create(coverprofile)
+	// Package patterns are relative to the working directory, but the
+	// profile, the state and the config default to ones in the module root
+	// so that goverage can be run in any subdirectory.
+	workDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	modRoot := findModuleRoot(workDir)
+	if modRoot != "" && modRoot != workDir {
+		set := setFlagNames(flag.CommandLine)
+		coverprofile = fromRoot(set, "coverprofile", coverprofile, modRoot)
+		stateDir = fromRoot(set, "state-dir", stateDir, modRoot)
+		configFile = configFromRoot(configFile)
+	}
+	cfg, err := loadConfig(configFile)
+	if err != nil {
+		return err
+	}
+	if noNetwork && dockerImage == "" {
+		if err := checkNetworkSandbox(); err != nil {
+			return fmt.Errorf("-no-network: %v", err)
+		}
+	}
+	if envForAll {
+		if err := testEnvVars.setenv(); err != nil {
+			return err
+		}
+	}
+	// vcs is the state of the git repository recorded in JSON outputs.
+	var vcs vcsInfo
+	if tr != nil || testJSONOutput != "" || failuresJSON != "" || flakyReportFile != "" || metadataFile != "" {
+		vcs = detectVCS()
+	}
+	if tr != nil {
+		tr.vcs = vcs
+	}
+	var testEvents io.Writer
+	if variants != nil {
+		testEvents = variants.testEvents
+	} else if testJSONOutput != "" {
+		f, err := os.Create(testJSONOutput)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		testEvents = f
+	}
+	// appended is profiles in coverprofile to merge new results into.
+	var appended []*cover.Profile
+	if appendProfile && isExist(coverprofile) {
+		if appended, err = readProfiles(coverprofile); err != nil {
+			return err
+		}
+	}
+	// Open the file first to fail early. It's truncated just before writing
+	// the result with -append.
+	flags := os.O_WRONLY | os.O_CREATE
+	if !appendProfile {
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(coverprofile, flags, 0666)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
-	// pkgs is packages to run tests and get coverage.
-	var pkgs []string
-	for _, pkg := range args {
-		ps, err := getPkgs(pkg)
+	if pkgFile != "" {
+		ps, err := readPkgFile(pkgFile)
 		if err != nil {
 			return err
 		}
-		pkgs = append(pkgs, ps...)
+		args = append(args, ps...)
+		if len(args) == 0 {
+			log.Printf("no packages in %s", pkgFile)
+		}
+	}
+	if len(args) == 0 && pkgFile == "" {
+		args = []string{"."}
+	}
+	// pkgs is packages to run tests and get coverage.
+	pkgs, skipped, err := resolvePkgsSkipped(args, ignoreUnresolved)
+	if err != nil {
+		return err
+	}
+	// Test support packages are neither tested nor instrumented.
+	pkgs, helpers := cfg.withoutTestHelpers(pkgs)
+	skipped = append(skipped, helpers...)
+	var targetPkg string
+	if coverTarget != "" {
+		all := pkgs
+		if pkgs, targetPkg, err = selectCoverTarget(pkgs, coverTarget); err != nil {
+			return err
+		}
+		skipped = append(skipped, coverTargetSkipped(all, pkgs, targetPkg)...)
+		log.Printf("%d package(s) depend on %s", len(pkgs), targetPkg)
+	}
+	// Tests of packages outside of the main module run in their modules.
+	depDirs.apply(pkgs)
+	if hasExternal(pkgs) && (dockerImage != "" || workerHosts != "") {
+		return fmt.Errorf("packages outside of the main module cannot be tested with -docker or -workers")
+	}
+	if dockerImage != "" || workerHosts != "" {
+		if moduleRoot = mainModuleDir(pkgs); moduleRoot == "" {
+			if moduleRoot, err = os.Getwd(); err != nil {
+				return err
+			}
+		}
+	}
+	if workerHosts != "" {
+		if workers, err = newWorkerPool(workerHosts, moduleRoot); err != nil {
+			return err
+		}
+		if err := workers.sync(moduleRoot); err != nil {
+			return err
+		}
+		if jobs == "1" {
+			jobs = strconv.Itoa(len(workers.all))
+		}
+	}
+	importPaths := make([]string, 0, len(pkgs))
+	for _, p := range pkgs {
+		importPaths = append(importPaths, p.ImportPath)
+	}
+	coverpkgOf, err := coverpkgs(coverpkgMode, pkgs)
+	if err != nil {
+		return err
+	}
+	if targetPkg != "" {
+		// Instrument only the package of the target, which is much faster.
+		for pkg := range coverpkgOf {
+			coverpkgOf[pkg] = targetPkg
+		}
+	} else {
+		restrictExternalCoverpkg(coverpkgOf, pkgs)
+		if coverDeps != "" {
+			addCoverDeps(coverpkgOf, coverDeps)
+		}
+	}
+	state := &runState{Packages: map[string]*packageState{}}
+	if !noState {
+		if state, err = loadRunState(stateDir); err != nil {
+			return err
+		}
+	}
+	// Run packages which likely fail or take long first. Note that it must
+	// not change coverpkg, which is a part of cache keys.
+	state.prioritize(pkgs)
+	// testArgs returns optional args of "go test" for the package.
+	testArgs := func(p *listPackage) []string {
+		args := buildOptionalTestArgs(coverpkgOf[p.ImportPath], covermode, cpu, parallel, timeout, short, v)
+		// -bench-only and -examples-only take precedence over tests selected
+		// by the config.
+		if run := cfg.runPattern(p); run != "" && !benchOnly && !examplesOnly {
+			args = append(args, "-run", run)
+		}
+		return args
+	}
+	// nativeRoot is the directory which contains binary coverage data
+	// directories of each package when merging by "go tool covdata" or
+	// writing them to -covdir.
+	var nativeRoot string
+	if nativeMerge || covdir != "" {
+		if goSupportsCovdata() {
+			if nativeRoot, err = ioutil.TempDir("", "goverage-native"); err != nil {
+				return err
+			}
+			defer os.RemoveAll(nativeRoot)
+		} else if covdir != "" {
+			return fmt.Errorf("-covdir requires Go 1.20 or later")
+		} else {
+			log.Printf("-native-merge requires Go 1.20 or later. Fall back to merging text profiles")
+		}
+	}
+	var nativeDirs []string
+	var cache *profileCache
+	var inputs *testInputs
+	var gover string
+	if useCache {
+		var remote *remoteCache
+		if cfg.Cache.Remote != nil {
+			if remote, err = newRemoteCache(cfg.Cache.Remote); err != nil {
+				return err
+			}
+		}
+		if cache, err = newProfileCache(cacheDir, remote); err != nil {
+			return err
+		}
+		if inputs, err = listTestInputs(importPaths); err != nil {
+			return err
+		}
+		if gover, err = goVersion(); err != nil {
+			return err
+		}
 	}
-	if len(pkgs) == 0 {
-		pkgs = []string{"."}
+	limiter, err := newJobLimiter(jobs)
+	if err != nil {
+		return err
 	}
-	coverpkg := strings.Join(pkgs, ",")
-	optionalArgs := buildOptionalTestArgs(coverpkg, covermode, cpu, parallel, timeout, short, v)
+	// mu guards variables below, which are updated by concurrent tests of
+	// packages.
+	var mu sync.Mutex
+	// cachedCpss is profiles reused from the cache.
+	var cachedCpss [][]*cover.Profile
 	cpss := make([][]*cover.Profile, len(pkgs))
-	hasFailedTest := false
-	for i, pkg := range pkgs {
-		cps, success, err := coverage(pkg, optionalArgs, v)
-		if !success {
-			hasFailedTest = true
+	results := make([]*packageResult, 0, len(pkgs))
+	var failedPkgs, buildFailedPkgs []string
+	var loopErr error
+	// record records the result of a package. It must be called with mu held.
+	record := func(r *packageResult, start time.Time) error {
+		results = append(results, r)
+		// "go test" prints the line itself with -v, and on failures.
+		if r.Cached || !v && r.Success {
+			writePackageLine(testOutput, r)
+		}
+		if teamcity {
+			// Cached packages are not reported when they start.
+			if r.Cached {
+				writeTeamCityTestStarted(testOutput, r.Pkg)
+			}
+			writeTeamCityTestFinished(testOutput, r)
+		}
+		if tr != nil {
+			tr.packageSpan(r, start)
+		}
+		if testEvents != nil {
+			return writeTestEvents(testEvents, r, vcs)
+		}
+		return nil
+	}
+	testOne := func(i int, p *listPackage) error {
+		pkg := p.ImportPath
+		start := time.Now()
+		var cacheKey string
+		// Packages with hooks are not cached since hooks may have side effects.
+		if cache != nil && len(cfg.preHooks(p)) == 0 && len(cfg.postHooks(p)) == 0 {
+			mu.Lock()
+			keyArgs := append(testArgs(p), fmt.Sprintf("-subprocess-coverage=%v", subprocessCoverage))
+			key, err := inputs.key(pkg, gover, keyArgs, testEnv(cfg, p))
+			mu.Unlock()
+			if err != nil {
+				log.Printf("cannot cache package %q: %v", pkg, err)
+			} else if ps, ok := cache.get(key); ok {
+				r := &packageResult{Pkg: pkg, Profiles: inputs.depProfiles(pkg, ps), Success: true, Cached: true}
+				mu.Lock()
+				defer mu.Unlock()
+				cpss[i] = r.Profiles
+				cachedCpss = append(cachedCpss, r.Profiles)
+				return record(r, start)
+			}
+			cacheKey = key
+		}
+		var nativeDir string
+		if nativeRoot != "" {
+			nativeDir = filepath.Join(nativeRoot, strconv.Itoa(i))
+			if err := os.Mkdir(nativeDir, 0755); err != nil {
+				return err
+			}
+			mu.Lock()
+			nativeDirs = append(nativeDirs, nativeDir)
+			mu.Unlock()
+		}
+		if teamcity {
+			writeTeamCityTestStarted(testOutput, pkg)
+		}
+		var r *packageResult
+		var err error
+		if retries > 0 || detectFlaky > 1 {
+			r, err = runAttempts(func() (*packageResult, error) {
+				return testPackage(cfg, p, testArgs(p), nativeDir, v)
+			}, detectFlaky, retries)
+		} else {
+			r, err = testPackage(cfg, p, testArgs(p), nativeDir, v)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if r != nil {
+			if err := record(r, start); err != nil {
+				return err
+			}
+		} else if teamcity {
+			writeTeamCityTestFinished(testOutput, &packageResult{Pkg: pkg, Stderr: []byte(fmt.Sprint(err)), Duration: time.Since(start)})
+		}
+		if r == nil || !r.Success {
+			failedPkgs = append(failedPkgs, pkg)
+			if r != nil && classifyFailure(r.output()) == failureBuild {
+				buildFailedPkgs = append(buildFailedPkgs, pkg)
+			}
 		}
 		if err != nil {
 			// Do not return err here. It could be just tests are not found for the package.
 			log.Printf("got error for package %q: %v", pkg, err)
-			continue
+			return nil
+		}
+		if r.Profiles != nil {
+			cpss[i] = r.Profiles
+		}
+		if cacheKey != "" && r.Success {
+			if err := cache.put(cacheKey, r.Profiles); err != nil {
+				log.Printf("failed to cache profile of package %q: %v", pkg, err)
+			}
 		}
-		if cps != nil {
-			cpss[i] = cps
+		return nil
+	}
+	// Kill running tests with their test binaries on interrupts. They don't
+	// receive interrupts from the terminal since they run in their own
+	// process groups.
+	testCmds = newRunningCmds()
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigc)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-sigc:
+			log.Printf("interrupted; killing running tests")
+			testCmds.killAll()
+		case <-done:
+		}
+	}()
+	var wg sync.WaitGroup
+	for i, p := range pkgs {
+		limiter.acquire()
+		mu.Lock()
+		failed := failfast && len(failedPkgs) > 0
+		stop := loopErr != nil || failed || testCmds.interrupted()
+		mu.Unlock()
+		if stop {
+			limiter.release()
+			if failed {
+				log.Printf("skip remaining %d package(s) by -failfast", len(pkgs)-i)
+			}
+			break
 		}
+		wg.Add(1)
+		go func(i int, p *listPackage) {
+			defer wg.Done()
+			defer limiter.release()
+			if err := testOne(i, p); err != nil {
+				mu.Lock()
+				if loopErr == nil {
+					loopErr = err
+				}
+				mu.Unlock()
+			}
+		}(i, p)
 	}
-	dumpcp(file, mergeProfiles(cpss))
-	if hasFailedTest {
-		return &ExitError{Code: 1}
+	wg.Wait()
+	if loopErr != nil {
+		return loopErr
 	}
-	return nil
+	if testCmds.interrupted() {
+		return &ExitError{Msg: "goverage: interrupted", Code: 130}
+	}
+	if covdir != "" {
+		if err := mergeCovdata(nativeDirs, covdir); err != nil {
+			return err
+		}
+	}
+	var merged []*cover.Profile
+	if nativeMerge && nativeRoot != "" {
+		native, err := nativeMergeProfiles(nativeDirs)
+		if err != nil {
+			return err
+		}
+		merged = mergeProfiles(append([][]*cover.Profile{native}, cachedCpss...))
+	} else {
+		merged = mergeProfiles(cpss)
+	}
+	if len(appended) > 0 {
+		cpss, err := checkModes([][]*cover.Profile{appended, merged}, coerceModeFlag)
+		if err != nil {
+			return fmt.Errorf("cannot append to %s: %v", coverprofile, err)
+		}
+		merged = mergeProfiles(cpss)
+	}
+	merged = fixCgoProfiles(merged, skipCgo)
+	// Reports after tests share the resolver, which already knows
+	// directories of tested packages.
+	resolver := newFileResolver()
+	resolver.addPackages(pkgs)
+	resolver.prefetch(merged)
+	resolve := resolver.resolve
+	merged = normalizeProfiles(merged, resolve)
+	out := &runOutputs{
+		coverprofile:    coverprofile,
+		baseline:        baselineKey(args, short, tags),
+		cfg:             cfg,
+		state:           state,
+		workDir:         workDir,
+		modRoot:         modRoot,
+		vcs:             vcs,
+		pkgs:            pkgs,
+		importPaths:     importPaths,
+		skipped:         skipped,
+		results:         results,
+		failedPkgs:      failedPkgs,
+		buildFailedPkgs: buildFailedPkgs,
+		tr:              tr,
+	}
+	if generatedReport != "" {
+		out.generated = cfg.generatedFiles(merged, resolve)
+	}
+	if exclusionsReport != "" {
+		out.ignored = cfg.ignoredRegions(merged, resolve)
+	}
+	merged = rewriteProfiles(cfg.filterProfiles(merged, resolve), rewrites)
+	if appendProfile {
+		if err := file.Truncate(0); err != nil {
+			return err
+		}
+	}
+	dumpProfiles(file, merged, pkgs)
+	out.profiles = merged
+	// Reports of variants are written once for the merged profile.
+	if variants != nil {
+		variants.outputs = append(variants.outputs, out)
+		return nil
+	}
+	return out.write(resolve)
+}
+
+// dumpProfiles writes profiles to w with file names relative to their modules
+// with -relative-paths.
+func dumpProfiles(w io.Writer, profiles []*cover.Profile, pkgs []*listPackage) {
+	if !relativePaths {
+		dumpcp(w, profiles)
+		return
+	}
+	modules := modulePaths(pkgs)
+	if len(modules) == 0 {
+		log.Printf("-relative-paths has no effect outside of modules")
+	}
+	dumpcp(w, relativeProfiles(profiles, modules))
+}
+
+// runOutputs is results of a run to write reports, the state and the
+// baseline of -max-decrease from.
+type runOutputs struct {
+	coverprofile string
+	// profiles is the merged profile written to coverprofile.
+	profiles []*cover.Profile
+	// baseline is the key of the baseline of -max-decrease.
+	baseline        string
+	cfg             *config
+	state           *runState
+	workDir         string
+	modRoot         string
+	vcs             vcsInfo
+	pkgs            []*listPackage
+	importPaths     []string
+	skipped         []skippedPackage
+	results         []*packageResult
+	failedPkgs      []string
+	buildFailedPkgs []string
+	generated       []generatedFile
+	ignored         []ignoredRegion
+	tr              *tracer
+}
+
+// write writes reports and the state of the run, and returns an error if
+// tests failed or coverage decreased.
+func (o *runOutputs) write(resolve func(string) (string, error)) error {
+	merged, results, state := o.profiles, o.results, o.state
+	if generatedReport != "" {
+		if err := writeJSONFile(generatedReport, o.generated); err != nil {
+			return err
+		}
+	}
+	if exclusionsReport != "" {
+		if err := writeJSONFile(exclusionsReport, o.ignored); err != nil {
+			return err
+		}
+	}
+	if metadataFile != "" {
+		if err := writeJSONFile(metadataFile, newRunMetadata(o.coverprofile, merged, covermode, o.vcs, time.Now())); err != nil {
+			return err
+		}
+	}
+	if uncovered {
+		if err := writeUncovered(testOutput, merged, resolve); err != nil {
+			return err
+		}
+	}
+	if chart {
+		if err := writeChart(testOutput, merged); err != nil {
+			return err
+		}
+	}
+	if teamcity {
+		writeTeamCityCoverage(testOutput, merged)
+	}
+	if gitlab {
+		if err := writeGitLabReport(testOutput, merged, resolve); err != nil {
+			return err
+		}
+	}
+	if buildkiteFile != "" || buildkiteAnnotate {
+		var buf bytes.Buffer
+		if err := writeBuildkiteAnnotation(&buf, merged, results); err != nil {
+			return err
+		}
+		if buildkiteFile != "" {
+			if err := ioutil.WriteFile(buildkiteFile, buf.Bytes(), 0644); err != nil {
+				return err
+			}
+		}
+		if buildkiteAnnotate {
+			if err := annotateBuildkite(buf.Bytes(), len(o.failedPkgs) > 0); err != nil {
+				log.Printf("failed to annotate the build: %v", err)
+			}
+		}
+	}
+	stats := statementStats(merged)
+	total := stats.percent()
+	var decreaseErr error
+	if maxDecrease >= 0 {
+		baseline, ok, err := baselineCoverage(baselineFile, state, o.baseline, o.cfg)
+		if err != nil {
+			return err
+		}
+		if ok {
+			decreaseErr = checkDecrease(total, baseline, maxDecrease)
+		} else {
+			log.Printf("no baseline coverage for -max-decrease yet")
+		}
+	}
+	state.update(results)
+	// Do not record the baseline of a run which failed.
+	if len(o.failedPkgs) == 0 && decreaseErr == nil {
+		state.raiseBaseline(o.baseline, total)
+	}
+	state.Flags = setFlags(flag.CommandLine)
+	state.WorkDir, state.ModuleRoot = o.workDir, o.modRoot
+	if !noState {
+		if err := state.save(stateDir); err != nil {
+			log.Printf("failed to save run state: %v", err)
+		}
+		if err := updateImpactDB(stateDir, results); err != nil {
+			log.Printf("failed to update the impact database: %v", err)
+		}
+	}
+	if failuresJSON != "" {
+		if err := writeFailuresJSON(failuresJSON, results, append(o.skipped, skippedResults(o.pkgs, results)...), o.vcs); err != nil {
+			return err
+		}
+	}
+	if perTestFile != "" {
+		if err := writePerTestFile(perTestFile, results, rewrites); err != nil {
+			return err
+		}
+	}
+	if testMapFile != "" {
+		if err := writeTestMap(testMapFile, results, rewrites); err != nil {
+			return err
+		}
+	}
+	if retries > 0 || detectFlaky > 1 {
+		ss := testStabilities(results)
+		writeFlakySummary(testOutput, ss)
+		if flakyReportFile != "" {
+			if err := writeJSONFile(flakyReportFile, flakyReport{vcsInfo: o.vcs, Tests: ss}); err != nil {
+				return err
+			}
+		}
+	}
+	if tapFile != "" {
+		if err := writeTAPFile(tapFile, o.importPaths, results); err != nil {
+			return err
+		}
+	}
+	if o.tr != nil {
+		o.tr.setResult(total, len(o.failedPkgs))
+	}
+	if len(o.failedPkgs) > 0 || len(o.buildFailedPkgs) > 0 {
+		writeFailureSummary(testOutput, results)
+	}
+	if len(o.buildFailedPkgs) > 0 {
+		return &BuildError{Packages: o.buildFailedPkgs}
+	}
+	if len(o.failedPkgs) > 0 {
+		return &TestFailure{Packages: o.failedPkgs}
+	}
+	return decreaseErr
 }
 
 // buildOptionalTestArgs returns common optional args for go test regardless
@@ -155,68 +917,221 @@ func buildOptionalTestArgs(coverpkg, covermode, cpu, parallel, timeout string, s
 	if race {
 		args = append(args, "-race")
 	}
-	return args
-}
-
-// getPkgs returns packages for mesuring coverage. Returned packages doesn't
-// contain vendor packages.
-func getPkgs(pkg string) ([]string, error) {
-	if pkg == "" {
-		pkg = "./..."
+	if failfast {
+		args = append(args, "-failfast")
 	}
-	out, err := exec.Command("go", "list", pkg).CombinedOutput()
-	if err != nil {
-		return nil, err
+	if tags != "" {
+		args = append(args, "-tags", tags)
 	}
-	allPkgs := strings.Split(strings.Trim(string(out), "\n"), "\n")
-	pkgs := make([]string, 0, len(allPkgs))
-	for _, p := range allPkgs {
-		if !(strings.Contains(p, "/vendor/") || strings.HasPrefix(p, "vendor/")) {
-			pkgs = append(pkgs, p)
-		}
+	if benchOnly {
+		args = append(args, "-run", "^$", "-bench", ".")
 	}
-	return pkgs, nil
+	if examplesOnly {
+		args = append(args, "-run", "^Example")
+	}
+	return args
 }
 
-// coverage runs test for the given pkg and returns cover profile.
-// success indicates "go test" succeeded or not. coverage may return profiles
-// even when success=false. When "go test" fails, coverage outputs "go test"
-// result to stdout even when verbose=false.
-func coverage(pkg string, optArgs []string, verbose bool) (profiles []*cover.Profile, success bool, err error) {
+// testPackage runs tests for the package p with its hooks configured in cfg.
+// If nativeDir is not empty, binary coverage data is also written to the
+// directory for merging by "go tool covdata". See coverage for the returned
+// values.
+func testPackage(cfg *config, p *listPackage, optArgs []string, nativeDir string, verbose bool) (r *packageResult, err error) {
 	coverprofile, err := tmpProfileName()
 	if err != nil {
-		return nil, false, err
+		return nil, err
 	}
 	// Remove coverprofile created by "go test".
 	defer os.Remove(coverprofile)
+	env := testEnv(cfg, p)
+	if useTestJSON() {
+		optArgs = append(optArgs[:len(optArgs):len(optArgs)], "-json")
+	}
+	var covdir string
+	if nativeDir != "" {
+		// Test binary flags must be the last.
+		optArgs = append(optArgs[:len(optArgs):len(optArgs)], "-args", "-test.gocoverdir="+nativeDir)
+		if subprocessCoverage {
+			// Subprocesses can write coverage data into the same directory.
+			env = append(env, "GOCOVERDIR="+nativeDir)
+		}
+	} else if subprocessCoverage {
+		if covdir, err = ioutil.TempDir("", "goverage-covdata"); err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(covdir)
+		env = append(env, "GOCOVERDIR="+covdir)
+	}
+	hookEnv := append([]string{
+		"GOVERAGE_PACKAGE=" + p.ImportPath,
+		"GOVERAGE_PACKAGE_DIR=" + p.Dir,
+		"GOVERAGE_PROFILE=" + coverprofile,
+	}, env...)
+	// Post hooks run even if a pre hook fails or skips tests, so that they can
+	// clean up what pre hooks set up.
+	defer func() {
+		if _, herr := runHooks(cfg.postHooks(p), cfg.dir, hookEnv); herr != nil {
+			if r != nil {
+				r.Success = false
+			}
+			if err == nil {
+				err = herr
+			}
+		}
+	}()
+	skip, err := runHooks(cfg.preHooks(p), cfg.dir, hookEnv)
+	if err != nil {
+		return &packageResult{Pkg: p.ImportPath, ExitCode: -1}, err
+	}
+	if skip {
+		log.Printf("skip tests for package %q by pre hook", p.ImportPath)
+		return &packageResult{Pkg: p.ImportPath, Success: true, Skipped: true}, nil
+	}
+	if perTestFile != "" || testMapFile != "" || impact {
+		r, err = coveragePerTest(p.ImportPath, testDir(p), coverprofile, cfg.runPattern(p), optArgs, env, verbose)
+	} else {
+		r, err = coverage(p.ImportPath, testDir(p), coverprofile, optArgs, env, verbose)
+	}
+	if r != nil && covdir != "" {
+		sub, cerr := covdataProfiles(covdir)
+		if cerr != nil {
+			log.Printf("failed to collect coverage of subprocesses for package %q: %v", p.ImportPath, cerr)
+		}
+		r.Profiles = mergeProfiles([][]*cover.Profile{r.Profiles, sub})
+	}
+	return r, err
+}
+
+// packageResult is the result of running "go test" for a package.
+type packageResult struct {
+	Pkg      string
+	Profiles []*cover.Profile
+	// Success indicates "go test" succeeded or not.
+	Success bool
+	// ExitCode is the exit status of "go test". It's -1 when the process
+	// could not be started or was killed by a signal.
+	ExitCode int
+	Stdout   []byte
+	Stderr   []byte
+	Duration time.Duration
+	// Cached is true if the result is reused from the profile cache.
+	Cached bool
+	// Skipped is true if tests are skipped by a pre hook.
+	Skipped bool
+	// TestProfiles maps names of top-level tests to their profiles with
+	// -per-test.
+	TestProfiles map[string][]*cover.Profile
+	// Events is events of "go test -json" with -test-json-output, -retries or
+	// -detect-flaky. Stdout is the plain text output reconstructed from them.
+	// See parseTestJSON.
+	Events [][]byte
+	// Attempts is outcomes of tests in each attempt with -retries or
+	// -detect-flaky.
+	Attempts []testOutcomes
+}
+
+// output returns combined output of "go test".
+func (r *packageResult) output() []byte {
+	return append(append([]byte{}, r.Stdout...), r.Stderr...)
+}
+
+// coverage runs test for the given pkg and returns its result including cover
+// profiles written to coverprofile. coverage may return profiles even when
+// "go test" failed. When "go test" fails, coverage outputs "go test" result to
+// stdout even when verbose=false. env is added to the environment of "go
+// test", which runs in dir, or the working directory if it's empty. The
+// returned result is non-nil when "go test" was run, even if err is not nil.
+func coverage(pkg, dir, coverprofile string, optArgs, env []string, verbose bool) (*packageResult, error) {
 	args := append([]string{"test", pkg, "-coverprofile", coverprofile}, optArgs...)
 	cmd := exec.Command(gobinary, args...)
+	cmd.Dir = dir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	var host, remoteProfile, container string
+	if workers != nil {
+		host = workers.acquire()
+		defer workers.release(host)
+		remoteProfile = workers.remoteProfile(coverprofile)
+		cmd = workers.command(host, remoteArgs(args, coverprofile, remoteProfile), env)
+	} else if dockerImage != "" {
+		var err error
+		if cmd, container, err = dockerCommand(dockerImage, moduleRoot, args, env, noNetwork); err != nil {
+			return nil, err
+		}
+	} else if noNetwork {
+		isolateNetwork(cmd)
+	}
 	stdout := new(bytes.Buffer)
 	stderr := new(bytes.Buffer)
-	if verbose {
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+	mode := resolveOutputMode(outputMode, verbose)
+	// Output of "go test -json" is printed as plain text after the test.
+	tee := mode == outputStream && !useTestJSON()
+	if tee {
+		cmd.Stdout = io.MultiWriter(testOutput, stdout)
+		cmd.Stderr = io.MultiWriter(os.Stderr, stderr)
 	} else {
 		cmd.Stdout = stdout
 		cmd.Stderr = stderr
 	}
-	if err := cmd.Run(); err != nil {
-		fmt.Fprint(os.Stdout, stdout.String())
-		fmt.Fprint(os.Stderr, stderr.String())
+	r := &packageResult{Pkg: pkg}
+	start := time.Now()
+	var kill func()
+	if container != "" {
+		kill = func() { killContainer(container) }
+	}
+	err := testCmds.runWithKill(cmd, kill)
+	r.Duration = time.Since(start)
+	r.Stdout = stdout.Bytes()
+	r.Stderr = stderr.Bytes()
+	if useTestJSON() {
+		r.Events, r.Stdout = parseTestJSON(pkg, r.Stdout, r.Stderr)
+		r.Stderr = nil
+	}
+	r.ExitCode = exitCode(err)
+	var fetchErr error
+	if host != "" {
+		// The profile is not written if tests are not built or there are no
+		// tests.
+		fetchErr = workers.fetch(host, remoteProfile, coverprofile)
+	}
+	if !tee && (verbose || err != nil) {
+		printOutput(r, mode == outputGroup)
+	}
+	if fetchErr != nil {
+		return r, fetchErr
+	}
+	if err != nil {
 		// "go test" can creates coverprofile even when "go test" failes, so do not
 		// return error here if coverprofile is created.
 		if !isExist(coverprofile) {
-			return nil, false, fmt.Errorf("failed to run 'go test %v': %v", pkg, err)
+			return r, fmt.Errorf("failed to run 'go test %v': %v", pkg, err)
 		}
 	} else {
+		r.Success = true
 		if !isExist(coverprofile) {
 			// There are no test and coverprofile is not created.
-			return nil, true, nil
+			return r, nil
 		}
-		success = true
 	}
-	profiles, err = cover.ParseProfiles(coverprofile)
-	return profiles, success, err
+	r.Profiles, err = cover.ParseProfiles(coverprofile)
+	if err != nil {
+		return r, &ProfileParseError{File: coverprofile, Err: err}
+	}
+	return r, nil
+}
+
+// exitCode returns exit status from the error returned by exec.Cmd.Run.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			return status.ExitStatus()
+		}
+	}
+	return -1
 }
 
 func tmpProfileName() (string, error) {
@@ -238,27 +1153,25 @@ func isExist(filename string) bool {
 	return err == nil
 }
 
-// mergeProfiles merges cover profiles. It assumes target packages of each
-// cover profile are same and sorted.
+// mergeProfiles merges cover profiles. Blocks of the same file are merged by
+// their positions, so profiles of the same file don't have to consist of same
+// blocks, though they usually do since every package is tested with the same
+// coverpkg.
 func mergeProfiles(cpss [][]*cover.Profile) []*cover.Profile {
 	// File name to profile.
 	profiles := map[string]*cover.Profile{}
 	for _, ps := range cpss {
 		for _, p := range ps {
 			if _, ok := profiles[p.FileName]; !ok {
-				// Insert profile.
-				profiles[p.FileName] = p
-				continue
-			}
-			// Merge blocks.
-			for i, block := range p.Blocks {
-				switch p.Mode {
-				case "set":
-					profiles[p.FileName].Blocks[i].Count |= block.Count
-				case "count", "atomic":
-					profiles[p.FileName].Blocks[i].Count += block.Count
+				// Insert a copy of profile not to modify the given one.
+				profiles[p.FileName] = &cover.Profile{
+					FileName: p.FileName,
+					Mode:     p.Mode,
+					Blocks:   append([]cover.ProfileBlock(nil), p.Blocks...),
 				}
+				continue
 			}
+			mergeBlocks(profiles[p.FileName], p)
 		}
 	}
 	result := make([]*cover.Profile, 0, len(profiles))
@@ -271,6 +1184,116 @@ func mergeProfiles(cpss [][]*cover.Profile) []*cover.Profile {
 	return result
 }
 
+// mergeBlocks merges blocks of src into dst.
+func mergeBlocks(dst, src *cover.Profile) {
+	if sameBlocks(dst.Blocks, src.Blocks) {
+		for i, block := range src.Blocks {
+			dst.Blocks[i].Count = mergeCount(src.Mode, dst.Blocks[i].Count, block.Count)
+		}
+		return
+	}
+	index := make(map[blockPos]int, len(dst.Blocks))
+	for i, b := range dst.Blocks {
+		index[posOf(b)] = i
+	}
+	for _, block := range src.Blocks {
+		if i, ok := index[posOf(block)]; ok {
+			dst.Blocks[i].Count = mergeCount(src.Mode, dst.Blocks[i].Count, block.Count)
+			continue
+		}
+		dst.Blocks = append(dst.Blocks, block)
+	}
+	sort.Slice(dst.Blocks, func(i, j int) bool {
+		bi, bj := dst.Blocks[i], dst.Blocks[j]
+		return bi.StartLine < bj.StartLine || bi.StartLine == bj.StartLine && bi.StartCol < bj.StartCol
+	})
+}
+
+// checkModes returns an error if profiles in cpss have different modes and
+// coerce, a mode to convert them to, is empty. Otherwise, it returns cpss
+// converted to coerce. See coerceMode.
+func checkModes(cpss [][]*cover.Profile, coerce string) ([][]*cover.Profile, error) {
+	switch coerce {
+	case "", "set", "count":
+	default:
+		return nil, fmt.Errorf("invalid -coerce-mode %q: must be set or count", coerce)
+	}
+	modes := make(map[string]bool)
+	for _, ps := range cpss {
+		for _, p := range ps {
+			modes[p.Mode] = true
+		}
+	}
+	if len(modes) <= 1 && coerce == "" {
+		return cpss, nil
+	}
+	if coerce == "" {
+		var ms []string
+		for m := range modes {
+			ms = append(ms, m)
+		}
+		sort.Strings(ms)
+		return nil, fmt.Errorf("cannot merge profiles of different modes %s; use -coerce-mode", strings.Join(ms, ", "))
+	}
+	result := make([][]*cover.Profile, len(cpss))
+	for i, ps := range cpss {
+		result[i] = make([]*cover.Profile, len(ps))
+		for j, p := range ps {
+			result[i][j] = coerceMode(p, coerce)
+		}
+	}
+	return result, nil
+}
+
+// coerceMode returns a copy of the profile converted to the mode. Converting
+// to "set" marks blocks executed at least once as 1. Converting "set" to
+// "count" keeps counts as is, so that a covered block counts as executed once.
+// "atomic" profiles have the same counts as "count".
+func coerceMode(p *cover.Profile, mode string) *cover.Profile {
+	c := &cover.Profile{FileName: p.FileName, Mode: mode, Blocks: append([]cover.ProfileBlock(nil), p.Blocks...)}
+	if mode == "set" {
+		for i := range c.Blocks {
+			if c.Blocks[i].Count > 0 {
+				c.Blocks[i].Count = 1
+			}
+		}
+	}
+	return c
+}
+
+func mergeCount(mode string, x, y int) int {
+	switch mode {
+	case "set":
+		return x | y
+	case "count", "atomic":
+		return x + y
+	}
+	return x
+}
+
+// blockPos is the position of a block in a file.
+type blockPos struct {
+	startLine, startCol, endLine, endCol int
+}
+
+func posOf(b cover.ProfileBlock) blockPos {
+	return blockPos{b.StartLine, b.StartCol, b.EndLine, b.EndCol}
+}
+
+// sameBlocks reports whether xs and ys consist of blocks at the same
+// positions.
+func sameBlocks(xs, ys []cover.ProfileBlock) bool {
+	if len(xs) != len(ys) {
+		return false
+	}
+	for i := range xs {
+		if posOf(xs[i]) != posOf(ys[i]) {
+			return false
+		}
+	}
+	return true
+}
+
 // dumpcp dumps cover profile result to io.Writer.
 func dumpcp(w io.Writer, cps []*cover.Profile) {
 	if len(cps) == 0 {
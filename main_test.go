@@ -1,9 +1,15 @@
 package main
 
 import (
+	"errors"
 	"io/ioutil"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+
+	"golang.org/x/tools/cover"
 )
 
 func TestRun(t *testing.T) {
@@ -54,7 +60,8 @@ func TestRun_with_test_failed(t *testing.T) {
 	}
 	defer os.Chdir(wd)
 	err = run(tmpfile.Name(), []string{"./..."}, "", "", "", "", false, true)
-	if err, ok := err.(*ExitError); !ok || err.Code != 1 {
+	var testFailure *TestFailure
+	if !errors.As(err, &testFailure) || exitStatus(err) != 1 {
 		t.Fatalf("unexpected error: %v", err)
 	}
 	b, err := ioutil.ReadFile(tmpfile.Name())
@@ -71,3 +78,200 @@ func TestRun_with_test_failed(t *testing.T) {
 		t.Errorf("got:\n%v\nwant:\n%v", got, want)
 	}
 }
+
+func TestRun_native_merge(t *testing.T) {
+	if !goSupportsCovdata() {
+		t.Skip("go tool covdata is not available")
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("./example/root"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	profiles := make([]string, 2)
+	for i, native := range []bool{false, true} {
+		tmpfile, err := ioutil.TempFile("", "goverage-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(tmpfile.Name())
+		nativeMerge = native
+		err = run(tmpfile.Name(), []string{"./..."}, "count", "", "", "", false, false)
+		nativeMerge = false
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadFile(tmpfile.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		profiles[i] = string(b)
+	}
+	if profiles[0] != profiles[1] {
+		t.Errorf("text merge:\n%v\nnative merge:\n%v", profiles[0], profiles[1])
+	}
+}
+
+func TestRun_append(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("./example/root"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	tmpfile, err := ioutil.TempFile("", "goverage-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	if err := run(tmpfile.Name(), []string{"./..."}, "count", "", "", "", false, false); err != nil {
+		t.Fatal(err)
+	}
+	once, err := cover.ParseProfiles(tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendProfile = true
+	defer func() { appendProfile = false }()
+	if err := run(tmpfile.Name(), []string{"./..."}, "count", "", "", "", false, false); err != nil {
+		t.Fatal(err)
+	}
+	twice, err := cover.ParseProfiles(tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range once {
+		for j := range p.Blocks {
+			p.Blocks[j].Count *= 2
+		}
+		if !reflect.DeepEqual(p, twice[i]) {
+			t.Errorf("appended profile of %s: got %v, want %v", p.FileName, twice[i].Blocks, p.Blocks)
+		}
+	}
+	if err := run(tmpfile.Name(), []string{"./..."}, "set", "", "", "", false, false); err == nil {
+		t.Error("want error for appending profile of another mode")
+	}
+	if ps, err := cover.ParseProfiles(tmpfile.Name()); err != nil || !reflect.DeepEqual(ps, twice) {
+		t.Errorf("profile should be kept on error: %v", err)
+	}
+}
+
+func TestRun_tapStdout(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("./example/root"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	tmpfile, err := ioutil.TempFile("", "goverage-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout, testOutput = w, w
+	tapFile = "-"
+	defer func() {
+		os.Stdout = stdout
+		tapFile = ""
+		testOutput = os.Stdout
+	}()
+	done := make(chan []byte)
+	go func() {
+		b, _ := ioutil.ReadAll(r)
+		done <- b
+	}()
+	err = run(tmpfile.Name(), []string{"./..."}, "count", "", "", "", false, false)
+	w.Close()
+	out := <-done
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	if len(lines) < 2 || lines[0] != "TAP version 13" || !strings.HasPrefix(lines[1], "1..") {
+		t.Fatalf("stdout is not TAP:\n%s", out)
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(lines[1], "1.."))
+	if err != nil {
+		t.Fatalf("invalid plan %q", lines[1])
+	}
+	var points int
+	for _, l := range lines[2:] {
+		if !strings.HasPrefix(l, "ok ") && !strings.HasPrefix(l, "not ok ") {
+			t.Errorf("unexpected line in TAP: %q", l)
+			continue
+		}
+		points++
+	}
+	if points != n || n == 0 {
+		t.Errorf("got %d test points, want %d in the plan", points, n)
+	}
+}
+
+func TestMergeProfiles(t *testing.T) {
+	block := func(startLine, count int) cover.ProfileBlock {
+		return cover.ProfileBlock{StartLine: startLine, StartCol: 1, EndLine: startLine + 1, EndCol: 2, NumStmt: 1, Count: count}
+	}
+	cpss := [][]*cover.Profile{
+		{
+			{FileName: "a.go", Mode: "count", Blocks: []cover.ProfileBlock{block(1, 1), block(5, 0)}},
+		},
+		{
+			{FileName: "a.go", Mode: "count", Blocks: []cover.ProfileBlock{block(1, 2), block(5, 1)}},
+			{FileName: "b.go", Mode: "count", Blocks: []cover.ProfileBlock{block(1, 1)}},
+		},
+		{
+			// Blocks which doesn't align with others. e.g. from a subprocess.
+			{FileName: "a.go", Mode: "count", Blocks: []cover.ProfileBlock{block(3, 4), block(5, 1)}},
+		},
+	}
+	got := mergeProfiles(cpss)
+	want := []*cover.Profile{
+		{FileName: "a.go", Mode: "count", Blocks: []cover.ProfileBlock{block(1, 3), block(3, 4), block(5, 2)}},
+		{FileName: "b.go", Mode: "count", Blocks: []cover.ProfileBlock{block(1, 1)}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestBuildOptionalTestArgs_benchOnly(t *testing.T) {
+	defer func(b bool) { benchOnly = b }(benchOnly)
+	benchOnly = true
+	got := buildOptionalTestArgs("a,b", "count", "", "", "", false, false)
+	want := []string{"-coverpkg", "a,b", "-covermode", "count", "-run", "^$", "-bench", "."}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	defer func(r int) { retries = r }(retries)
+	retries = 1
+	if err := checkFlagConflicts(""); err == nil {
+		t.Error("want an error for -bench-only with -retries")
+	}
+}
+
+func TestBuildOptionalTestArgs_examplesOnly(t *testing.T) {
+	defer func(e bool) { examplesOnly = e }(examplesOnly)
+	examplesOnly = true
+	got := buildOptionalTestArgs("a", "", "", "", "", false, false)
+	want := []string{"-coverpkg", "a", "-run", "^Example"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	defer func(b bool) { benchOnly = b }(benchOnly)
+	benchOnly = true
+	if err := checkFlagConflicts(""); err == nil {
+		t.Error("want an error for -examples-only with -bench-only")
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	"golang.org/x/tools/cover"
+)
+
+// Orders of rows of the Markdown table.
+const (
+	mdSortName       = "name"
+	mdSortCoverage   = "coverage"
+	mdSortDelta      = "delta"
+	mdSortStatements = "statements"
+)
+
+// mdRow is a row of the Markdown table.
+type mdRow struct {
+	stats *coverStats
+	// delta is the difference of coverage from the baseline in percentage
+	// points. hasBase is false if the package is not in the baseline.
+	delta   float64
+	hasBase bool
+}
+
+// writeMarkdownTable writes a Markdown table of coverage per package in
+// profiles. If baseline is not nil, it also has differences from the
+// baseline. Rows are sorted by sortBy: name, coverage (the lowest first),
+// delta (the largest decrease first) or statements (the most first). If limit
+// is positive, rows after limit are omitted with a row of their number.
+func writeMarkdownTable(w io.Writer, profiles, baseline []*cover.Profile, sortBy string, limit int) error {
+	base := make(map[string]float64)
+	for _, s := range packageStats(baseline) {
+		base[s.Name] = s.percent()
+	}
+	var rows []mdRow
+	for _, s := range packageStats(profiles) {
+		r := mdRow{stats: s}
+		if p, ok := base[s.Name]; ok {
+			r.delta, r.hasBase = s.percent()-p, true
+		}
+		rows = append(rows, r)
+	}
+	var less func(a, b mdRow) bool
+	switch sortBy {
+	case mdSortName, "":
+		// packageStats returns them sorted by names.
+	case mdSortCoverage:
+		less = func(a, b mdRow) bool { return a.stats.percent() < b.stats.percent() }
+	case mdSortDelta:
+		less = func(a, b mdRow) bool { return a.hasBase && (!b.hasBase || a.delta < b.delta) }
+	case mdSortStatements:
+		less = func(a, b mdRow) bool { return a.stats.Total > b.stats.Total }
+	default:
+		return fmt.Errorf("unknown sort order %q: must be name, coverage, delta or statements", sortBy)
+	}
+	if less != nil {
+		sort.SliceStable(rows, func(i, j int) bool { return less(rows[i], rows[j]) })
+	}
+	withDelta := baseline != nil
+	var b bytes.Buffer
+	if withDelta {
+		fmt.Fprintln(&b, "| Package | Coverage | Δ | Statements |")
+		fmt.Fprintln(&b, "| --- | ---: | ---: | ---: |")
+	} else {
+		fmt.Fprintln(&b, "| Package | Coverage | Statements |")
+		fmt.Fprintln(&b, "| --- | ---: | ---: |")
+	}
+	row := func(name string, s *coverStats, delta string) {
+		if withDelta {
+			fmt.Fprintf(&b, "| %s | %.1f%% | %s | %d/%d |\n", name, s.percent(), delta, s.Covered, s.Total)
+		} else {
+			fmt.Fprintf(&b, "| %s | %.1f%% | %d/%d |\n", name, s.percent(), s.Covered, s.Total)
+		}
+	}
+	for i, r := range rows {
+		if limit > 0 && i == limit {
+			fmt.Fprintf(&b, "| … %d more package(s) |", len(rows)-limit)
+			if withDelta {
+				fmt.Fprint(&b, " |")
+			}
+			fmt.Fprintln(&b, " | |")
+			break
+		}
+		delta := "new"
+		if r.hasBase {
+			delta = fmt.Sprintf("%+.1f%%", r.delta)
+		}
+		row("`"+r.stats.Name+"`", r.stats, delta)
+	}
+	total := statementStats(profiles)
+	baseTotal := statementStats(baseline)
+	row("**Total**", &total, fmt.Sprintf("%+.1f%%", total.percent()-baseTotal.percent()))
+	_, err := b.WriteTo(w)
+	return err
+}
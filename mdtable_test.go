@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestWriteMarkdownTable(t *testing.T) {
+	profile := func(name string, covered, uncovered int) *cover.Profile {
+		return &cover.Profile{FileName: name, Blocks: []cover.ProfileBlock{{NumStmt: covered, Count: 1}, {NumStmt: uncovered}}}
+	}
+	profiles := []*cover.Profile{
+		profile("example.com/a/a.go", 3, 1),
+		profile("example.com/b/b.go", 1, 1),
+		profile("example.com/c/c.go", 9, 1),
+	}
+	baseline := []*cover.Profile{
+		profile("example.com/a/a.go", 1, 1),
+		profile("example.com/b/b.go", 3, 1),
+	}
+	tests := []struct {
+		name     string
+		baseline []*cover.Profile
+		sortBy   string
+		limit    int
+		want     string
+	}{
+		{
+			name: "name",
+			want: "" +
+				"| Package | Coverage | Statements |\n" +
+				"| --- | ---: | ---: |\n" +
+				"| `example.com/a` | 75.0% | 3/4 |\n" +
+				"| `example.com/b` | 50.0% | 1/2 |\n" +
+				"| `example.com/c` | 90.0% | 9/10 |\n" +
+				"| **Total** | 81.2% | 13/16 |\n",
+		},
+		{
+			name:   "coverage with limit",
+			sortBy: mdSortCoverage,
+			limit:  1,
+			want: "" +
+				"| Package | Coverage | Statements |\n" +
+				"| --- | ---: | ---: |\n" +
+				"| `example.com/b` | 50.0% | 1/2 |\n" +
+				"| … 2 more package(s) | | |\n" +
+				"| **Total** | 81.2% | 13/16 |\n",
+		},
+		{
+			name:     "delta",
+			baseline: baseline,
+			sortBy:   mdSortDelta,
+			want: "" +
+				"| Package | Coverage | Δ | Statements |\n" +
+				"| --- | ---: | ---: | ---: |\n" +
+				"| `example.com/b` | 50.0% | -25.0% | 1/2 |\n" +
+				"| `example.com/a` | 75.0% | +25.0% | 3/4 |\n" +
+				"| `example.com/c` | 90.0% | new | 9/10 |\n" +
+				"| **Total** | 81.2% | +14.6% | 13/16 |\n",
+		},
+		{
+			name:   "statements",
+			sortBy: mdSortStatements,
+			limit:  5,
+			want: "" +
+				"| Package | Coverage | Statements |\n" +
+				"| --- | ---: | ---: |\n" +
+				"| `example.com/c` | 90.0% | 9/10 |\n" +
+				"| `example.com/a` | 75.0% | 3/4 |\n" +
+				"| `example.com/b` | 50.0% | 1/2 |\n" +
+				"| **Total** | 81.2% | 13/16 |\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeMarkdownTable(&buf, profiles, tt.baseline, tt.sortBy, tt.limit); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+	if err := writeMarkdownTable(&bytes.Buffer{}, profiles, nil, "size", 0); err == nil {
+		t.Error("got nil error for an unknown sort order")
+	}
+}
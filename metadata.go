@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"golang.org/x/tools/cover"
+)
+
+// runMetadata describes a run of goverage, so that archived profiles are
+// self-describing. It's written to the sidecar file of -metadata since
+// comment lines are not allowed in profiles.
+type runMetadata struct {
+	Profile   string `json:"profile"`
+	Goverage  string `json:"goverage_version"`
+	Go        string `json:"go_version,omitempty"`
+	Covermode string `json:"covermode"`
+	vcsInfo
+	Time string `json:"time"`
+}
+
+// newRunMetadata returns metadata of the run which wrote profiles to the
+// profile file at the time in the repository of vcs. The Go version is
+// omitted if it cannot be got.
+func newRunMetadata(profile string, profiles []*cover.Profile, mode string, vcs vcsInfo, now time.Time) *runMetadata {
+	m := &runMetadata{
+		Profile:   profile,
+		Goverage:  readBuildVersion().Version,
+		Covermode: mode,
+		vcsInfo:   vcs,
+		Time:      now.UTC().Format(time.RFC3339),
+	}
+	if len(profiles) > 0 {
+		m.Covermode = profiles[0].Mode
+	}
+	if m.Covermode == "" {
+		m.Covermode = "set"
+	}
+	if v, err := goVersion(); err == nil {
+		m.Go = strings.TrimPrefix(v, "go version ")
+	}
+	return m
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestNewRunMetadata(t *testing.T) {
+	now := time.Date(2024, 3, 14, 18, 26, 53, 0, time.FixedZone("JST", 9*60*60))
+	m := newRunMetadata("coverage.out", []*cover.Profile{{FileName: "a/a.go", Mode: "count"}}, "", vcsInfo{Revision: "abc", Branch: "main"}, now)
+	if m.Profile != "coverage.out" || m.Goverage != readBuildVersion().Version || m.Covermode != "count" || m.Time != "2024-03-14T09:26:53Z" || m.Revision != "abc" {
+		t.Errorf("got %+v", m)
+	}
+	if m.Go == "" {
+		t.Error("got empty Go version")
+	}
+	if m := newRunMetadata("coverage.out", nil, "atomic", vcsInfo{}, now); m.Covermode != "atomic" {
+		t.Errorf("got covermode %q, want atomic", m.Covermode)
+	}
+	if m := newRunMetadata("coverage.out", nil, "", vcsInfo{}, now); m.Covermode != "set" {
+		t.Errorf("got covermode %q, want set", m.Covermode)
+	}
+}
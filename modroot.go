@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+)
+
+// findModuleRoot returns the nearest directory of dir and its parents which
+// has go.mod. It's empty if there is none, e.g. in GOPATH mode.
+func findModuleRoot(dir string) string {
+	for {
+		if fi, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil && !fi.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// setFlagNames returns names of flags set in fs.
+func setFlagNames(fs *flag.FlagSet) map[string]bool {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	return set
+}
+
+// fromRoot returns the relative filename of the flag joined to root unless
+// the flag is set explicitly. It lets default outputs and configs of goverage
+// run in a subdirectory be the ones in the module root, while paths given by
+// users stay relative to the working directory as shells complete them.
+func fromRoot(set map[string]bool, name, filename, root string) string {
+	if set[name] || filename == "" || filepath.IsAbs(filename) {
+		return filename
+	}
+	return filepath.Join(root, filename)
+}
+
+// subdirModuleRoot returns the module root if the working directory is a
+// subdirectory of it, or an empty string otherwise.
+func subdirModuleRoot() string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	if root := findModuleRoot(wd); root != wd {
+		return root
+	}
+	return ""
+}
+
+// fromModuleRoot returns the filename of the flag of fs by fromRoot in the
+// module root of the working directory, so that subcommands find outputs of
+// goverage run in any subdirectory.
+func fromModuleRoot(fs *flag.FlagSet, name, filename string) string {
+	if root := subdirModuleRoot(); root != "" {
+		return fromRoot(setFlagNames(fs), name, filename, root)
+	}
+	return filename
+}
+
+// configFromRoot returns filename, or the default config in the module root
+// of the working directory if filename is empty and the config exists there.
+func configFromRoot(filename string) string {
+	if filename != "" {
+		return filename
+	}
+	if root := subdirModuleRoot(); root != "" && isExist(filepath.Join(root, defaultConfigFile)) {
+		return filepath.Join(root, defaultConfigFile)
+	}
+	return filename
+}
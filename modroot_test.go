@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindModuleRoot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-modroot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "app")
+	sub := filepath.Join(root, "internal", "store")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/app\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := findModuleRoot(sub); got != root {
+		t.Errorf("findModuleRoot(%s) = %q, want %q", sub, got, root)
+	}
+	if got := findModuleRoot(root); got != root {
+		t.Errorf("findModuleRoot(%s) = %q, want %q", root, got, root)
+	}
+}
+
+func TestFromRoot(t *testing.T) {
+	root := "app"
+	abs, err := filepath.Abs("c.out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := map[string]bool{"state-dir": true}
+	tests := []struct {
+		name, filename, want string
+	}{
+		{"coverprofile", "coverage.out", filepath.Join(root, "coverage.out")},
+		{"coverprofile", abs, abs},
+		{"state-dir", ".goverage", ".goverage"},
+		{"config", "", ""},
+	}
+	for _, tt := range tests {
+		if got := fromRoot(set, tt.name, tt.filename, root); got != tt.want {
+			t.Errorf("fromRoot(%q, %q) = %q, want %q", tt.name, tt.filename, got, tt.want)
+		}
+	}
+}
+
+func TestConfigFromRoot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-modroot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dir, err = filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(dir, "internal")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"go.mod", defaultConfigFile} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(sub); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := configFromRoot(""), filepath.Join(dir, defaultConfigFile); got != want {
+		t.Errorf("configFromRoot(\"\") = %q, want %q", got, want)
+	}
+	if got := configFromRoot("my.yml"); got != "my.yml" {
+		t.Errorf("configFromRoot(%q) = %q, want it as is", "my.yml", got)
+	}
+	fs := newFlagSet("impact", "")
+	dirFlag := fs.String("state-dir", defaultStateDir, "")
+	if got, want := fromModuleRoot(fs, "state-dir", *dirFlag), filepath.Join(dir, defaultStateDir); got != want {
+		t.Errorf("fromModuleRoot() = %q, want %q", got, want)
+	}
+}
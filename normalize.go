@@ -0,0 +1,173 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"sort"
+
+	"golang.org/x/tools/cover"
+)
+
+// normalizeProfiles returns profiles whose overlapping blocks are split into
+// blocks at a common granularity. Blocks overlap when profiles of runs with
+// different Go versions or build tags, which instrument a file with different
+// block boundaries, are merged. Statements of split blocks are counted in the
+// source files resolved by resolve, and profiles whose sources cannot be read
+// are kept as is.
+func normalizeProfiles(profiles []*cover.Profile, resolve func(string) (string, error)) []*cover.Profile {
+	result := make([]*cover.Profile, len(profiles))
+	for i, p := range profiles {
+		result[i] = p
+		if !hasOverlaps(p.Blocks) {
+			continue
+		}
+		filename, err := resolve(p.FileName)
+		if err != nil {
+			log.Printf("cannot normalize overlapping blocks of %s: %v", p.FileName, err)
+			continue
+		}
+		starts, err := stmtStarts(filename)
+		if err != nil {
+			log.Printf("cannot normalize overlapping blocks of %s: %v", p.FileName, err)
+			continue
+		}
+		result[i] = &cover.Profile{
+			FileName: p.FileName,
+			Mode:     p.Mode,
+			Blocks:   normalizeBlocks(p.Mode, p.Blocks, starts),
+		}
+	}
+	return result
+}
+
+// srcPos is a position in a source file.
+type srcPos struct {
+	line, col int
+}
+
+func (p srcPos) before(q srcPos) bool {
+	return p.line < q.line || p.line == q.line && p.col < q.col
+}
+
+func blockStart(b cover.ProfileBlock) srcPos { return srcPos{b.StartLine, b.StartCol} }
+func blockEnd(b cover.ProfileBlock) srcPos   { return srcPos{b.EndLine, b.EndCol} }
+
+// hasOverlaps reports whether any of blocks, sorted by their starts, overlap.
+func hasOverlaps(blocks []cover.ProfileBlock) bool {
+	for i := 1; i < len(blocks); i++ {
+		if blockStart(blocks[i]).before(blockEnd(blocks[i-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeBlocks splits each group of overlapping blocks, sorted by their
+// starts, at the starts and the ends of the blocks in the group. The count of
+// a split block is merged from the blocks covering it, and its statements are
+// ones starting in it out of starts. Split blocks without statements are
+// dropped. Blocks which overlap with no others are kept as is.
+func normalizeBlocks(mode string, blocks []cover.ProfileBlock, starts []srcPos) []cover.ProfileBlock {
+	var result []cover.ProfileBlock
+	for i := 0; i < len(blocks); {
+		j, end := i+1, blockEnd(blocks[i])
+		for ; j < len(blocks) && blockStart(blocks[j]).before(end); j++ {
+			if end.before(blockEnd(blocks[j])) {
+				end = blockEnd(blocks[j])
+			}
+		}
+		if j == i+1 {
+			result = append(result, blocks[i])
+		} else {
+			result = append(result, splitBlocks(mode, blocks[i:j], starts)...)
+		}
+		i = j
+	}
+	return result
+}
+
+func splitBlocks(mode string, group []cover.ProfileBlock, starts []srcPos) []cover.ProfileBlock {
+	var bounds []srcPos
+	for _, b := range group {
+		bounds = append(bounds, blockStart(b), blockEnd(b))
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i].before(bounds[j]) })
+	var result []cover.ProfileBlock
+	for i := 1; i < len(bounds); i++ {
+		from, to := bounds[i-1], bounds[i]
+		if from == to {
+			continue
+		}
+		covered := false
+		count := 0
+		for _, b := range group {
+			if blockStart(b).before(to) && from.before(blockEnd(b)) {
+				count = mergeCount(mode, count, b.Count)
+				covered = true
+			}
+		}
+		numStmt := 0
+		for _, s := range starts {
+			if !s.before(from) && s.before(to) {
+				numStmt++
+			}
+		}
+		if !covered || numStmt == 0 {
+			continue
+		}
+		result = append(result, cover.ProfileBlock{
+			StartLine: from.line,
+			StartCol:  from.col,
+			EndLine:   to.line,
+			EndCol:    to.col,
+			NumStmt:   numStmt,
+			Count:     count,
+		})
+	}
+	return result
+}
+
+// stmtStarts returns the starts of statements in statement lists of the
+// source file, which are the statements counted by the cover tool.
+func stmtStarts(filename string) ([]srcPos, error) {
+	extents, err := stmtExtents(filename)
+	if err != nil {
+		return nil, err
+	}
+	starts := make([]srcPos, len(extents))
+	for i, e := range extents {
+		starts[i] = e[0]
+	}
+	return starts, nil
+}
+
+// stmtExtents returns the starts and the ends of statements counted by the
+// cover tool in the source file. See stmtStarts.
+func stmtExtents(filename string) ([][2]srcPos, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	var extents [][2]srcPos
+	add := func(stmts []ast.Stmt) {
+		for _, s := range stmts {
+			start, end := fset.Position(s.Pos()), fset.Position(s.End())
+			extents = append(extents, [2]srcPos{{start.Line, start.Column}, {end.Line, end.Column}})
+		}
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			add(n.List)
+		case *ast.CaseClause:
+			add(n.Body)
+		case *ast.CommClause:
+			add(n.Body)
+		}
+		return true
+	})
+	return extents, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestNormalizeProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-normalize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := `package p
+
+func f(x int) int {
+	y := x
+	if x > 0 {
+		return 1
+	}
+	return y
+}
+`
+	filename := filepath.Join(dir, "p.go")
+	if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	resolve := func(string) (string, error) { return filename, nil }
+
+	// Blocks of an older Go version merged with ones of a newer Go version.
+	overlapping := &cover.Profile{FileName: "example.com/p/p.go", Mode: "count", Blocks: []cover.ProfileBlock{
+		{StartLine: 3, StartCol: 19, EndLine: 5, EndCol: 11, NumStmt: 2, Count: 1},
+		{StartLine: 4, StartCol: 2, EndLine: 4, EndCol: 8, NumStmt: 1, Count: 2},
+		{StartLine: 5, StartCol: 2, EndLine: 5, EndCol: 11, NumStmt: 1, Count: 2},
+		{StartLine: 5, StartCol: 11, EndLine: 7, EndCol: 3, NumStmt: 1, Count: 1},
+		{StartLine: 6, StartCol: 3, EndLine: 6, EndCol: 11, NumStmt: 1, Count: 0},
+		{StartLine: 7, StartCol: 3, EndLine: 8, EndCol: 10, NumStmt: 1, Count: 0},
+		{StartLine: 8, StartCol: 2, EndLine: 8, EndCol: 10, NumStmt: 1, Count: 3},
+	}}
+	aligned := &cover.Profile{FileName: "example.com/p/q.go", Mode: "count", Blocks: []cover.ProfileBlock{
+		{StartLine: 3, StartCol: 19, EndLine: 5, EndCol: 11, NumStmt: 2, Count: 1},
+		{StartLine: 5, StartCol: 11, EndLine: 7, EndCol: 3, NumStmt: 1, Count: 1},
+	}}
+	got := normalizeProfiles([]*cover.Profile{overlapping, aligned}, resolve)
+	want := []cover.ProfileBlock{
+		{StartLine: 4, StartCol: 2, EndLine: 4, EndCol: 8, NumStmt: 1, Count: 3},
+		{StartLine: 5, StartCol: 2, EndLine: 5, EndCol: 11, NumStmt: 1, Count: 3},
+		{StartLine: 6, StartCol: 3, EndLine: 6, EndCol: 11, NumStmt: 1, Count: 1},
+		{StartLine: 8, StartCol: 2, EndLine: 8, EndCol: 10, NumStmt: 1, Count: 3},
+	}
+	if !reflect.DeepEqual(got[0].Blocks, want) {
+		t.Errorf("got %+v, want %+v", got[0].Blocks, want)
+	}
+	if got[1] != aligned {
+		t.Errorf("profile without overlapping blocks is changed: %+v", got[1])
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sync"
+
+	"golang.org/x/tools/cover"
+)
+
+// Values of -output-mode.
+const (
+	// outputBuffered prints output of a package after it finishes.
+	outputBuffered = "buffered"
+	// outputStream prints output of packages as they produce it.
+	outputStream = "stream"
+	// outputGroup prints output of a package after it finishes at once, so
+	// that it's never interleaved with output of other packages.
+	outputGroup = "group"
+)
+
+// outputMu serializes output of packages in outputGroup mode.
+var outputMu sync.Mutex
+
+// testOutput is where output of tests and per-package results are printed.
+// It's stderr if a report is written to stdout, so that the report can be
+// parsed.
+var testOutput io.Writer = os.Stdout
+
+func validateOutputMode(mode string) error {
+	switch mode {
+	case "", outputBuffered, outputStream, outputGroup:
+		return nil
+	}
+	return fmt.Errorf("invalid -output-mode %q: must be buffered, stream or group", mode)
+}
+
+// resolveOutputMode returns the output mode for -output-mode. By default,
+// output is streamed with -v and buffered otherwise.
+func resolveOutputMode(mode string, verbose bool) string {
+	if mode != "" {
+		return mode
+	}
+	if verbose {
+		return outputStream
+	}
+	return outputBuffered
+}
+
+// printOutput prints buffered output of the package result. If group is true,
+// stdout and stderr are printed while holding outputMu.
+func printOutput(r *packageResult, group bool) {
+	if group {
+		outputMu.Lock()
+		defer outputMu.Unlock()
+	}
+	testOutput.Write(r.Stdout)
+	os.Stderr.Write(r.Stderr)
+}
+
+// writePackageLine writes a line of the successful package result in the same
+// form as "go test", such as "ok  \tpkg\t1.234s\tcoverage: 74.3% of
+// statements". Coverage is of files in the package itself.
+func writePackageLine(w io.Writer, r *packageResult) {
+	if r.Profiles == nil {
+		fmt.Fprintf(w, "?   \t%s\t[no test files]\n", r.Pkg)
+		return
+	}
+	var own []*cover.Profile
+	for _, p := range r.Profiles {
+		if path.Dir(p.FileName) == r.Pkg {
+			own = append(own, p)
+		}
+	}
+	elapsed := fmt.Sprintf("%.3fs", r.Duration.Seconds())
+	if r.Cached {
+		elapsed = "(cached)"
+	}
+	coverage := "[no statements]"
+	if s := statementStats(own); s.Total > 0 {
+		coverage = fmt.Sprintf("%.1f%% of statements", s.percent())
+	}
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	fmt.Fprintf(w, "ok  \t%s\t%s\tcoverage: %s\n", r.Pkg, elapsed, coverage)
+}
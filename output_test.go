@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestResolveOutputMode(t *testing.T) {
+	tests := []struct {
+		mode    string
+		verbose bool
+		want    string
+	}{
+		{"", false, outputBuffered},
+		{"", true, outputStream},
+		{outputGroup, true, outputGroup},
+		{outputStream, false, outputStream},
+	}
+	for _, tt := range tests {
+		if got := resolveOutputMode(tt.mode, tt.verbose); got != tt.want {
+			t.Errorf("resolveOutputMode(%q, %v) = %q, want %q", tt.mode, tt.verbose, got, tt.want)
+		}
+	}
+	for _, mode := range []string{"", outputBuffered, outputStream, outputGroup} {
+		if err := validateOutputMode(mode); err != nil {
+			t.Error(err)
+		}
+	}
+	if err := validateOutputMode("live"); err == nil {
+		t.Error("want error for invalid mode")
+	}
+}
+
+func TestWritePackageLine(t *testing.T) {
+	profiles := []*cover.Profile{
+		{FileName: "example.com/a/a.go", Blocks: []cover.ProfileBlock{{NumStmt: 3, Count: 1}, {NumStmt: 1, Count: 0}}},
+		{FileName: "example.com/b/b.go", Blocks: []cover.ProfileBlock{{NumStmt: 5, Count: 0}}},
+	}
+	var buf bytes.Buffer
+	writePackageLine(&buf, &packageResult{Pkg: "example.com/a", Success: true, Profiles: profiles, Duration: 1234 * time.Millisecond})
+	writePackageLine(&buf, &packageResult{Pkg: "example.com/a", Success: true, Profiles: profiles, Cached: true})
+	writePackageLine(&buf, &packageResult{Pkg: "example.com/c", Success: true, Profiles: profiles})
+	writePackageLine(&buf, &packageResult{Pkg: "example.com/d", Success: true})
+	const want = "ok  \texample.com/a\t1.234s\tcoverage: 75.0% of statements\n" +
+		"ok  \texample.com/a\t(cached)\tcoverage: 75.0% of statements\n" +
+		"ok  \texample.com/c\t0.000s\tcoverage: [no statements]\n" +
+		"?   \texample.com/d\t[no test files]\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
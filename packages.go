@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// listPackage is a package reported by "go list -json". Only fields used by
+// goverage are decoded.
+type listPackage struct {
+	ImportPath      string
+	Dir             string
+	Standard        bool
+	GoFiles         []string
+	CgoFiles        []string
+	EmbedFiles      []string
+	TestGoFiles     []string
+	XTestGoFiles    []string
+	TestEmbedFiles  []string
+	XTestEmbedFiles []string
+	CFiles          []string
+	CXXFiles        []string
+	SFiles          []string
+	HFiles          []string
+	Deps            []string
+	Module          *listModule
+}
+
+// listModule is module metadata of a package reported by "go list -json". It's
+// nil in GOPATH mode.
+type listModule struct {
+	Path    string
+	Version string
+	Dir     string
+	GoMod   string
+	Main    bool
+}
+
+// resolvePkgs returns packages matched by the given patterns. Patterns
+// prefixed with "!" exclude matched packages from the result after all
+// patterns are expanded by "go list". If there are only exclusion patterns,
+// they are applied to "./...". If ignoreUnresolved is true, patterns which
+// cannot be resolved are logged and skipped instead of returning an error.
+func resolvePkgs(patterns []string, ignoreUnresolved bool) ([]*listPackage, error) {
+	pkgs, _, err := resolvePkgsSkipped(patterns, ignoreUnresolved)
+	return pkgs, err
+}
+
+// resolvePkgsSkipped is like resolvePkgs but also returns patterns skipped
+// since they cannot be resolved and packages excluded by "!" patterns.
+func resolvePkgsSkipped(patterns []string, ignoreUnresolved bool) ([]*listPackage, []skippedPackage, error) {
+	var includes, excludes []string
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "!") {
+			excludes = append(excludes, strings.TrimPrefix(pattern, "!"))
+		} else {
+			includes = append(includes, pattern)
+		}
+	}
+	if len(includes) == 0 && len(excludes) > 0 {
+		includes = []string{"./..."}
+	}
+	pkgs, skipped, err := listPkgs(includes, ignoreUnresolved)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(excludes) == 0 {
+		return pkgs, skipped, nil
+	}
+	// excludedBy maps excluded packages to their patterns.
+	excludedBy := make(map[string]string)
+	for _, pattern := range excludes {
+		excluded, s, err := listPkgs([]string{pattern}, ignoreUnresolved)
+		if err != nil {
+			return nil, nil, err
+		}
+		skipped = append(skipped, s...)
+		for _, p := range excluded {
+			if _, ok := excludedBy[p.ImportPath]; !ok {
+				excludedBy[p.ImportPath] = pattern
+			}
+		}
+	}
+	result := make([]*listPackage, 0, len(pkgs))
+	for _, p := range pkgs {
+		if pattern, ok := excludedBy[p.ImportPath]; ok {
+			skipped = append(skipped, skippedPackage{Package: p.ImportPath, Pattern: "!" + pattern, Reason: skipExcluded})
+			continue
+		}
+		result = append(result, p)
+	}
+	return result, skipped, nil
+}
+
+// listPkgs returns packages for each pattern in order, and patterns skipped
+// with ignoreUnresolved.
+func listPkgs(patterns []string, ignoreUnresolved bool) ([]*listPackage, []skippedPackage, error) {
+	var pkgs []*listPackage
+	var skipped []skippedPackage
+	for _, pattern := range patterns {
+		ps, err := getPkgs(pattern)
+		if err != nil {
+			if ignoreUnresolved {
+				log.Printf("ignore unresolved package pattern %q: %v", pattern, err)
+				skipped = append(skipped, skippedPackage{Pattern: pattern, Reason: skipUnresolved, Detail: strings.TrimSpace(err.Error())})
+				continue
+			}
+			return nil, nil, err
+		}
+		pkgs = append(pkgs, ps...)
+	}
+	return pkgs, skipped, nil
+}
+
+// readPkgFile reads package patterns from the file, one per line. Empty lines
+// and lines starting with "#" are ignored. If filename is "-", it reads from
+// stdin.
+func readPkgFile(filename string) ([]string, error) {
+	if filename == "-" {
+		return readPkgList(os.Stdin)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readPkgList(f)
+}
+
+func readPkgList(r io.Reader) ([]string, error) {
+	var pkgs []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pkgs = append(pkgs, line)
+	}
+	return pkgs, s.Err()
+}
+
+// getPkgs returns packages for mesuring coverage. Returned packages doesn't
+// contain vendor packages.
+func getPkgs(pkg string) ([]*listPackage, error) {
+	if pkg == "" {
+		pkg = "./..."
+	}
+	cmd := goList("-json", pkg)
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to run 'go list %v': %v\n%s", pkg, err, stderr.Bytes())
+	}
+	allPkgs, err := decodeListPackages(stdout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse 'go list -json %v' output: %v", pkg, err)
+	}
+	pkgs := make([]*listPackage, 0, len(allPkgs))
+	for _, p := range allPkgs {
+		if !isVendored(p.ImportPath) {
+			pkgs = append(pkgs, p)
+		}
+	}
+	return pkgs, nil
+}
+
+// decodeListPackages decodes the stream of JSON objects written by
+// "go list -json".
+func decodeListPackages(r io.Reader) ([]*listPackage, error) {
+	var pkgs []*listPackage
+	dec := json.NewDecoder(r)
+	for {
+		p := new(listPackage)
+		if err := dec.Decode(p); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, p)
+	}
+	return pkgs, nil
+}
+
+func isVendored(importPath string) bool {
+	return strings.Contains(importPath, "/vendor/") || strings.HasPrefix(importPath, "vendor/")
+}
+
+// goList returns the "go list" command with args. It lists packages with
+// build tags of the current tag set of -tag-matrix, so that packages and files
+// of the tag set are found.
+func goList(args ...string) *exec.Cmd {
+	if tags != "" {
+		args = append([]string{"-tags", tags}, args...)
+	}
+	return exec.Command("go", append([]string{"list"}, args...)...)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeListPackages(t *testing.T) {
+	const in = `{
+	"Dir": "/go/src/example.com/a",
+	"ImportPath": "example.com/a",
+	"TestGoFiles": ["a_test.go"],
+	"Module": {"Path": "example.com", "Main": true}
+}
+{
+	"Dir": "/go/src/example.com/a/b",
+	"ImportPath": "example.com/a/b"
+}
+`
+	got, err := decodeListPackages(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*listPackage{
+		{
+			ImportPath:  "example.com/a",
+			Dir:         "/go/src/example.com/a",
+			TestGoFiles: []string{"a_test.go"},
+			Module:      &listModule{Path: "example.com", Main: true},
+		},
+		{
+			ImportPath: "example.com/a/b",
+			Dir:        "/go/src/example.com/a/b",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestReadPkgList(t *testing.T) {
+	const in = `
+# generated by impact analysis
+github.com/haya14busa/goverage/example/root
+  ./sub  
+
+!./e2e/...
+`
+	got, err := readPkgList(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"github.com/haya14busa/goverage/example/root", "./sub", "!./e2e/..."}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestGetPkgs(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("./example/root"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	pkgs, err := getPkgs("./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range pkgs {
+		got = append(got, p.ImportPath)
+	}
+	want := []string{
+		"github.com/haya14busa/goverage/example/root",
+		"github.com/haya14busa/goverage/example/root/sub",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestResolvePkgs_unresolved(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("./example/root"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	patterns := []string{"./sub", "./not-exist"}
+	if _, err := resolvePkgs(patterns, false); err == nil {
+		t.Error("got nil error for unresolved pattern")
+	}
+	pkgs, err := resolvePkgs(patterns, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 || pkgs[0].ImportPath != "github.com/haya14busa/goverage/example/root/sub" {
+		t.Errorf("unexpected packages: %+v", pkgs)
+	}
+}
+
+func TestResolvePkgs_exclude(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("./example/root"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	tests := [][]string{
+		{"./...", "!./sub/..."},
+		{"!./sub"},
+	}
+	for _, patterns := range tests {
+		pkgs, err := resolvePkgs(patterns, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pkgs) != 1 || pkgs[0].ImportPath != "github.com/haya14busa/goverage/example/root" {
+			t.Errorf("resolvePkgs(%v): unexpected packages: %+v", patterns, pkgs)
+		}
+	}
+}
+
+func TestResolvePkgsSkipped(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("./example/root"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	_, skipped, err := resolvePkgsSkipped([]string{"./...", "./not-exist", "!./sub"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 2 {
+		t.Fatalf("unexpected skipped packages: %+v", skipped)
+	}
+	if s := skipped[0]; s.Pattern != "./not-exist" || s.Reason != skipUnresolved || s.Detail == "" {
+		t.Errorf("unexpected unresolved pattern: %+v", s)
+	}
+	want := skippedPackage{Package: "github.com/haya14busa/goverage/example/root/sub", Pattern: "!./sub", Reason: skipExcluded}
+	if skipped[1] != want {
+		t.Errorf("got %+v, want %+v", skipped[1], want)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/cover"
+)
+
+// modulePaths returns paths of modules of pkgs, the longest first so that a
+// nested module takes precedence over its parent. It's empty in GOPATH mode.
+func modulePaths(pkgs []*listPackage) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, p := range pkgs {
+		if p.Module == nil || seen[p.Module.Path] {
+			continue
+		}
+		seen[p.Module.Path] = true
+		paths = append(paths, p.Module.Path)
+	}
+	sort.Slice(paths, func(i, j int) bool { return len(paths[i]) > len(paths[j]) })
+	return paths
+}
+
+// relativeProfiles returns copies of profiles whose file names are relative
+// to the module containing them, such as "internal/foo/bar.go" for
+// "example.com/app/internal/foo/bar.go" of module "example.com/app". File
+// names outside of modules are kept as is.
+func relativeProfiles(profiles []*cover.Profile, modules []string) []*cover.Profile {
+	result := make([]*cover.Profile, len(profiles))
+	for i, p := range profiles {
+		c := *p
+		for _, m := range modules {
+			if strings.HasPrefix(p.FileName, m+"/") {
+				c.FileName = strings.TrimPrefix(p.FileName, m+"/")
+				break
+			}
+		}
+		result[i] = &c
+	}
+	return result
+}
+
+// pathRewrite rewrites file names starting with From, a slash separated path
+// prefix, to start with To instead.
+type pathRewrite struct {
+	From string
+	To   string
+}
+
+// pathRewrites is rules of -path-rewrite. It implements flag.Value to be
+// given several times as "from=>to".
+type pathRewrites []pathRewrite
+
+func (rs *pathRewrites) String() string {
+	ss := make([]string, len(*rs))
+	for i, r := range *rs {
+		ss[i] = r.From + "=>" + r.To
+	}
+	return strings.Join(ss, ",")
+}
+
+func (rs *pathRewrites) Set(s string) error {
+	i := strings.Index(s, "=>")
+	if i < 0 {
+		return fmt.Errorf("invalid path rewrite %q: must be from=>to", s)
+	}
+	from := strings.TrimSuffix(filepath.ToSlash(strings.TrimSpace(s[:i])), "/")
+	if from == "" {
+		return fmt.Errorf("invalid path rewrite %q: empty prefix", s)
+	}
+	*rs = append(*rs, pathRewrite{From: from, To: filepath.ToSlash(strings.TrimSpace(s[i+2:]))})
+	return nil
+}
+
+// rewrite returns name rewritten by the first rule whose prefix matches it
+// at a path boundary. The name is returned as is if no rules match. Names
+// are compared in the slash separated form, so that absolute paths of
+// Windows match rules too.
+func (rs pathRewrites) rewrite(name string) string {
+	orig := name
+	name = filepath.ToSlash(name)
+	for _, r := range rs {
+		if name == r.From {
+			return r.To
+		}
+		if strings.HasPrefix(name, r.From+"/") {
+			return path.Join(r.To, strings.TrimPrefix(name, r.From+"/"))
+		}
+	}
+	return orig
+}
+
+// rewriteProfiles returns copies of profiles with file names rewritten by
+// rs. Profiles which are rewritten to the same file name are merged.
+func rewriteProfiles(profiles []*cover.Profile, rs pathRewrites) []*cover.Profile {
+	if len(rs) == 0 {
+		return profiles
+	}
+	result := make([]*cover.Profile, len(profiles))
+	for i, p := range profiles {
+		c := *p
+		c.FileName = rs.rewrite(p.FileName)
+		result[i] = &c
+	}
+	return mergeProfiles([][]*cover.Profile{result})
+}
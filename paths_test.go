@@ -0,0 +1,91 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestModulePaths(t *testing.T) {
+	pkgs := []*listPackage{
+		{ImportPath: "example.com/app", Module: &listModule{Path: "example.com/app"}},
+		{ImportPath: "example.com/app/tools/gen", Module: &listModule{Path: "example.com/app/tools"}},
+		{ImportPath: "example.com/app/store", Module: &listModule{Path: "example.com/app"}},
+		{ImportPath: "example.com/gopath"},
+	}
+	want := []string{"example.com/app/tools", "example.com/app"}
+	if got := modulePaths(pkgs); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRelativeProfiles(t *testing.T) {
+	profiles := []*cover.Profile{
+		{FileName: "example.com/app/main.go", Mode: "set"},
+		{FileName: "example.com/app/internal/foo/bar.go", Mode: "set"},
+		{FileName: "example.com/app/tools/gen/gen.go", Mode: "set"},
+		{FileName: "example.com/application/x.go", Mode: "set"},
+	}
+	got := relativeProfiles(profiles, []string{"example.com/app/tools", "example.com/app"})
+	want := []string{"main.go", "internal/foo/bar.go", "gen/gen.go", "example.com/application/x.go"}
+	for i, p := range got {
+		if p.FileName != want[i] {
+			t.Errorf("got %s, want %s", p.FileName, want[i])
+		}
+	}
+	if profiles[0].FileName != "example.com/app/main.go" {
+		t.Errorf("relativeProfiles modified the given profile: %s", profiles[0].FileName)
+	}
+}
+
+func TestPathRewrites(t *testing.T) {
+	var rs pathRewrites
+	for _, s := range []string{"github.com/me/app/vendor/github.com/me/lib/=>lib", "github.com/me/app=>."} {
+		if err := rs.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"github.com/me/app/main.go", "main.go"},
+		{"github.com/me/app/store/user.go", "store/user.go"},
+		{"github.com/me/app/vendor/github.com/me/lib/lib.go", "lib/lib.go"},
+		{"github.com/me/application/main.go", "github.com/me/application/main.go"},
+	}
+	for _, tt := range tests {
+		if got := rs.rewrite(tt.name); got != tt.want {
+			t.Errorf("rewrite(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	for _, s := range []string{"github.com/me/app", "=>."} {
+		if err := rs.Set(s); err == nil {
+			t.Errorf("Set(%q) wants an error", s)
+		}
+	}
+}
+
+func TestRewriteProfiles(t *testing.T) {
+	profiles := []*cover.Profile{
+		{FileName: "example.com/app/a.go", Mode: "count", Blocks: []cover.ProfileBlock{
+			{StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 2, NumStmt: 1, Count: 1},
+		}},
+		{FileName: "example.com/fork/a.go", Mode: "count", Blocks: []cover.ProfileBlock{
+			{StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 2, NumStmt: 1, Count: 2},
+		}},
+	}
+	rs := pathRewrites{{From: "example.com/app", To: "."}, {From: "example.com/fork", To: "."}}
+	want := []*cover.Profile{
+		{FileName: "a.go", Mode: "count", Blocks: []cover.ProfileBlock{
+			{StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 2, NumStmt: 1, Count: 3},
+		}},
+	}
+	if got := rewriteProfiles(profiles, rs); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if profiles[0].FileName != "example.com/app/a.go" {
+		t.Errorf("rewriteProfiles modified the given profile: %s", profiles[0].FileName)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestFileResolver_windowsPaths(t *testing.T) {
+	r := newFileResolver()
+	for _, name := range []string{`C:\src\app\main.go`, `\\server\share\app\main.go`} {
+		if got, err := r.resolve(name); err != nil || got != name {
+			t.Errorf("resolve(%q) = %q, %v; want it as is", name, got, err)
+		}
+	}
+	// Relative names with backslashes are looked up as import paths.
+	r.dirs["example.com/app/store"] = `C:\src\app\store`
+	if got, err := r.resolve(`example.com\app\store\user.go`); err != nil || got != `C:\src\app\store\user.go` {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
+
+func TestPathRewrites_windowsPaths(t *testing.T) {
+	var rs pathRewrites
+	if err := rs.Set(`C:\src\app=>.`); err != nil {
+		t.Fatal(err)
+	}
+	if got := rs.rewrite(`C:\src\app\store\user.go`); got != "store/user.go" {
+		t.Errorf("got %q, want store/user.go", got)
+	}
+}
+
+func TestFileURL_windowsPaths(t *testing.T) {
+	if got, want := fileURL(`C:\src\my app\main.go`), "file:///C:/src/my%20app/main.go"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/cover"
+)
+
+// listTests returns names of top-level tests, examples and fuzz targets of
+// the package matching the -run expression run by "go test -list" in dir.
+// Only the top-level part of run is used, and an empty run matches all.
+func listTests(pkg, dir, run string, env []string) ([]string, error) {
+	list := strings.SplitN(run, "/", 2)[0]
+	if list == "" {
+		list = "."
+	}
+	args := []string{"test", "-list", list}
+	if tags != "" {
+		args = append(args, "-tags", tags)
+	}
+	cmd := exec.Command("go", append(args, pkg)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tests of %s: %v", pkg, err)
+	}
+	var names []string
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		line := s.Text()
+		if strings.ContainsAny(line, " \t") {
+			continue
+		}
+		if strings.HasPrefix(line, "Test") || strings.HasPrefix(line, "Example") || strings.HasPrefix(line, "Fuzz") {
+			names = append(names, line)
+		}
+	}
+	return names, nil
+}
+
+// coveragePerTest runs each top-level test of the package separately by
+// coverage and returns the combined result, whose TestProfiles has the
+// profile of each test. Only tests matching run are listed, see listTests.
+// It falls back to coverage of the whole package if tests cannot be listed,
+// e.g. because of build errors.
+func coveragePerTest(pkg, dir, coverprofile, run string, optArgs, env []string, verbose bool) (*packageResult, error) {
+	names, err := listTests(pkg, dir, run, env)
+	if err != nil {
+		return coverage(pkg, dir, coverprofile, optArgs, env, verbose)
+	}
+	if len(names) == 0 {
+		r, err := coverage(pkg, dir, coverprofile, optArgs, env, verbose)
+		if r != nil {
+			r.TestProfiles = make(map[string][]*cover.Profile)
+		}
+		return r, err
+	}
+	result := &packageResult{Pkg: pkg, Success: true, TestProfiles: make(map[string][]*cover.Profile)}
+	var cpss [][]*cover.Profile
+	var firstErr error
+	for _, name := range names {
+		// Do not take the profile of the previous test.
+		os.Remove(coverprofile)
+		args := append(optArgs[:len(optArgs):len(optArgs)], "-run", "^"+regexp.QuoteMeta(name)+"$")
+		r, err := coverage(pkg, dir, coverprofile, args, env, verbose)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if r == nil {
+			return nil, err
+		}
+		result.Stdout = append(result.Stdout, r.Stdout...)
+		result.Stderr = append(result.Stderr, r.Stderr...)
+		result.Events = append(result.Events, r.Events...)
+		result.Duration += r.Duration
+		if !r.Success {
+			result.Success = false
+			if result.ExitCode == 0 {
+				result.ExitCode = r.ExitCode
+			}
+		}
+		result.TestProfiles[name] = r.Profiles
+		cpss = append(cpss, r.Profiles)
+	}
+	result.Profiles = mergeProfiles(cpss)
+	return result, firstErr
+}
+
+// perTestReport is the content of the file written by -per-test.
+type perTestReport struct {
+	Tests []testCoverage `json:"tests"`
+}
+
+// testCoverage is statements covered by a top-level test.
+type testCoverage struct {
+	Package string `json:"package"`
+	Test    string `json:"test"`
+	// Statements is the number of covered statements.
+	Statements int `json:"statements"`
+	// Blocks maps file names to covered blocks as
+	// "startLine.startCol,endLine.endCol", as written in profiles.
+	Blocks map[string][]string `json:"blocks"`
+}
+
+// testCoverages returns statements covered by each test in results, sorted
+// by packages and tests. File names are rewritten by rs.
+func testCoverages(results []*packageResult, rs pathRewrites) []testCoverage {
+	tcs := []testCoverage{}
+	for _, r := range results {
+		for name, profiles := range r.TestProfiles {
+			tc := testCoverage{Package: r.Pkg, Test: name, Blocks: make(map[string][]string)}
+			for _, p := range rewriteProfiles(profiles, rs) {
+				for _, b := range p.Blocks {
+					if b.Count == 0 {
+						continue
+					}
+					tc.Statements += b.NumStmt
+					tc.Blocks[p.FileName] = append(tc.Blocks[p.FileName], fmt.Sprintf("%d.%d,%d.%d", b.StartLine, b.StartCol, b.EndLine, b.EndCol))
+				}
+			}
+			tcs = append(tcs, tc)
+		}
+	}
+	sort.Slice(tcs, func(i, j int) bool {
+		ti, tj := tcs[i], tcs[j]
+		return ti.Package < tj.Package || ti.Package == tj.Package && ti.Test < tj.Test
+	})
+	return tcs
+}
+
+// writePerTestFile writes statements covered by each test in results to
+// filename as JSON. See testCoverages.
+func writePerTestFile(filename string, results []*packageResult, rs pathRewrites) error {
+	return writeJSONFile(filename, perTestReport{Tests: testCoverages(results, rs)})
+}
+
+// testMapEntry is files and blocks covered by a test in the file written by
+// -test-map.
+type testMapEntry struct {
+	Files  []string            `json:"files"`
+	Blocks map[string][]string `json:"blocks"`
+}
+
+// writeTestMap writes a map from packages and names of tests in results to
+// files and blocks they cover to filename as JSON, e.g. for test selection.
+func writeTestMap(filename string, results []*packageResult, rs pathRewrites) error {
+	m := make(map[string]map[string]testMapEntry)
+	for _, tc := range testCoverages(results, rs) {
+		files := make([]string, 0, len(tc.Blocks))
+		for f := range tc.Blocks {
+			files = append(files, f)
+		}
+		sort.Strings(files)
+		if m[tc.Package] == nil {
+			m[tc.Package] = make(map[string]testMapEntry)
+		}
+		m[tc.Package][tc.Test] = testMapEntry{Files: files, Blocks: tc.Blocks}
+	}
+	return writeJSONFile(filename, m)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestWritePerTestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-per-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	profile := func(counts ...int) []*cover.Profile {
+		p := &cover.Profile{FileName: "example.com/app/a.go", Mode: "set"}
+		for i, c := range counts {
+			p.Blocks = append(p.Blocks, cover.ProfileBlock{StartLine: i*2 + 1, StartCol: 2, EndLine: i*2 + 2, EndCol: 3, NumStmt: 2, Count: c})
+		}
+		return []*cover.Profile{p}
+	}
+	results := []*packageResult{
+		{Pkg: "example.com/app/b", TestProfiles: map[string][]*cover.Profile{"TestB": profile(0, 1)}},
+		{Pkg: "example.com/app", TestProfiles: map[string][]*cover.Profile{
+			"TestZ": profile(0, 0),
+			"TestA": profile(1, 1),
+		}},
+		{Pkg: "example.com/app/c"},
+	}
+	filename := filepath.Join(dir, "per-test.json")
+	if err := writePerTestFile(filename, results, pathRewrites{{From: "example.com/app", To: "."}}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "tests": [
+    {
+      "package": "example.com/app",
+      "test": "TestA",
+      "statements": 4,
+      "blocks": {
+        "a.go": [
+          "1.2,2.3",
+          "3.2,4.3"
+        ]
+      }
+    },
+    {
+      "package": "example.com/app",
+      "test": "TestZ",
+      "statements": 0,
+      "blocks": {}
+    },
+    {
+      "package": "example.com/app/b",
+      "test": "TestB",
+      "statements": 2,
+      "blocks": {
+        "a.go": [
+          "3.2,4.3"
+        ]
+      }
+    }
+  ]
+}
+`
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteTestMap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-test-map")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	covered := []cover.ProfileBlock{{StartLine: 1, StartCol: 2, EndLine: 2, EndCol: 3, NumStmt: 1, Count: 1}}
+	results := []*packageResult{
+		{Pkg: "example.com/app", TestProfiles: map[string][]*cover.Profile{
+			"TestA": {
+				{FileName: "example.com/app/b.go", Mode: "set", Blocks: covered},
+				{FileName: "example.com/app/a.go", Mode: "set", Blocks: covered},
+			},
+			"TestB": {
+				{FileName: "example.com/app/a.go", Mode: "set", Blocks: []cover.ProfileBlock{{StartLine: 1, StartCol: 2, EndLine: 2, EndCol: 3, NumStmt: 1}}},
+			},
+		}},
+	}
+	filename := filepath.Join(dir, "test-map.json")
+	if err := writeTestMap(filename, results, nil); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "example.com/app": {
+    "TestA": {
+      "files": [
+        "example.com/app/a.go",
+        "example.com/app/b.go"
+      ],
+      "blocks": {
+        "example.com/app/a.go": [
+          "1.2,2.3"
+        ],
+        "example.com/app/b.go": [
+          "1.2,2.3"
+        ]
+      }
+    },
+    "TestB": {
+      "files": [],
+      "blocks": {}
+    }
+  }
+}
+`
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"os/exec"
+	"sync"
+)
+
+// errInterrupted is returned for commands which are not started since
+// goverage is interrupted.
+var errInterrupted = errors.New("interrupted")
+
+// runningCmds is "go test" commands which are running. They are run in their
+// own process groups, so that they can be killed with their child processes,
+// such as test binaries, when goverage is interrupted.
+type runningCmds struct {
+	mu sync.Mutex
+	// cmds maps commands to functions which kill what the process tree
+	// doesn't have, such as containers. They may be nil.
+	cmds   map[*exec.Cmd]func()
+	killed bool
+}
+
+func newRunningCmds() *runningCmds {
+	return &runningCmds{cmds: make(map[*exec.Cmd]func())}
+}
+
+// testCmds is commands of the current run.
+var testCmds = newRunningCmds()
+
+// run starts cmd and waits for it to finish. It returns errInterrupted
+// without starting cmd after killAll.
+func (r *runningCmds) run(cmd *exec.Cmd) error {
+	return r.runWithKill(cmd, nil)
+}
+
+// runWithKill is like run, but kill is also called if cmd is killed by
+// killAll.
+func (r *runningCmds) runWithKill(cmd *exec.Cmd, kill func()) error {
+	setProcessGroup(cmd)
+	r.mu.Lock()
+	if r.killed {
+		r.mu.Unlock()
+		return errInterrupted
+	}
+	if err := cmd.Start(); err != nil {
+		r.mu.Unlock()
+		return err
+	}
+	r.cmds[cmd] = kill
+	r.mu.Unlock()
+	err := cmd.Wait()
+	r.mu.Lock()
+	delete(r.cmds, cmd)
+	r.mu.Unlock()
+	return err
+}
+
+// killAll kills process trees of running commands and prevents new commands
+// from starting.
+func (r *runningCmds) killAll() {
+	r.mu.Lock()
+	r.killed = true
+	var kills []func()
+	for cmd, kill := range r.cmds {
+		if err := killProcessTree(cmd); err != nil {
+			log.Printf("failed to kill process %d: %v", cmd.Process.Pid, err)
+		}
+		if kill != nil {
+			kills = append(kills, kill)
+		}
+	}
+	r.mu.Unlock()
+	for _, kill := range kills {
+		kill()
+	}
+}
+
+// interrupted reports whether killAll has been called.
+func (r *runningCmds) interrupted() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.killed
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestRunningCmds_killAll(t *testing.T) {
+	// The shell waits for its child, which keeps stdout open, so that Wait
+	// doesn't return until both of them are killed.
+	cmd := exec.Command("sh", "-c", "sleep 30 & wait")
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", "ping -n 30 127.0.0.1")
+	}
+	cmd.Stdout = new(bytes.Buffer)
+	r := newRunningCmds()
+	errc := make(chan error, 1)
+	killed := false
+	go func() { errc <- r.runWithKill(cmd, func() { killed = true }) }()
+	for {
+		r.mu.Lock()
+		started := len(r.cmds) > 0
+		r.mu.Unlock()
+		if started {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	r.killAll()
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Error("want error of the killed command")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the process tree was not killed")
+	}
+	if !killed {
+		t.Error("the kill function of the command was not called")
+	}
+	if !r.interrupted() {
+		t.Error("interrupted() = false after killAll")
+	}
+	if err := r.run(exec.Command("go", "version")); err != errInterrupted {
+		t.Errorf("run after killAll = %v, want errInterrupted", err)
+	}
+}
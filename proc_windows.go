@@ -0,0 +1,27 @@
+package main
+
+import (
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// setProcessGroup makes cmd run in a new process group, so that console
+// interrupts of goverage are not sent to it directly.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+}
+
+// killProcessTree kills cmd and its descendant processes by taskkill since
+// Windows doesn't kill child processes with their parent.
+func killProcessTree(cmd *exec.Cmd) error {
+	err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+	if err != nil {
+		// taskkill fails if the process has just exited.
+		return cmd.Process.Kill()
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// remoteCacheConfig is configuration of a remote profile cache shared by
+// machines, e.g. CI runs across branches.
+//
+// The remote cache is an HTTP(S) endpoint which serves entries with GET
+// <url>/<key> and stores them with PUT <url>/<key>, an S3 bucket of an
+// s3://bucket/prefix URL, or a GCS bucket of a gs://bucket/prefix URL.
+type remoteCacheConfig struct {
+	URL string `yaml:"url"`
+	// TokenEnv is the name of an environment variable which has a bearer
+	// token sent with requests. It defaults to GOOGLE_OAUTH_ACCESS_TOKEN for
+	// GCS, e.g. set by "gcloud auth print-access-token".
+	TokenEnv string `yaml:"token_env"`
+	// ReadOnly disables uploading entries, e.g. for untrusted pull requests.
+	ReadOnly bool `yaml:"read_only"`
+	// Region is the region of the S3 bucket. It defaults to AWS_REGION or
+	// AWS_DEFAULT_REGION.
+	Region string `yaml:"region"`
+	// Endpoint is the URL of an S3 compatible service such as MinIO, which
+	// is accessed with path style URLs.
+	Endpoint string `yaml:"endpoint"`
+}
+
+// remoteCache is a client of the remote profile cache.
+type remoteCache struct {
+	url      string
+	token    string
+	readOnly bool
+	client   *http.Client
+	// s3 signs requests to S3. It's nil for other backends and anonymous
+	// access to S3.
+	s3 *s3Signer
+}
+
+func newRemoteCache(cfg *remoteCacheConfig) (*remoteCache, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote cache url %q: %v", cfg.URL, err)
+	}
+	c := &remoteCache{
+		url:      strings.TrimSuffix(cfg.URL, "/"),
+		readOnly: cfg.ReadOnly,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+	tokenEnv := cfg.TokenEnv
+	switch u.Scheme {
+	case "http", "https":
+	case "gs":
+		// The XML API of GCS serves objects by GET and PUT with OAuth tokens.
+		c.url = strings.TrimSuffix("https://storage.googleapis.com/"+u.Host+u.Path, "/")
+		if tokenEnv == "" {
+			tokenEnv = "GOOGLE_OAUTH_ACCESS_TOKEN"
+		}
+	case "s3":
+		if c.url, c.s3, err = newS3Cache(u, cfg); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported remote cache url %q: only http, https, s3 and gs are supported", cfg.URL)
+	}
+	if tokenEnv != "" {
+		c.token = os.Getenv(tokenEnv)
+	}
+	return c, nil
+}
+
+// newS3Cache returns the URL of the prefix of the s3://bucket/prefix URL and
+// the signer of requests with credentials in AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN. The signer is nil without
+// credentials, e.g. for public buckets.
+func newS3Cache(u *url.URL, cfg *remoteCacheConfig) (string, *s3Signer, error) {
+	region := cfg.Region
+	for _, env := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region == "" {
+			region = os.Getenv(env)
+		}
+	}
+	if region == "" {
+		return "", nil, fmt.Errorf("remote cache %q: the region of the S3 bucket is required by region of the config or AWS_REGION", cfg.URL)
+	}
+	prefix := strings.TrimSuffix(u.Path, "/")
+	base := "https://" + u.Host + ".s3." + region + ".amazonaws.com" + prefix
+	if cfg.Endpoint != "" {
+		base = strings.TrimSuffix(cfg.Endpoint, "/") + "/" + u.Host + prefix
+	}
+	var signer *s3Signer
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		signer = &s3Signer{
+			region:       region,
+			accessKey:    id,
+			secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		}
+	}
+	return base, signer, nil
+}
+
+func (c *remoteCache) newRequest(method, key string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest(method, c.url+"/"+key, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "text/plain")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.s3 != nil {
+		c.s3.sign(req, body, time.Now())
+	}
+	return req, nil
+}
+
+// get returns the entry for the key. ok is false if the entry doesn't exist.
+func (c *remoteCache) get(key string) (b []byte, ok bool, err error) {
+	req, err := c.newRequest(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, false, nil
+	default:
+		return nil, false, fmt.Errorf("GET %s: %s", req.URL, resp.Status)
+	}
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	return b, true, nil
+}
+
+// put stores the entry for the key.
+func (c *remoteCache) put(key string, b []byte) error {
+	req, err := c.newRequest(http.MethodPut, key, b)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("PUT %s: %s", req.URL, resp.Status)
+	}
+	return nil
+}
+
+// s3Signer signs requests to S3 by AWS Signature Version 4.
+type s3Signer struct {
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// sign sets headers of the request with the body to authenticate it at t.
+// All headers of the request are signed.
+func (s *s3Signer) sign(req *http.Request, body []byte, t time.Time) {
+	amzDate := t.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+	headers := map[string]string{"host": req.URL.Host}
+	for k, vs := range req.Header {
+		if k != "Authorization" {
+			headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(vs, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{req.Method, req.URL.EscapedPath(), req.URL.RawQuery, canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	key := []byte("AWS4" + s.secretKey)
+	for _, v := range []string{date, s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, v)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.accessKey+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
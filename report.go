@@ -0,0 +1,538 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/tools/cover"
+)
+
+// runReport prints reports of an existing profile.
+func runReport(args []string) error {
+	fs := newFlagSet("report", "-by-author|-by-owner|-line-map|-sarif|-checkstyle|-worst N|-zero-funcs|-branches|-uncovered|-editor-json|-reporter quickfix|-html|-format md-table|istanbul|clover|jacoco|-gerrit|-harbormaster coverage.out")
+	byAuthor := fs.Bool("by-author", false, "Report covered and uncovered lines per author by git blame")
+	byOwner := fs.Bool("by-owner", false, "Report coverage per code owner in CODEOWNERS")
+	lineMap := fs.Bool("line-map", false, "Write execution counts per line of each file as JSON")
+	sarif := fs.Bool("sarif", false, "Write uncovered regions as SARIF results")
+	harbormaster := fs.Bool("harbormaster", false, "Write line coverage as a unit result of harbormaster.sendmessage for Phabricator")
+	checkstyle := fs.Bool("checkstyle", false, "Write uncovered functions and regions as checkstyle XML warnings")
+	worst := fs.Int("worst", 0, "Report N files with the lowest coverage")
+	zeroFuncs := fs.Bool("zero-funcs", false, "Report functions without any covered statements")
+	branches := fs.Bool("branches", false, "Report arms of if, switch and select statements which are never executed and approximate branch coverage")
+	pkgPattern := fs.String("pkg", "", "Only report packages matching the pattern, for -zero-funcs")
+	uncovered := fs.Bool("uncovered", false, "Report ranges of uncovered lines per file")
+	editorJSON := fs.Bool("editor-json", false, "Write uncovered ranges per file with the covermode and the git revision as JSON for editor plugins")
+	asHTML := fs.Bool("html", false, "Write a self-contained HTML report with sources annotated by coverage, viewable as a single file")
+	format := fs.String("format", "", "Write a report in the format: md-table (a Markdown table of coverage per package), istanbul (Istanbul coverage JSON for nyc), clover (Clover XML) or jacoco (JaCoCo XML)")
+	baselineProfile := fs.String("baseline", "", "Baseline profile to show differences of coverage from, for -format md-table")
+	sortBy := fs.String("sort", mdSortName, "Order of packages for -format md-table: name, coverage (the lowest first), delta (the largest decrease first) or statements (the most first)")
+	limit := fs.Int("limit", 0, "Maximum number of packages for -format md-table. Zero shows all")
+	reporter := fs.String("reporter", "", "Report uncovered code in the format: quickfix (file:line:col: message for Vim and Emacs)")
+	gerrit := fs.Bool("gerrit", false, "Write Gerrit robot comments on uncovered changed lines as a ReviewInput JSON")
+	gerritChange := fs.String("gerrit-change", "", "Change number of robot comments for -gerrit (default $GERRIT_CHANGE_NUMBER)")
+	gerritPatchset := fs.String("gerrit-patchset", "", "Patchset number of robot comments for -gerrit (default $GERRIT_PATCHSET_NUMBER)")
+	changedSince := fs.String("changed-since", "", "Only report files changed since the git ref, for -sarif and -gerrit (default HEAD^ for -gerrit)")
+	codeownersFile := fs.String("codeowners", "", "CODEOWNERS file for -by-owner (default CODEOWNERS in .github/, the root or docs/ of the repository)")
+	cfgFile := fs.String("config", "", "Config file (default \""+defaultConfigFile+"\" if exists)")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("goverage report: a profile is required")
+	}
+	cfg, err := loadConfig(configFromRoot(*cfgFile))
+	if err != nil {
+		return err
+	}
+	profiles, err := readProfiles(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	// All reports share the resolver to find files of profiles.
+	resolve := newProfileResolver(profiles).resolve
+	profiles = cfg.filterProfiles(profiles, resolve)
+	switch {
+	case *byAuthor:
+		return reportByAuthor(os.Stdout, profiles, resolve, blameAuthors)
+	case *byOwner:
+		root, err := gitRoot()
+		if err != nil {
+			return err
+		}
+		filename := *codeownersFile
+		if filename == "" {
+			var ok bool
+			if filename, ok = findCodeowners(root); !ok {
+				return fmt.Errorf("goverage report: CODEOWNERS not found in %s", root)
+			}
+		}
+		owners, err := loadCodeowners(filename, root)
+		if err != nil {
+			return err
+		}
+		return reportByOwner(os.Stdout, profiles, resolve, owners)
+	case *lineMap:
+		return writeLineMap(os.Stdout, profiles)
+	case *sarif:
+		root, err := gitRoot()
+		if err != nil {
+			return err
+		}
+		var changed map[string]bool
+		if *changedSince != "" {
+			if changed, err = changedFiles(root, *changedSince); err != nil {
+				return err
+			}
+		}
+		return writeSARIF(os.Stdout, profiles, resolve, root, changed)
+	case *gerrit:
+		root, err := gitRoot()
+		if err != nil {
+			return err
+		}
+		ref := *changedSince
+		if ref == "" {
+			ref = "HEAD^"
+		}
+		changed, err := changedLines(root, ref)
+		if err != nil {
+			return err
+		}
+		change, patchset := *gerritChange, *gerritPatchset
+		if change == "" {
+			change = os.Getenv("GERRIT_CHANGE_NUMBER")
+		}
+		if patchset == "" {
+			patchset = os.Getenv("GERRIT_PATCHSET_NUMBER")
+		}
+		return writeGerritReview(os.Stdout, profiles, resolve, root, changed, change, patchset)
+	case *harbormaster:
+		root, err := gitRoot()
+		if err != nil {
+			if root, err = os.Getwd(); err != nil {
+				return err
+			}
+		}
+		return writeHarbormaster(os.Stdout, profiles, resolve, root)
+	case *checkstyle:
+		return writeCheckstyle(os.Stdout, profiles, resolve)
+	case *zeroFuncs:
+		match := func(string) bool { return true }
+		if *pkgPattern != "" {
+			if match, err = packageMatcher(*pkgPattern); err != nil {
+				return err
+			}
+		}
+		return reportZeroFuncs(os.Stdout, profiles, match, resolve)
+	case *branches:
+		return writeBranches(os.Stdout, profiles, resolve)
+	case *editorJSON:
+		root, err := gitRoot()
+		if err != nil {
+			if root, err = os.Getwd(); err != nil {
+				return err
+			}
+		}
+		revision, dirty, err := gitRevision(root)
+		if err != nil {
+			log.Printf("cannot get git revision: %v", err)
+		}
+		return writeEditorJSON(os.Stdout, profiles, resolve, root, revision, dirty)
+	case *format == "md-table":
+		var baseline []*cover.Profile
+		if *baselineProfile != "" {
+			if baseline, err = readProfiles(*baselineProfile); err != nil {
+				return err
+			}
+			// An empty baseline still shows differences.
+			baseline = append([]*cover.Profile{}, cfg.excludeProfiles(baseline, resolve)...)
+		}
+		return writeMarkdownTable(os.Stdout, profiles, baseline, *sortBy, *limit)
+	case *format == "istanbul":
+		return writeIstanbul(os.Stdout, profiles, resolve)
+	case *format == "clover":
+		return writeClover(os.Stdout, profiles, resolve, time.Now())
+	case *format == "jacoco":
+		return writeJaCoCo(os.Stdout, profiles, resolve, time.Now())
+	case *format != "":
+		return fmt.Errorf("goverage report: unknown format %q", *format)
+	case *asHTML:
+		var revision string
+		if root, err := gitRoot(); err == nil {
+			revision, _, _ = gitRevision(root)
+		}
+		return writeHTML(os.Stdout, profiles, resolve, revision)
+	case *reporter == "quickfix":
+		return writeQuickfix(os.Stdout, profiles, resolve)
+	case *reporter != "":
+		return fmt.Errorf("goverage report: unknown reporter %q", *reporter)
+	case *uncovered:
+		return writeUncovered(os.Stdout, profiles, resolve)
+	case *worst > 0:
+		return reportWorst(os.Stdout, profiles, *worst, resolve, terminalLinker(os.Stdout, os.Getenv))
+	}
+	fs.Usage()
+	return errors.New("goverage report: no report is specified")
+}
+
+// lineHits returns execution counts of lines which have statements in the
+// profile. A line shared by several blocks has the smallest count of them, so
+// a line is considered as covered only if all statements on it are covered.
+func lineHits(p *cover.Profile) map[int]int {
+	hits := make(map[int]int)
+	for _, b := range p.Blocks {
+		if b.NumStmt == 0 {
+			continue
+		}
+		for line := b.StartLine; line <= b.EndLine; line++ {
+			if c, ok := hits[line]; !ok || b.Count < c {
+				hits[line] = b.Count
+			}
+		}
+	}
+	return hits
+}
+
+// uncoveredRegions returns regions of consecutive uncovered blocks in the
+// profile. NumStmt of a region is the total number of statements in it.
+func uncoveredRegions(p *cover.Profile) []cover.ProfileBlock {
+	blocks := append([]cover.ProfileBlock{}, p.Blocks...)
+	sort.Slice(blocks, func(i, j int) bool {
+		bi, bj := blocks[i], blocks[j]
+		return bi.StartLine < bj.StartLine || bi.StartLine == bj.StartLine && bi.StartCol < bj.StartCol
+	})
+	var regions []cover.ProfileBlock
+	extend := false
+	for _, b := range blocks {
+		if b.NumStmt == 0 {
+			continue
+		}
+		if b.Count > 0 {
+			extend = false
+			continue
+		}
+		if extend {
+			r := &regions[len(regions)-1]
+			r.EndLine, r.EndCol = b.EndLine, b.EndCol
+			r.NumStmt += b.NumStmt
+			continue
+		}
+		regions = append(regions, b)
+		extend = true
+	}
+	return regions
+}
+
+// lineMapReport is the output of "goverage report -line-map".
+type lineMapReport struct {
+	Mode string `json:"mode"`
+	// Files maps a file name in the profile to execution counts per line. See
+	// lineHits.
+	Files map[string]map[int]int `json:"files"`
+}
+
+// writeLineMap writes execution counts per line of profiles as JSON.
+func writeLineMap(w io.Writer, profiles []*cover.Profile) error {
+	report := lineMapReport{Files: make(map[string]map[int]int, len(profiles))}
+	for _, p := range profiles {
+		report.Mode = p.Mode
+		report.Files[p.FileName] = lineHits(p)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// coverStats is the number of covered items (lines or statements) out of all
+// items which belong to Name.
+type coverStats struct {
+	Name    string
+	Covered int
+	Total   int
+}
+
+func (s *coverStats) percent() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return 100 * float64(s.Covered) / float64(s.Total)
+}
+
+// statementStats returns statement coverage of all profiles.
+func statementStats(profiles []*cover.Profile) coverStats {
+	var s coverStats
+	for _, p := range profiles {
+		for _, b := range p.Blocks {
+			s.Total += b.NumStmt
+			if b.Count > 0 {
+				s.Covered += b.NumStmt
+			}
+		}
+	}
+	return s
+}
+
+// reportByAuthor writes a table of line coverage per author in descending
+// order of coverage. resolve translates a file name in the profile to a path
+// and blame returns the author of each line of the file.
+func reportByAuthor(w io.Writer, profiles []*cover.Profile, resolve func(string) (string, error), blame func(string) ([]string, error)) error {
+	stats := make(map[string]*coverStats)
+	for _, p := range profiles {
+		filename, err := resolve(p.FileName)
+		if err != nil {
+			log.Printf("skip %s: %v", p.FileName, err)
+			continue
+		}
+		authors, err := blame(filename)
+		if err != nil {
+			log.Printf("skip %s: %v", p.FileName, err)
+			continue
+		}
+		for line, count := range lineHits(p) {
+			author := "unknown"
+			if line-1 < len(authors) && authors[line-1] != "" {
+				author = authors[line-1]
+			}
+			s, ok := stats[author]
+			if !ok {
+				s = &coverStats{Name: author}
+				stats[author] = s
+			}
+			s.Total++
+			if count > 0 {
+				s.Covered++
+			}
+		}
+	}
+	result := make([]*coverStats, 0, len(stats))
+	for _, s := range stats {
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].percent() != result[j].percent() {
+			return result[i].percent() > result[j].percent()
+		}
+		return result[i].Name < result[j].Name
+	})
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "AUTHOR\tCOVERED\tUNCOVERED\tLINES\tCOVERAGE")
+	for _, s := range result {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.1f%%\n", s.Name, s.Covered, s.Total-s.Covered, s.Total, s.percent())
+	}
+	return tw.Flush()
+}
+
+// reportByOwner writes a table of statement coverage per code owner in
+// ascending order of coverage, so that under-tested owners come first. A file
+// with several owners counts for each of them.
+func reportByOwner(w io.Writer, profiles []*cover.Profile, resolve func(string) (string, error), owners *codeowners) error {
+	const unowned = "(unowned)"
+	stats := make(map[string]*coverStats)
+	for _, p := range profiles {
+		filename, err := resolve(p.FileName)
+		if err != nil {
+			log.Printf("skip %s: %v", p.FileName, err)
+			continue
+		}
+		fileOwners := owners.owners(filename)
+		if len(fileOwners) == 0 {
+			fileOwners = []string{unowned}
+		}
+		for _, owner := range fileOwners {
+			s, ok := stats[owner]
+			if !ok {
+				s = &coverStats{Name: owner}
+				stats[owner] = s
+			}
+			for _, b := range p.Blocks {
+				s.Total += b.NumStmt
+				if b.Count > 0 {
+					s.Covered += b.NumStmt
+				}
+			}
+		}
+	}
+	result := make([]*coverStats, 0, len(stats))
+	for _, s := range stats {
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].percent() != result[j].percent() {
+			return result[i].percent() < result[j].percent()
+		}
+		return result[i].Name < result[j].Name
+	})
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "OWNER\tCOVERED\tSTATEMENTS\tCOVERAGE")
+	for _, s := range result {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f%%\n", s.Name, s.Covered, s.Total, s.percent())
+	}
+	return tw.Flush()
+}
+
+// reportWorst writes n files with the lowest statement coverage. Files with
+// the same coverage are ordered by the number of uncovered statements in
+// descending order. File names link to the files by link.
+func reportWorst(w io.Writer, profiles []*cover.Profile, n int, resolve func(string) (string, error), link linkFunc) error {
+	var files []*coverStats
+	for _, p := range profiles {
+		s := statementStats([]*cover.Profile{p})
+		if s.Total == 0 {
+			continue
+		}
+		s.Name = p.FileName
+		files = append(files, &s)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		fi, fj := files[i], files[j]
+		if fi.percent() != fj.percent() {
+			return fi.percent() < fj.percent()
+		}
+		if ui, uj := fi.Total-fi.Covered, fj.Total-fj.Covered; ui != uj {
+			return ui > uj
+		}
+		return fi.Name < fj.Name
+	})
+	if len(files) > n {
+		files = files[:n]
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	// The file is the last column so that invisible escape sequences of the
+	// link don't break alignment.
+	fmt.Fprintln(tw, "COVERAGE\tUNCOVERED\tSTATEMENTS\tFILE")
+	for _, s := range files {
+		name := s.Name
+		if filename, err := resolve(s.Name); err == nil {
+			name = link(s.Name, filename)
+		}
+		fmt.Fprintf(tw, "%.1f%%\t%d\t%d\t%s\n", s.percent(), s.Total-s.Covered, s.Total, name)
+	}
+	return tw.Flush()
+}
+
+// packageMatcher returns a function reporting whether an import path matches
+// the package pattern. Relative patterns are resolved by "go list".
+func packageMatcher(pattern string) (func(importPath string) bool, error) {
+	if !isRelativePattern(pattern) {
+		return func(importPath string) bool { return matchPattern(pattern, importPath) }, nil
+	}
+	pkgs, err := resolvePkgs([]string{pattern}, false)
+	if err != nil {
+		return nil, err
+	}
+	importPaths := make(map[string]bool, len(pkgs))
+	for _, p := range pkgs {
+		importPaths[p.ImportPath] = true
+	}
+	return func(importPath string) bool { return importPaths[importPath] }, nil
+}
+
+// displayPath returns filename relative to the current directory if it's in
+// the directory, so that editors and terminals can open it.
+func displayPath(filename string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return filename
+	}
+	if rel, ok := relPath(wd, filename); ok {
+		return filepath.FromSlash(rel)
+	}
+	return filename
+}
+
+// reportZeroFuncs writes functions without any covered statements in packages
+// matched by match as "file:line<TAB>function".
+func reportZeroFuncs(w io.Writer, profiles []*cover.Profile, match func(importPath string) bool, resolve func(string) (string, error)) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, p := range profiles {
+		if !match(path.Dir(p.FileName)) {
+			continue
+		}
+		filename, err := resolve(p.FileName)
+		if err != nil {
+			log.Printf("skip %s: %v", p.FileName, err)
+			continue
+		}
+		funcs, err := funcExtents(filename, p)
+		if err != nil {
+			log.Printf("skip %s: %v", p.FileName, err)
+			continue
+		}
+		for _, fn := range funcs {
+			if fn.Total > 0 && fn.Covered == 0 {
+				fmt.Fprintf(tw, "%s:%d\t%s\n", displayPath(filename), fn.StartLine, fn.Name)
+			}
+		}
+	}
+	return tw.Flush()
+}
+
+// writeUncovered writes compact ranges of uncovered lines of each file, such
+// as "store/user.go:88-97,120".
+func writeUncovered(w io.Writer, profiles []*cover.Profile, resolve func(string) (string, error)) error {
+	for _, p := range profiles {
+		var lines []int
+		for line, count := range lineHits(p) {
+			if count == 0 {
+				lines = append(lines, line)
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		sort.Ints(lines)
+		name := p.FileName
+		if filename, err := resolve(p.FileName); err == nil {
+			name = displayPath(filename)
+		}
+		if _, err := fmt.Fprintf(w, "%s:%s\n", name, lineRanges(lines)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lineRanges formats sorted line numbers as comma separated ranges.
+func lineRanges(lines []int) string {
+	var ranges []string
+	for i := 0; i < len(lines); {
+		j := i
+		for j+1 < len(lines) && lines[j+1] == lines[j]+1 {
+			j++
+		}
+		if i == j {
+			ranges = append(ranges, strconv.Itoa(lines[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", lines[i], lines[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(ranges, ",")
+}
+
+// writeQuickfix writes uncovered regions as "file:line:col: message" lines,
+// which Vim's quickfix and Emacs's compilation mode can jump through.
+func writeQuickfix(w io.Writer, profiles []*cover.Profile, resolve func(string) (string, error)) error {
+	for _, p := range profiles {
+		name := p.FileName
+		if filename, err := resolve(p.FileName); err == nil {
+			name = displayPath(filename)
+		}
+		for _, r := range uncoveredRegions(p) {
+			if _, err := fmt.Fprintf(w, "%s:%d:%d: %d uncovered statement(s)\n", name, r.StartLine, r.StartCol, r.NumStmt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
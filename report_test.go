@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestLineHits(t *testing.T) {
+	p := &cover.Profile{Blocks: []cover.ProfileBlock{
+		{StartLine: 1, StartCol: 10, EndLine: 3, EndCol: 5, NumStmt: 2, Count: 1},
+		{StartLine: 3, StartCol: 5, EndLine: 4, EndCol: 2, NumStmt: 1, Count: 0},
+		{StartLine: 6, StartCol: 1, EndLine: 6, EndCol: 9, NumStmt: 0, Count: 0},
+	}}
+	want := map[int]int{1: 1, 2: 1, 3: 0, 4: 0}
+	if got := lineHits(p); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestUncoveredRegions(t *testing.T) {
+	p := &cover.Profile{Blocks: []cover.ProfileBlock{
+		{StartLine: 9, StartCol: 1, EndLine: 9, EndCol: 9, NumStmt: 1, Count: 0},
+		{StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 2, NumStmt: 1, Count: 0},
+		{StartLine: 2, StartCol: 2, EndLine: 3, EndCol: 4, NumStmt: 2, Count: 0},
+		{StartLine: 3, StartCol: 4, EndLine: 3, EndCol: 9, NumStmt: 0, Count: 1},
+		{StartLine: 4, StartCol: 1, EndLine: 5, EndCol: 2, NumStmt: 1, Count: 0},
+		{StartLine: 6, StartCol: 1, EndLine: 7, EndCol: 2, NumStmt: 1, Count: 1},
+	}}
+	want := []cover.ProfileBlock{
+		{StartLine: 1, StartCol: 1, EndLine: 5, EndCol: 2, NumStmt: 4, Count: 0},
+		{StartLine: 9, StartCol: 1, EndLine: 9, EndCol: 9, NumStmt: 1, Count: 0},
+	}
+	if got := uncoveredRegions(p); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestWriteLineMap(t *testing.T) {
+	profiles := []*cover.Profile{
+		{FileName: "example.com/a/a.go", Mode: "count", Blocks: []cover.ProfileBlock{
+			{StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 2, NumStmt: 1, Count: 3},
+			{StartLine: 3, StartCol: 1, EndLine: 3, EndCol: 9, NumStmt: 1, Count: 0},
+		}},
+		{FileName: "example.com/b/b.go", Mode: "count"},
+	}
+	var buf bytes.Buffer
+	if err := writeLineMap(&buf, profiles); err != nil {
+		t.Fatal(err)
+	}
+	var got lineMapReport
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := lineMapReport{Mode: "count", Files: map[string]map[int]int{
+		"example.com/a/a.go": {1: 3, 2: 3, 3: 0},
+		"example.com/b/b.go": {},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestReportByAuthor(t *testing.T) {
+	profiles := []*cover.Profile{
+		{FileName: "example.com/a/a.go", Mode: "set", Blocks: []cover.ProfileBlock{
+			{StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 2, NumStmt: 1, Count: 1},
+			{StartLine: 3, StartCol: 1, EndLine: 4, EndCol: 2, NumStmt: 1, Count: 0},
+		}},
+	}
+	resolve := func(name string) (string, error) { return "/src/" + name, nil }
+	blame := func(filename string) ([]string, error) {
+		return []string{"alice", "alice", "bob", "alice"}, nil
+	}
+	var buf bytes.Buffer
+	if err := reportByAuthor(&buf, profiles, resolve, blame); err != nil {
+		t.Fatal(err)
+	}
+	const want = `AUTHOR  COVERED  UNCOVERED  LINES  COVERAGE
+alice   2        1          3      66.7%
+bob     0        1          1      0.0%
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestReportByOwner(t *testing.T) {
+	block := func(numStmt, count int) cover.ProfileBlock {
+		return cover.ProfileBlock{StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 2, NumStmt: numStmt, Count: count}
+	}
+	profiles := []*cover.Profile{
+		{FileName: "example.com/app/store/user.go", Blocks: []cover.ProfileBlock{block(3, 1), block(1, 0)}},
+		{FileName: "example.com/app/web/handler.go", Blocks: []cover.ProfileBlock{block(2, 0), block(2, 1)}},
+		{FileName: "example.com/app/main.go", Blocks: []cover.ProfileBlock{block(1, 0)}},
+	}
+	owners, err := parseCodeowners(strings.NewReader("store/ @org/storage\nweb/ @org/web @org/storage\n"), "/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolve := func(name string) (string, error) { return "/repo/" + strings.TrimPrefix(name, "example.com/app/"), nil }
+	var buf bytes.Buffer
+	if err := reportByOwner(&buf, profiles, resolve, owners); err != nil {
+		t.Fatal(err)
+	}
+	const want = `OWNER         COVERED  STATEMENTS  COVERAGE
+(unowned)     0        1           0.0%
+@org/web      2        4           50.0%
+@org/storage  5        8           62.5%
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestReportWorst(t *testing.T) {
+	block := func(numStmt, count int) cover.ProfileBlock {
+		return cover.ProfileBlock{StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 2, NumStmt: numStmt, Count: count}
+	}
+	profiles := []*cover.Profile{
+		{FileName: "example.com/a/good.go", Blocks: []cover.ProfileBlock{block(9, 1), block(1, 0)}},
+		{FileName: "example.com/a/small.go", Blocks: []cover.ProfileBlock{block(1, 1), block(1, 0)}},
+		{FileName: "example.com/a/large.go", Blocks: []cover.ProfileBlock{block(10, 1), block(10, 0)}},
+		{FileName: "example.com/a/none.go", Blocks: []cover.ProfileBlock{block(3, 0)}},
+		{FileName: "example.com/a/empty.go"},
+	}
+	resolve := func(name string) (string, error) { return "/src/" + name, nil }
+	var buf bytes.Buffer
+	if err := reportWorst(&buf, profiles, 3, resolve, noLink); err != nil {
+		t.Fatal(err)
+	}
+	const want = `COVERAGE  UNCOVERED  STATEMENTS  FILE
+0.0%      3          3           example.com/a/none.go
+50.0%     10         20          example.com/a/large.go
+50.0%     1          2           example.com/a/small.go
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestReportZeroFuncs(t *testing.T) {
+	filename, cleanup := writeFuncsTestSource(t)
+	defer cleanup()
+	profiles := []*cover.Profile{
+		{FileName: "example.com/a/a.go", Blocks: []cover.ProfileBlock{
+			{StartLine: 3, StartCol: 14, EndLine: 5, EndCol: 2, NumStmt: 1, Count: 0},
+			{StartLine: 9, StartCol: 17, EndLine: 10, EndCol: 9, NumStmt: 1, Count: 0},
+			{StartLine: 10, StartCol: 9, EndLine: 12, EndCol: 3, NumStmt: 1, Count: 0},
+		}},
+		{FileName: "example.com/b/a.go", Blocks: []cover.ProfileBlock{
+			{StartLine: 3, StartCol: 14, EndLine: 5, EndCol: 2, NumStmt: 1, Count: 0},
+		}},
+	}
+	resolve := func(string) (string, error) { return filename, nil }
+	match := func(importPath string) bool { return matchPattern("example.com/a/...", importPath) }
+	var buf bytes.Buffer
+	if err := reportZeroFuncs(&buf, profiles, match, resolve); err != nil {
+		t.Fatal(err)
+	}
+	want := filename + ":3  F\n" + filename + ":9  (*T).M\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestLineRanges(t *testing.T) {
+	tests := []struct {
+		lines []int
+		want  string
+	}{
+		{[]int{120}, "120"},
+		{[]int{88, 89, 90, 97, 120, 121}, "88-90,97,120-121"},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := lineRanges(tt.lines); got != tt.want {
+			t.Errorf("lineRanges(%v) = %q, want %q", tt.lines, got, tt.want)
+		}
+	}
+}
+
+func TestWriteUncovered(t *testing.T) {
+	profiles := []*cover.Profile{
+		{FileName: "example.com/app/store/user.go", Blocks: []cover.ProfileBlock{
+			{StartLine: 88, StartCol: 2, EndLine: 97, EndCol: 3, NumStmt: 4, Count: 0},
+			{StartLine: 100, StartCol: 2, EndLine: 101, EndCol: 3, NumStmt: 1, Count: 1},
+			{StartLine: 120, StartCol: 2, EndLine: 120, EndCol: 9, NumStmt: 1, Count: 0},
+		}},
+		{FileName: "example.com/app/store/ok.go", Blocks: []cover.ProfileBlock{
+			{StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 2, NumStmt: 1, Count: 1},
+		}},
+	}
+	resolve := func(name string) (string, error) { return "", errors.New("not found") }
+	var buf bytes.Buffer
+	if err := writeUncovered(&buf, profiles, resolve); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "example.com/app/store/user.go:88-97,120\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestWriteQuickfix(t *testing.T) {
+	profiles := []*cover.Profile{{FileName: "example.com/app/store/user.go", Blocks: []cover.ProfileBlock{
+		{StartLine: 88, StartCol: 2, EndLine: 97, EndCol: 3, NumStmt: 4, Count: 0},
+		{StartLine: 100, StartCol: 2, EndLine: 101, EndCol: 3, NumStmt: 1, Count: 1},
+		{StartLine: 120, StartCol: 5, EndLine: 120, EndCol: 9, NumStmt: 1, Count: 0},
+	}}}
+	resolve := func(name string) (string, error) { return "", errors.New("not found") }
+	var buf bytes.Buffer
+	if err := writeQuickfix(&buf, profiles, resolve); err != nil {
+		t.Fatal(err)
+	}
+	const want = `example.com/app/store/user.go:88:2: 4 uncovered statement(s)
+example.com/app/store/user.go:120:5: 1 uncovered statement(s)
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestIsolateNetwork(t *testing.T) {
+	if err := checkNetworkSandbox(); err != nil {
+		t.Skip(err)
+	}
+	ns, err := os.Readlink("/proc/self/ns/net")
+	if err != nil {
+		t.Skip(err)
+	}
+	cmd := exec.Command("readlink", "/proc/self/ns/net")
+	isolateNetwork(cmd)
+	setProcessGroup(cmd)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(out)); got == ns {
+		t.Errorf("command runs in the network namespace of goverage: %s", got)
+	}
+}
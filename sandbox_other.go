@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// isolateNetwork does nothing since network namespaces are only available on
+// Linux. checkNetworkSandbox fails instead.
+func isolateNetwork(cmd *exec.Cmd) {}
+
+func checkNetworkSandbox() error {
+	return fmt.Errorf("network sandbox is not supported on %s", runtime.GOOS)
+}
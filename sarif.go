@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"path/filepath"
+
+	"golang.org/x/tools/cover"
+)
+
+// sarifRuleUncovered is the ID of the SARIF rule for uncovered code.
+const sarifRuleUncovered = "uncovered"
+
+// sarifLog is a SARIF 2.1.0 log with the subset of properties goverage
+// writes.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+// writeSARIF writes uncovered regions of profiles as SARIF results. Paths are
+// relative to root, the root of the repository. If changed is not nil, only
+// files in it are reported.
+func writeSARIF(w io.Writer, profiles []*cover.Profile, resolve func(string) (string, error), root string, changed map[string]bool) error {
+	results := []sarifResult{}
+	for _, p := range profiles {
+		filename, err := resolve(p.FileName)
+		if err != nil {
+			log.Printf("skip %s: %v", p.FileName, err)
+			continue
+		}
+		rel, inRoot := relPath(root, filename)
+		if changed != nil && !changed[filename] && !(inRoot && changed[filepath.Join(root, filepath.FromSlash(rel))]) {
+			continue
+		}
+		// URIs are escaped for directories with spaces or non-ASCII
+		// characters.
+		uri := fileURL(filename)
+		if inRoot {
+			uri = (&url.URL{Path: rel}).String()
+		}
+		for _, r := range uncoveredRegions(p) {
+			results = append(results, sarifResult{
+				RuleID:  sarifRuleUncovered,
+				Level:   "note",
+				Message: sarifMessage{Text: fmt.Sprintf("%d statement(s) not covered by tests", r.NumStmt)},
+				Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: uri},
+					Region: sarifRegion{
+						StartLine:   r.StartLine,
+						StartColumn: r.StartCol,
+						EndLine:     r.EndLine,
+						EndColumn:   r.EndCol,
+					},
+				}}},
+			})
+		}
+	}
+	report := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "goverage",
+				InformationURI: "https://github.com/haya14busa/goverage",
+				Rules: []sarifRule{{
+					ID:               sarifRuleUncovered,
+					ShortDescription: sarifMessage{Text: "Code not covered by tests"},
+				}},
+			}},
+			Results: results,
+		}},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
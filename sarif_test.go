@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestWriteSARIF(t *testing.T) {
+	profiles := []*cover.Profile{
+		{FileName: "example.com/app/store/user.go", Blocks: []cover.ProfileBlock{
+			{StartLine: 3, StartCol: 2, EndLine: 5, EndCol: 3, NumStmt: 2, Count: 0},
+			{StartLine: 6, StartCol: 2, EndLine: 7, EndCol: 3, NumStmt: 1, Count: 1},
+		}},
+		{FileName: "example.com/app/web/handler.go", Blocks: []cover.ProfileBlock{
+			{StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 2, NumStmt: 1, Count: 0},
+		}},
+	}
+	resolve := func(name string) (string, error) { return "/repo/" + name[len("example.com/app/"):], nil }
+	for _, tt := range []struct {
+		name    string
+		changed map[string]bool
+		want    []string
+	}{
+		{"all", nil, []string{"store/user.go", "web/handler.go"}},
+		{"changed", map[string]bool{"/repo/web/handler.go": true}, []string{"web/handler.go"}},
+	} {
+		var buf bytes.Buffer
+		if err := writeSARIF(&buf, profiles, resolve, "/repo", tt.changed); err != nil {
+			t.Fatal(err)
+		}
+		var got sarifLog
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Version != "2.1.0" || len(got.Runs) != 1 {
+			t.Fatalf("%s: unexpected log: %s", tt.name, buf.Bytes())
+		}
+		results := got.Runs[0].Results
+		if len(results) != len(tt.want) {
+			t.Fatalf("%s: got %d results, want %d: %s", tt.name, len(results), len(tt.want), buf.Bytes())
+		}
+		for i, r := range results {
+			if uri := r.Locations[0].PhysicalLocation.ArtifactLocation.URI; uri != tt.want[i] || r.RuleID != sarifRuleUncovered {
+				t.Errorf("%s: result %d: got %s %s, want %s", tt.name, i, r.RuleID, uri, tt.want[i])
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := writeSARIF(&buf, profiles[:1], resolve, "/repo", nil); err != nil {
+		t.Fatal(err)
+	}
+	var got sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := sarifRegion{StartLine: 3, StartColumn: 2, EndLine: 5, EndColumn: 3}
+	if r := got.Runs[0].Results[0]; r.Locations[0].PhysicalLocation.Region != want || r.Message.Text != "2 statement(s) not covered by tests" {
+		t.Errorf("unexpected result: %+v", r)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/cover"
+)
+
+// runSelect prints tests affected by changes since a git ref according to the
+// impact database, or runs only them and writes their merged profile.
+func runSelect(args []string) error {
+	fs := newFlagSet("select", "[-since origin/main] [-run [-coverprofile coverage.out]] [-state-dir .goverage]")
+	since := fs.String("since", "origin/main", "Git ref to compare the working tree with")
+	run := fs.Bool("run", false, "Run the selected tests and write their merged profile instead of printing them")
+	profile := fs.String("coverprofile", "coverage.out", "Profile of the selected tests with -run")
+	dir := fs.String("state-dir", defaultStateDir, "Directory of the impact database")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return errors.New("goverage select: unexpected arguments")
+	}
+	*dir = fromModuleRoot(fs, "state-dir", *dir)
+	*profile = fromModuleRoot(fs, "coverprofile", *profile)
+	db, err := loadImpactDB(*dir, "select")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	root, err := gitRoot()
+	if err != nil {
+		return err
+	}
+	changed, err := changedFiles(root, *since)
+	if err != nil {
+		return err
+	}
+	files := make([]string, 0, len(changed))
+	for f := range changed {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	tests, changedPkgs, err := affectedByFiles(db, files)
+	if err != nil {
+		return err
+	}
+	if !*run {
+		return writeImpacted(os.Stdout, tests, false)
+	}
+	if len(tests) == 0 {
+		log.Printf("no tests are affected by changes since %s", *since)
+	}
+	return runSelectedTests(tests, changedPkgs, *profile)
+}
+
+// runSelectedTests runs the tests, grouped by packages, with coverage of
+// their packages and the changed packages, and writes the merged profile.
+func runSelectedTests(tests []impactedTest, changedPkgs []string, profile string) error {
+	var pkgs []string
+	names := make(map[string][]string)
+	for _, t := range tests {
+		if _, ok := names[t.Package]; !ok {
+			pkgs = append(pkgs, t.Package)
+		}
+		names[t.Package] = append(names[t.Package], regexp.QuoteMeta(t.Test))
+	}
+	coverpkg := make([]string, 0, len(pkgs)+len(changedPkgs))
+	seen := make(map[string]bool)
+	for _, p := range append(append([]string{}, pkgs...), changedPkgs...) {
+		if !seen[p] {
+			seen[p] = true
+			coverpkg = append(coverpkg, p)
+		}
+	}
+	var cpss [][]*cover.Profile
+	var failed []string
+	for _, pkg := range pkgs {
+		tmp, err := tmpProfileName()
+		if err != nil {
+			return err
+		}
+		args := []string{"-coverpkg", strings.Join(coverpkg, ","), "-run", "^(" + strings.Join(names[pkg], "|") + ")$"}
+		r, err := coverage(pkg, "", tmp, args, nil, false)
+		os.Remove(tmp)
+		if r == nil {
+			return err
+		}
+		if err != nil || !r.Success {
+			failed = append(failed, pkg)
+		} else {
+			writePackageLine(os.Stdout, r)
+		}
+		cpss = append(cpss, r.Profiles)
+	}
+	if err := writeProfile(profile, mergeProfiles(cpss)); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return &TestFailure{Packages: failed}
+	}
+	return nil
+}
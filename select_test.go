@@ -0,0 +1,38 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunSelectedTests(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-select")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	profile := filepath.Join(dir, "coverage.out")
+	tests := []impactedTest{{"github.com/haya14busa/goverage/example/root", "TestCoveredFromRoot"}}
+	if err := runSelectedTests(tests, []string{"github.com/haya14busa/goverage/example/root/sub"}, profile); err != nil {
+		t.Fatal(err)
+	}
+	profiles, err := readProfiles(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	covered := map[string]int{}
+	for _, p := range profiles {
+		for _, b := range p.Blocks {
+			if b.Count > 0 {
+				covered[filepath.Base(p.FileName)] += b.NumStmt
+			}
+		}
+	}
+	// Only TestCoveredFromRoot runs, which covers CoveredFromRoot in root.go
+	// and nothing in sub.go, which is instrumented as a changed package.
+	if covered["root.go"] != 1 || covered["sub.go"] != 0 || len(profiles) != 2 {
+		t.Errorf("got covered statements %v in %d file(s)", covered, len(profiles))
+	}
+}
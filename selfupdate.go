@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// latestReleaseURL is the GitHub API endpoint of the latest release.
+const latestReleaseURL = "https://api.github.com/repos/haya14busa/goverage/releases/latest"
+
+// checksumsAsset is the name of the release asset which lists SHA-256
+// checksums of other assets in the format of sha256sum.
+const checksumsAsset = "checksums.txt"
+
+// checksumsSignatureAsset is the name of the release asset which has the
+// base64 encoded Ed25519 signature of checksumsAsset.
+const checksumsSignatureAsset = checksumsAsset + ".sig"
+
+// releasePublicKey is the base64 encoded Ed25519 public key of signatures of
+// checksums of releases, which builds may set by
+// -ldflags "-X main.releasePublicKey=<key>". self-update is not supported in
+// builds without it, since checksums replaced with binaries of a release
+// cannot be told apart from genuine ones.
+var releasePublicKey = ""
+
+// errSelfUpdateUnsupported is returned by self-update of builds without
+// releasePublicKey.
+var errSelfUpdateUnsupported = errors.New("goverage self-update: not supported in this build, which has no key to verify releases; install goverage with go get instead")
+
+type githubRelease struct {
+	TagName string        `json:"tag_name"`
+	Assets  []githubAsset `json:"assets"`
+}
+
+type githubAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// runSelfUpdate replaces the running binary with the latest release.
+func runSelfUpdate(args []string) error {
+	fs := newFlagSet("self-update", "[-check] [-force]")
+	check := fs.Bool("check", false, "Only check if a newer release is available")
+	force := fs.Bool("force", false, "Update even if the current version is the latest")
+	fs.Parse(args)
+	if releasePublicKey == "" {
+		return errSelfUpdateUnsupported
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+	key, err := parsePublicKey(releasePublicKey)
+	if err != nil {
+		return err
+	}
+	u := &selfUpdater{
+		client:    &http.Client{Timeout: 5 * time.Minute},
+		token:     os.Getenv("GITHUB_TOKEN"),
+		publicKey: key,
+	}
+	rel, err := u.latestRelease(latestReleaseURL)
+	if err != nil {
+		return err
+	}
+	current := readBuildVersion().Version
+	if compareSemver(rel.TagName, current) <= 0 && !*force {
+		log.Printf("goverage %s is the latest", current)
+		return nil
+	}
+	if *check {
+		fmt.Printf("goverage %s is available (current: %s)\n", rel.TagName, current)
+		return nil
+	}
+	if err := u.update(rel, exe, releaseAssetName(runtime.GOOS, runtime.GOARCH)); err != nil {
+		return err
+	}
+	log.Printf("updated goverage %s to %s", current, rel.TagName)
+	return nil
+}
+
+// parsePublicKey parses the base64 encoded Ed25519 public key of releases.
+func parsePublicKey(s string) (ed25519.PublicKey, error) {
+	if s == "" {
+		return nil, errSelfUpdateUnsupported
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(b) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key of releases %q", s)
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+// releaseAssetName returns the name of the release binary for the platform.
+func releaseAssetName(goos, goarch string) string {
+	name := "goverage_" + goos + "_" + goarch
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// selfUpdater downloads release assets from GitHub.
+type selfUpdater struct {
+	client *http.Client
+	// token is a GitHub token to avoid the rate limit of anonymous requests.
+	// It may be empty.
+	token string
+	// publicKey verifies the signature of checksums of releases.
+	publicKey ed25519.PublicKey
+}
+
+func (u *selfUpdater) get(url string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if u.token != "" {
+		req.Header.Set("Authorization", "token "+u.token)
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (u *selfUpdater) latestRelease(url string) (*githubRelease, error) {
+	b, err := u.get(url)
+	if err != nil {
+		return nil, err
+	}
+	var rel githubRelease
+	if err := json.Unmarshal(b, &rel); err != nil {
+		return nil, fmt.Errorf("invalid release: %v", err)
+	}
+	return &rel, nil
+}
+
+// update downloads the asset of the release, verifies it against the
+// checksums of the release signed by the public key and replaces exe with it.
+func (u *selfUpdater) update(rel *githubRelease, exe, assetName string) error {
+	var binURL, sumsURL, sigURL string
+	for _, a := range rel.Assets {
+		switch a.Name {
+		case assetName:
+			binURL = a.URL
+		case checksumsAsset:
+			sumsURL = a.URL
+		case checksumsSignatureAsset:
+			sigURL = a.URL
+		}
+	}
+	if binURL == "" {
+		return fmt.Errorf("release %s has no %s", rel.TagName, assetName)
+	}
+	if sumsURL == "" {
+		return fmt.Errorf("release %s has no %s to verify %s", rel.TagName, checksumsAsset, assetName)
+	}
+	if sigURL == "" {
+		return fmt.Errorf("release %s has no %s to verify %s", rel.TagName, checksumsSignatureAsset, checksumsAsset)
+	}
+	sums, err := u.get(sumsURL)
+	if err != nil {
+		return err
+	}
+	sig, err := u.get(sigURL)
+	if err != nil {
+		return err
+	}
+	if err := verifySignature(u.publicKey, sums, sig); err != nil {
+		return fmt.Errorf("%s of release %s: %v", checksumsAsset, rel.TagName, err)
+	}
+	want, ok := parseChecksums(sums)[assetName]
+	if !ok {
+		return fmt.Errorf("%s of release %s has no checksum of %s", checksumsAsset, rel.TagName, assetName)
+	}
+	bin, err := u.get(binURL)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(bin)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return fmt.Errorf("checksum mismatch of %s: got %s, want %s", assetName, got, want)
+	}
+	return replaceExecutable(exe, bin)
+}
+
+// verifySignature verifies the base64 encoded Ed25519 signature of b.
+func verifySignature(key ed25519.PublicKey, b, sig []byte) error {
+	if len(key) != ed25519.PublicKeySize {
+		return errors.New("no public key to verify the signature")
+	}
+	s, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+	if !ed25519.Verify(key, b, s) {
+		return errors.New("signature verification failed")
+	}
+	return nil
+}
+
+// parseChecksums parses output of sha256sum and returns checksums by file
+// names.
+func parseChecksums(b []byte) map[string]string {
+	sums := make(map[string]string)
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		sums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+	return sums
+}
+
+// replaceExecutable atomically replaces the executable file with content b.
+func replaceExecutable(exe string, b []byte) error {
+	info, err := os.Stat(exe)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(exe), ".goverage-update")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode()|0111); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		// A running executable cannot be overwritten on Windows, but it can
+		// be renamed.
+		old := exe + ".old"
+		os.Remove(old)
+		if err := os.Rename(exe, old); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), exe)
+}
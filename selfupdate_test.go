@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseChecksums(t *testing.T) {
+	got := parseChecksums([]byte("ABC  goverage_linux_amd64\ndef *goverage_windows_amd64.exe\n\ninvalid\n"))
+	if len(got) != 2 || got["goverage_linux_amd64"] != "abc" || got["goverage_windows_amd64.exe"] != "def" {
+		t.Errorf("unexpected checksums: %v", got)
+	}
+}
+
+func TestReleaseAssetName(t *testing.T) {
+	if got := releaseAssetName("linux", "arm64"); got != "goverage_linux_arm64" {
+		t.Errorf("got %s", got)
+	}
+	if got := releaseAssetName("windows", "amd64"); got != "goverage_windows_amd64.exe" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestSelfUpdaterUpdate(t *testing.T) {
+	const bin = "new binary"
+	sum := sha256.Sum256([]byte(bin))
+	checksums := hex.EncodeToString(sum[:]) + "  goverage_linux_amd64\n"
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(b string) string { return base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(b))) }
+	sig := sign(checksums)
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest":
+			if r.Header.Get("Authorization") != "token x" {
+				t.Errorf("missing token: %v", r.Header)
+			}
+			fmt.Fprintf(w, `{"tag_name":"v1.0.0","assets":[{"name":"goverage_linux_amd64","browser_download_url":%q},{"name":"checksums.txt","browser_download_url":%q},{"name":"checksums.txt.sig","browser_download_url":%q}]}`, srv.URL+"/bin", srv.URL+"/sums", srv.URL+"/sig")
+		case "/bin":
+			fmt.Fprint(w, bin)
+		case "/sums":
+			fmt.Fprint(w, checksums)
+		case "/sig":
+			fmt.Fprint(w, sig)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "goverage-selfupdate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	exe := filepath.Join(dir, "goverage")
+	if err := ioutil.WriteFile(exe, []byte("old binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	u := &selfUpdater{client: srv.Client(), token: "x", publicKey: pub}
+	rel, err := u.latestRelease(srv.URL + "/latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel.TagName != "v1.0.0" {
+		t.Errorf("got tag %s", rel.TagName)
+	}
+	if err := u.update(rel, exe, "goverage_darwin_arm64"); err == nil {
+		t.Error("want error for missing asset")
+	}
+	if err := u.update(rel, exe, "goverage_linux_amd64"); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadFile(exe); string(b) != bin {
+		t.Errorf("executable is not replaced: %q", b)
+	}
+
+	checksums = strings.Repeat("0", 64) + "  goverage_linux_amd64\n"
+	if err := u.update(rel, exe, "goverage_linux_amd64"); err == nil || !strings.Contains(err.Error(), "signature verification failed") {
+		t.Errorf("want signature verification failure of replaced checksums, got %v", err)
+	}
+	sig = sign(checksums)
+	if err := u.update(rel, exe, "goverage_linux_amd64"); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("want checksum mismatch, got %v", err)
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	if _, err := parsePublicKey(""); err == nil {
+		t.Error("got no error without the key")
+	}
+	if _, err := parsePublicKey("c2hvcnQ="); err == nil {
+		t.Error("got no error for a short key")
+	}
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := parsePublicKey(base64.StdEncoding.EncodeToString(pub)); err != nil || !got.Equal(pub) {
+		t.Errorf("parsePublicKey() = %v, %v", got, err)
+	}
+}
+
+func TestRunSelfUpdate_unsupported(t *testing.T) {
+	defer func(k string) { releasePublicKey = k }(releasePublicKey)
+	releasePublicKey = ""
+	if err := runSelfUpdate(nil); err != errSelfUpdateUnsupported {
+		t.Errorf("got %v, want errSelfUpdateUnsupported", err)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// semver is a parsed semantic version such as "v1.2.3-rc.1".
+type semver struct {
+	nums [3]int
+	// pre is dot separated identifiers of the pre-release version.
+	pre []string
+}
+
+// parseSemver parses the semantic version with an optional "v" prefix. Build
+// metadata such as "+incompatible" is ignored. ok is false if v is not a
+// semantic version.
+func parseSemver(v string) (s semver, ok bool) {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		v = v[:i]
+	}
+	if i := strings.IndexByte(v, '-'); i >= 0 {
+		s.pre = strings.Split(v[i+1:], ".")
+		v = v[:i]
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) != len(s.nums) {
+		return semver{}, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || p[0] == '+' {
+			return semver{}, false
+		}
+		s.nums[i] = n
+	}
+	return s, true
+}
+
+// compareSemver compares the semantic versions by precedence, and returns -1,
+// 0 or +1 if a is lower than, equal to or higher than b. Invalid versions are
+// lower than valid ones, and compared as strings with each other.
+func compareSemver(a, b string) int {
+	sa, okA := parseSemver(a)
+	sb, okB := parseSemver(b)
+	switch {
+	case !okA && !okB:
+		return strings.Compare(a, b)
+	case !okA:
+		return -1
+	case !okB:
+		return 1
+	}
+	for i := range sa.nums {
+		if c := compareInts(sa.nums[i], sb.nums[i]); c != 0 {
+			return c
+		}
+	}
+	// A pre-release version is lower than the release.
+	switch {
+	case len(sa.pre) == 0 && len(sb.pre) == 0:
+		return 0
+	case len(sa.pre) == 0:
+		return 1
+	case len(sb.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(sa.pre) && i < len(sb.pre); i++ {
+		if c := comparePrerelease(sa.pre[i], sb.pre[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(sa.pre), len(sb.pre))
+}
+
+// comparePrerelease compares identifiers of pre-release versions. Numeric
+// ones are compared numerically and are lower than alphanumeric ones.
+func comparePrerelease(a, b string) int {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return compareInts(na, nb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
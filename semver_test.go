@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestCompareSemver(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.10.0", "v1.9.0", 1},
+		{"v0.9.0", "v0.10.0", -1},
+		{"v1.2.3", "1.2.3", 0},
+		{"v1.0.0-rc.1", "v1.0.0", -1},
+		{"v1.0.0-rc.2", "v1.0.0-rc.10", -1},
+		{"v1.0.0-alpha", "v1.0.0-alpha.1", -1},
+		{"v1.0.0-1", "v1.0.0-alpha", -1},
+		{"v0.0.0-20210101000000-abcdef123456", "v0.0.0-20200101000000-abcdef123456", 1},
+		{"v2.0.0+incompatible", "v1.9.9", 1},
+		{"devel", "v0.1.0", -1},
+		{"v1.2", "v1.2.0", -1},
+	}
+	for _, tt := range tests {
+		if got := compareSemver(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareSemver(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := compareSemver(tt.b, tt.a); got != -tt.want {
+			t.Errorf("compareSemver(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"sort"
+)
+
+// Reason codes of skipped packages.
+const (
+	// skipUnresolved is a pattern which cannot be resolved with
+	// -ignore-unresolved.
+	skipUnresolved = "unresolved"
+	// skipExcluded is a package excluded by a "!" pattern.
+	skipExcluded = "excluded"
+	// skipCoverTarget is a package which does not depend on the package of
+	// -cover-target.
+	skipCoverTarget = "cover_target"
+	// skipTestHelper is a test support package of exclude.test_helpers.
+	skipTestHelper = "test_helper"
+	// skipPreHook is a package whose tests are skipped by a pre hook.
+	skipPreHook = "pre_hook"
+	// skipNotRun is a package which is not tested because of -failfast or
+	// an interrupt.
+	skipNotRun = "not_run"
+	// skipNoTestFiles is a package without test files.
+	skipNoTestFiles = "no_test_files"
+	// skipBuildFailed is a package whose tests failed to build.
+	skipBuildFailed = "build_failed"
+	// skipTestFailed is a package whose tests failed without a profile.
+	skipTestFailed = "test_failed"
+	// skipNoProfile is a package whose tests passed without a profile.
+	skipNoProfile = "no_profile"
+)
+
+// skippedPackage is a package or a pattern which is missing from the
+// profile with the reason code.
+type skippedPackage struct {
+	Package string `json:"package,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+	Reason  string `json:"reason"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// coverTargetSkipped returns packages of all which are not in selected by
+// -cover-target of the package targetPkg.
+func coverTargetSkipped(all, selected []*listPackage, targetPkg string) []skippedPackage {
+	in := make(map[string]bool, len(selected))
+	for _, p := range selected {
+		in[p.ImportPath] = true
+	}
+	var skipped []skippedPackage
+	for _, p := range all {
+		if !in[p.ImportPath] {
+			skipped = append(skipped, skippedPackage{Package: p.ImportPath, Reason: skipCoverTarget, Detail: "does not depend on " + targetPkg})
+		}
+	}
+	return skipped
+}
+
+// skippedResults returns packages of results which produced no profile, and
+// packages of pkgs which have no results, sorted by packages.
+func skippedResults(pkgs []*listPackage, results []*packageResult) []skippedPackage {
+	var skipped []skippedPackage
+	tested := make(map[string]bool, len(results))
+	for _, r := range results {
+		tested[r.Pkg] = true
+		if r.Profiles != nil {
+			continue
+		}
+		s := skippedPackage{Package: r.Pkg}
+		output := r.output()
+		switch {
+		case r.Skipped:
+			s.Reason = skipPreHook
+		case !r.Success && classifyFailure(output) == failureBuild:
+			s.Reason = skipBuildFailed
+		case !r.Success:
+			s.Reason = skipTestFailed
+		case bytes.Contains(output, []byte("[no test files]")):
+			s.Reason = skipNoTestFiles
+		default:
+			s.Reason = skipNoProfile
+		}
+		skipped = append(skipped, s)
+	}
+	for _, p := range pkgs {
+		if !tested[p.ImportPath] {
+			skipped = append(skipped, skippedPackage{Package: p.ImportPath, Reason: skipNotRun})
+		}
+	}
+	sort.SliceStable(skipped, func(i, j int) bool { return skipped[i].Package < skipped[j].Package })
+	return skipped
+}
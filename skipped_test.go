@@ -0,0 +1,43 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestSkippedResults(t *testing.T) {
+	pkgs := []*listPackage{{ImportPath: "a"}, {ImportPath: "b"}, {ImportPath: "c"}, {ImportPath: "d"}, {ImportPath: "e"}, {ImportPath: "f"}, {ImportPath: "g"}}
+	results := []*packageResult{
+		{Pkg: "a", Success: true, Profiles: []*cover.Profile{{FileName: "a/a.go"}}},
+		{Pkg: "b", Success: true, Stdout: []byte("?   \tb\t[no test files]\n")},
+		{Pkg: "c", Stdout: []byte("FAIL\tc [build failed]\n")},
+		{Pkg: "d", Stdout: []byte("FAIL\td\t0.1s\n")},
+		{Pkg: "e", Success: true, Skipped: true},
+		{Pkg: "f", Success: true},
+	}
+	want := []skippedPackage{
+		{Package: "b", Reason: skipNoTestFiles},
+		{Package: "c", Reason: skipBuildFailed},
+		{Package: "d", Reason: skipTestFailed},
+		{Package: "e", Reason: skipPreHook},
+		{Package: "f", Reason: skipNoProfile},
+		{Package: "g", Reason: skipNotRun},
+	}
+	if got := skippedResults(pkgs, results); !reflect.DeepEqual(got, want) {
+		t.Errorf("skippedResults() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCoverTargetSkipped(t *testing.T) {
+	all := []*listPackage{{ImportPath: "a"}, {ImportPath: "b"}, {ImportPath: "c"}}
+	selected := []*listPackage{{ImportPath: "b"}}
+	want := []skippedPackage{
+		{Package: "a", Reason: skipCoverTarget, Detail: "does not depend on b"},
+		{Package: "c", Reason: skipCoverTarget, Detail: "does not depend on b"},
+	}
+	if got := coverTargetSkipped(all, selected, "b"); !reflect.DeepEqual(got, want) {
+		t.Errorf("coverTargetSkipped() = %+v, want %+v", got, want)
+	}
+}
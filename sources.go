@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/build"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/cover"
+)
+
+// fileResolver translates file names in cover profiles, which are usually in
+// "import/path/file.go" form, to absolute paths in the file system. All
+// reports share it to agree on where files are, in modules and GOPATH mode.
+type fileResolver struct {
+	// dirs maps an import path to its directory. An empty string means the
+	// package cannot be found.
+	dirs map[string]string
+	// gopaths and modCache are searched for packages which "go list" cannot
+	// find, e.g. ones outside of the current module or of other workspaces.
+	gopaths  []string
+	modCache string
+}
+
+func newFileResolver() *fileResolver {
+	r := &fileResolver{dirs: make(map[string]string)}
+	gopath := os.Getenv("GOPATH")
+	if gopath == "" {
+		gopath = build.Default.GOPATH
+	}
+	for _, p := range filepath.SplitList(gopath) {
+		if p != "" {
+			r.gopaths = append(r.gopaths, p)
+		}
+	}
+	r.modCache = hostModCache()
+	return r
+}
+
+// newProfileResolver returns a fileResolver which has prefetched packages of
+// the profiles.
+func newProfileResolver(profiles []*cover.Profile) *fileResolver {
+	r := newFileResolver()
+	r.prefetch(profiles)
+	return r
+}
+
+// addPackages records directories of pkgs reported by "go list".
+func (r *fileResolver) addPackages(pkgs []*listPackage) {
+	for _, p := range pkgs {
+		if p.Dir != "" {
+			r.dirs[p.ImportPath] = p.Dir
+		}
+	}
+}
+
+// prefetch looks up directories of packages of the profiles by a single "go
+// list -e", so that resolve doesn't run "go list" for each package. Packages
+// which "go list" cannot find are left to lookup.
+func (r *fileResolver) prefetch(profiles []*cover.Profile) {
+	seen := make(map[string]bool)
+	var importPaths []string
+	for _, p := range profiles {
+		name := filepath.ToSlash(p.FileName)
+		if filepath.IsAbs(p.FileName) || strings.HasPrefix(name, "_/") {
+			continue
+		}
+		importPath := path.Dir(name)
+		if _, ok := r.dirs[importPath]; ok || seen[importPath] {
+			continue
+		}
+		seen[importPath] = true
+		importPaths = append(importPaths, importPath)
+	}
+	if len(importPaths) == 0 {
+		return
+	}
+	stdout := new(bytes.Buffer)
+	cmd := goList(append([]string{"-e", "-json"}, importPaths...)...)
+	cmd.Stdout = stdout
+	if err := cmd.Run(); err != nil {
+		return
+	}
+	pkgs, err := decodeListPackages(stdout)
+	if err != nil {
+		return
+	}
+	for _, p := range pkgs {
+		if seen[p.ImportPath] && p.Dir != "" && isDir(p.Dir) {
+			r.dirs[p.ImportPath] = p.Dir
+		}
+	}
+}
+
+// resolve returns the absolute path of the file in the profile.
+func (r *fileResolver) resolve(fileName string) (string, error) {
+	if filepath.IsAbs(fileName) {
+		return fileName, nil
+	}
+	if isExist(fileName) {
+		return filepath.Abs(fileName)
+	}
+	// Packages outside of GOPATH and modules have local import paths such as
+	// "_/home/me/my project/pkg", which "go list" doesn't accept.
+	if strings.HasPrefix(fileName, "_/") {
+		if filename := filepath.FromSlash(fileName[1:]); isExist(filename) {
+			return filename, nil
+		}
+	}
+	// File names are slash separated, but a profile written on Windows may
+	// have backslashes.
+	fileName = filepath.ToSlash(fileName)
+	importPath := path.Dir(fileName)
+	dir, ok := r.dirs[importPath]
+	if !ok {
+		dir = r.lookup(importPath)
+		r.dirs[importPath] = dir
+	}
+	if dir == "" {
+		return "", fmt.Errorf("cannot find package %q of %s", importPath, fileName)
+	}
+	return filepath.Join(dir, path.Base(fileName)), nil
+}
+
+// lookup returns the directory of the package by "go list", or by searching
+// GOPATH entries and the module cache if "go list" cannot find it.
+func (r *fileResolver) lookup(importPath string) string {
+	stdout := new(bytes.Buffer)
+	cmd := goList("-json", importPath)
+	cmd.Stdout = stdout
+	if err := cmd.Run(); err == nil {
+		if pkgs, err := decodeListPackages(stdout); err == nil && len(pkgs) > 0 && pkgs[0].Dir != "" {
+			return pkgs[0].Dir
+		}
+	}
+	return r.search(importPath)
+}
+
+// search returns the directory of the package in the first GOPATH entry
+// which has it, or in the module cache. Modules in the cache are tried from
+// the longest module path, and the highest semantic version is used if there
+// are several versions of the module.
+func (r *fileResolver) search(importPath string) string {
+	for _, gopath := range r.gopaths {
+		if dir := filepath.Join(gopath, "src", filepath.FromSlash(importPath)); isDir(dir) {
+			return dir
+		}
+	}
+	if r.modCache == "" {
+		return ""
+	}
+	for mod, rest := importPath, ""; mod != "." && mod != "/"; mod, rest = path.Dir(mod), path.Join(path.Base(mod), rest) {
+		escaped, ok := escapeModulePath(mod)
+		if !ok {
+			continue
+		}
+		prefix := filepath.Join(r.modCache, filepath.FromSlash(escaped)) + "@"
+		dirs, err := filepath.Glob(filepath.Join(prefix+"*", filepath.FromSlash(rest)))
+		if err != nil {
+			continue
+		}
+		var latest, latestVersion string
+		for _, dir := range dirs {
+			version := strings.SplitN(strings.TrimPrefix(dir, prefix), string(filepath.Separator), 2)[0]
+			if isDir(dir) && (latest == "" || compareSemver(version, latestVersion) > 0) {
+				latest, latestVersion = dir, version
+			}
+		}
+		if latest != "" {
+			return latest
+		}
+	}
+	return ""
+}
+
+// escapeModulePath returns the module path as in the module cache, where
+// upper case letters are escaped as "!" followed by lower case ones. ok is
+// false if the path has glob metacharacters.
+func escapeModulePath(mod string) (escaped string, ok bool) {
+	if strings.ContainsAny(mod, "*?[\\") {
+		return "", false
+	}
+	var b strings.Builder
+	for _, c := range mod {
+		if 'A' <= c && c <= 'Z' {
+			b.WriteByte('!')
+			c += 'a' - 'A'
+		}
+		b.WriteRune(c)
+	}
+	return b.String(), true
+}
+
+func isDir(filename string) bool {
+	fi, err := os.Stat(filename)
+	return err == nil && fi.IsDir()
+}
+
+// relPath returns the slash separated path of filename relative to root if
+// it's in root. "go list", git and the working directory may refer to the
+// same directory by different paths through symlinks, so symlinks are
+// resolved if the paths don't match as they are.
+func relPath(root, filename string) (string, bool) {
+	if rel, ok := relPathIn(root, filename); ok {
+		return rel, true
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", false
+	}
+	realFilename, err := filepath.EvalSymlinks(filename)
+	if err != nil {
+		return "", false
+	}
+	return relPathIn(realRoot, realFilename)
+}
+
+func relPathIn(root, filename string) (string, bool) {
+	rel, err := filepath.Rel(root, filename)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
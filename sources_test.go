@@ -0,0 +1,227 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestFileResolver(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("./example/root"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	r := newFileResolver()
+	got, err := r.resolve("github.com/haya14busa/goverage/example/root/sub/sub.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(wd, "example", "root", "sub", "sub.go"); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err := r.resolve("example.com/not/exist/a.go"); err == nil {
+		t.Error("got nil error for file in unknown package")
+	}
+}
+
+func TestFileResolver_localImportPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-sources")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "my project", "a.go")
+	if err := os.Mkdir(filepath.Dir(filename), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filename, []byte("package a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := newFileResolver().resolve("_" + filepath.ToSlash(filename))
+	if err != nil || got != filename {
+		t.Errorf("got %q, %v; want %q", got, err, filename)
+	}
+}
+
+func TestRelPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-sources")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "répo with space")
+	if err := os.MkdirAll(filepath.Join(root, "pkg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(root, "pkg", "a.go")
+	if err := ioutil.WriteFile(filename, []byte("package pkg\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := relPath(root, filename); !ok || got != "pkg/a.go" {
+		t.Errorf("relPath() = %q, %v; want pkg/a.go", got, ok)
+	}
+	if got, ok := relPath(root, filepath.Join(root, "..pkg", "a.go")); !ok || got != "..pkg/a.go" {
+		t.Errorf("relPath() = %q, %v; want ..pkg/a.go", got, ok)
+	}
+	if got, ok := relPath(filepath.Join(root, "pkg"), filepath.Join(root, "a.go")); ok {
+		t.Errorf("relPath() = %q for a file outside of root", got)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(root, link); err != nil {
+		t.Skipf("cannot create symlink: %v", err)
+	}
+	if got, ok := relPath(link, filename); !ok || got != "pkg/a.go" {
+		t.Errorf("relPath() through symlink = %q, %v; want pkg/a.go", got, ok)
+	}
+	if got, ok := relPath(root, filepath.Join(link, "pkg", "a.go")); !ok || got != "pkg/a.go" {
+		t.Errorf("relPath() through symlink = %q, %v; want pkg/a.go", got, ok)
+	}
+}
+
+// TestSymlinkedGOPATHCheckout tests a checkout which is a symlink into
+// GOPATH/src, whose packages are found by the path through the symlink.
+func TestSymlinkedGOPATHCheckout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-sources")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	real := filepath.Join(dir, "realproj")
+	if err := os.Mkdir(real, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(real, "a.go"), []byte("package a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gopath := filepath.Join(dir, "gopath")
+	if err := os.MkdirAll(filepath.Join(gopath, "src", "example.com"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(gopath, "src", "example.com", "a")
+	if err := os.Symlink(real, link); err != nil {
+		t.Skipf("cannot create symlink: %v", err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	for _, env := range []string{"PWD", "GOPATH", "GO111MODULE", "GOFLAGS"} {
+		defer os.Setenv(env, os.Getenv(env))
+	}
+	if err := os.Chdir(link); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("PWD", link)
+	os.Setenv("GOPATH", gopath)
+	os.Setenv("GO111MODULE", "off")
+	os.Setenv("GOFLAGS", "")
+	pkgs, err := resolvePkgs([]string{"."}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 || pkgs[0].ImportPath != "example.com/a" {
+		t.Fatalf("got packages %+v, want example.com/a", pkgs)
+	}
+	filename, err := newFileResolver().resolve("example.com/a/a.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel, ok := relPath(real, filename); !ok || rel != "a.go" {
+		t.Errorf("resolve() = %s, want a.go in %s", filename, real)
+	}
+}
+
+func TestFileResolver_search(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-sources")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gopath1 := filepath.Join(dir, "gopath1")
+	gopath2 := filepath.Join(dir, "gopath2")
+	modCache := filepath.Join(dir, "mod")
+	dirs := []string{
+		filepath.Join(gopath2, "src", "example.com", "a"),
+		filepath.Join(modCache, "github.com", "!me", "lib@v1.0.0", "sub"),
+		filepath.Join(modCache, "github.com", "!me", "lib@v1.10.0", "sub"),
+		filepath.Join(modCache, "github.com", "!me", "lib@v1.9.0", "sub"),
+		filepath.Join(modCache, "github.com", "!me", "lib@v1.10.0-rc.1", "sub"),
+	}
+	for _, d := range dirs {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := &fileResolver{dirs: make(map[string]string), gopaths: []string{gopath1, gopath2}, modCache: modCache}
+	tests := []struct {
+		importPath string
+		want       string
+	}{
+		{"example.com/a", dirs[0]},
+		{"github.com/Me/lib/sub", dirs[2]},
+		{"github.com/Me/lib/missing", ""},
+		{"example.com/b", ""},
+	}
+	for _, tt := range tests {
+		if got := r.search(tt.importPath); got != tt.want {
+			t.Errorf("search(%q) = %q, want %q", tt.importPath, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeModulePath(t *testing.T) {
+	if got, ok := escapeModulePath("github.com/BurntSushi/toml"); !ok || got != "github.com/!burnt!sushi/toml" {
+		t.Errorf("got %q, %v", got, ok)
+	}
+	if _, ok := escapeModulePath("example.com/[a]"); ok {
+		t.Error("want not ok for glob metacharacters")
+	}
+}
+
+func TestFileResolver_prefetch(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newFileResolver()
+	r.addPackages([]*listPackage{{ImportPath: "example.com/listed", Dir: filepath.Join(wd, "example", "root")}})
+	r.prefetch([]*cover.Profile{
+		{FileName: "github.com/haya14busa/goverage/example/root/sub/sub.go"},
+		{FileName: "example.com/not/exist/a.go"},
+	})
+	if got, want := r.dirs["github.com/haya14busa/goverage/example/root/sub"], filepath.Join(wd, "example", "root", "sub"); got != want {
+		t.Errorf("prefetched %q, want %q", got, want)
+	}
+	if dir, ok := r.dirs["example.com/not/exist"]; ok {
+		t.Errorf("prefetched %q for a missing package", dir)
+	}
+	got, err := r.resolve("example.com/listed/root.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(wd, "example", "root", "root.go"); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFileResolver_absolute(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := newFileResolver().resolve("sources.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(wd, "sources.go"); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/tools/cover"
+)
+
+// defaultStateDir is the default directory of goverage state files.
+const defaultStateDir = ".goverage"
+
+// stateFile is the name of the run state file in the state directory.
+const stateFile = "state.json"
+
+// timingsFile is the name of the file of package durations in the state
+// directory. Unlike stateFile, it only has durations, which don't depend on
+// local outcomes, so that it can be shared by CI caches or committed to seed
+// the order of packages.
+const timingsFile = "timings.json"
+
+// packageTimings is the content of timingsFile.
+type packageTimings struct {
+	// Packages maps an import path to the duration of its tests in seconds.
+	Packages map[string]float64 `json:"packages"`
+}
+
+// runState is outcomes of previous runs, which is persisted in the state
+// directory.
+type runState struct {
+	Packages map[string]*packageState `json:"packages"`
+	// Baselines maps a baselineKey to the highest total coverage of runs
+	// without failures. It's the baseline of -max-decrease unless -baseline
+	// is given.
+	Baselines map[string]float64 `json:"baselines,omitempty"`
+	// Flags is flags given to the last run as "-name=value".
+	Flags []string `json:"flags,omitempty"`
+	// WorkDir is the directory where the last run was invoked, which package
+	// patterns are relative to, and ModuleRoot is the root of its module,
+	// which default outputs and the config are relative to.
+	WorkDir    string `json:"work_dir,omitempty"`
+	ModuleRoot string `json:"module_root,omitempty"`
+}
+
+// packageState is the outcome of a package in the last run which tested it.
+type packageState struct {
+	Failed   bool    `json:"failed"`
+	Duration float64 `json:"duration_seconds"`
+	// ProfileHash is the SHA-256 of the profile of the package, which tells
+	// whether its coverage changed since the last run.
+	ProfileHash string `json:"profile_hash,omitempty"`
+}
+
+// loadRunState loads the run state in dir. Durations of packages missing in
+// the state are taken from the timings file. It returns an empty state if
+// neither exists.
+func loadRunState(dir string) (*runState, error) {
+	state := &runState{Packages: map[string]*packageState{}}
+	b, err := ioutil.ReadFile(filepath.Join(dir, stateFile))
+	if err == nil {
+		if err := json.Unmarshal(b, state); err != nil {
+			// The state is just a hint. Start over with an empty state.
+			state = &runState{}
+		}
+		if state.Packages == nil {
+			state.Packages = map[string]*packageState{}
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	b, err = ioutil.ReadFile(filepath.Join(dir, timingsFile))
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var timings packageTimings
+	if err := json.Unmarshal(b, &timings); err != nil {
+		return state, nil
+	}
+	for pkg, d := range timings.Packages {
+		if _, ok := state.Packages[pkg]; !ok {
+			state.Packages[pkg] = &packageState{Duration: d}
+		}
+	}
+	return state, nil
+}
+
+// save saves the state and the timings file in dir.
+func (s *runState) save(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	timings := packageTimings{Packages: make(map[string]float64, len(s.Packages))}
+	for pkg, ps := range s.Packages {
+		timings.Packages[pkg] = ps.Duration
+	}
+	if err := writeJSONFile(filepath.Join(dir, stateFile), s); err != nil {
+		return err
+	}
+	return writeJSONFile(filepath.Join(dir, timingsFile), timings)
+}
+
+func writeJSONFile(filename string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, append(b, '\n'), 0644)
+}
+
+// update records outcomes of the results. Results reused from the cache keep
+// the previous duration.
+func (s *runState) update(results []*packageResult) {
+	for _, r := range results {
+		ps, ok := s.Packages[r.Pkg]
+		if !ok {
+			ps = &packageState{}
+			s.Packages[r.Pkg] = ps
+		}
+		ps.Failed = !r.Success
+		if !r.Cached {
+			ps.Duration = r.Duration.Seconds()
+		}
+		ps.ProfileHash = profileHash(r.Profiles)
+	}
+}
+
+// profileHash returns the hex encoded SHA-256 of the profiles. It's empty if
+// there are no profiles.
+func profileHash(profiles []*cover.Profile) string {
+	if len(profiles) == 0 {
+		return ""
+	}
+	h := sha256.New()
+	dumpcp(h, profiles)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// redactedValue is a flag value which may have secrets, such as values of
+// environment variables.
+type redactedValue interface {
+	// redacted returns the value without secrets.
+	redacted() string
+}
+
+// setFlags returns flags set in fs as "-name=value", sorted by name. Values
+// of redactedValue flags are redacted, since the state is often cached or
+// uploaded as an artifact of CI.
+func setFlags(fs *flag.FlagSet) []string {
+	var flags []string
+	fs.Visit(func(f *flag.Flag) {
+		v := f.Value.String()
+		if r, ok := f.Value.(redactedValue); ok {
+			v = r.redacted()
+		}
+		flags = append(flags, fmt.Sprintf("-%s=%s", f.Name, v))
+	})
+	return flags
+}
+
+// prioritize sorts pkgs in place so that packages which failed in the last
+// run come first, followed by slower packages. Packages without a previous
+// outcome keep their relative order at the end.
+func (s *runState) prioritize(pkgs []*listPackage) {
+	get := func(p *listPackage) packageState {
+		if ps, ok := s.Packages[p.ImportPath]; ok {
+			return *ps
+		}
+		return packageState{}
+	}
+	sort.SliceStable(pkgs, func(i, j int) bool {
+		pi, pj := get(pkgs[i]), get(pkgs[j])
+		if pi.Failed != pj.Failed {
+			return pi.Failed
+		}
+		return pi.Duration > pj.Duration
+	})
+}
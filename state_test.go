@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestRunState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	state, err := loadRunState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.update([]*packageResult{
+		{Pkg: "a", Success: true, Duration: time.Second},
+		{Pkg: "b", Success: false, Duration: 2 * time.Second},
+		{Pkg: "c", Success: true, Duration: 3 * time.Second},
+	})
+	if err := state.save(dir); err != nil {
+		t.Fatal(err)
+	}
+	state, err = loadRunState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.update([]*packageResult{{Pkg: "a", Success: true, Cached: true}})
+	if got := state.Packages["a"].Duration; got != 1 {
+		t.Errorf("duration of cached package = %v, want 1", got)
+	}
+
+	pkgs := []*listPackage{{ImportPath: "new"}, {ImportPath: "a"}, {ImportPath: "b"}, {ImportPath: "c"}}
+	state.prioritize(pkgs)
+	var got []string
+	for _, p := range pkgs {
+		got = append(got, p.ImportPath)
+	}
+	if want := []string{"b", "c", "a", "new"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("prioritize: got %v, want %v", got, want)
+	}
+}
+
+func TestLoadRunState_timings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	state := &runState{Packages: map[string]*packageState{"a": {Duration: 1}, "b": {Failed: true, Duration: 2}}}
+	if err := state.save(dir); err != nil {
+		t.Fatal(err)
+	}
+	// Without the state, durations are restored from the timings file.
+	if err := os.Remove(filepath.Join(dir, stateFile)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := loadRunState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]*packageState{"a": {Duration: 1}, "b": {Duration: 2}}
+	if !reflect.DeepEqual(got.Packages, want) {
+		t.Errorf("got %v, want %v", got.Packages, want)
+	}
+}
+
+func TestRunState_profileHash(t *testing.T) {
+	profiles := []*cover.Profile{{FileName: "example.com/a/a.go", Mode: "set", Blocks: []cover.ProfileBlock{
+		{StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 2, NumStmt: 1, Count: 1},
+	}}}
+	state := &runState{Packages: map[string]*packageState{}}
+	state.update([]*packageResult{
+		{Pkg: "a", Success: true, Profiles: profiles},
+		{Pkg: "b", Success: true},
+	})
+	hash := state.Packages["a"].ProfileHash
+	if len(hash) != 64 {
+		t.Errorf("got hash %q, want a SHA-256", hash)
+	}
+	if got := state.Packages["b"].ProfileHash; got != "" {
+		t.Errorf("got hash %q for a package without profiles", got)
+	}
+	profiles[0].Blocks[0].Count = 0
+	state.update([]*packageResult{{Pkg: "a", Success: true, Profiles: profiles}})
+	if state.Packages["a"].ProfileHash == hash {
+		t.Error("hash is not changed by the coverage")
+	}
+}
+
+func TestSetFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Bool("race", false, "")
+	fs.String("covermode", "set", "")
+	fs.Int("parallel", 0, "")
+	var env envVars
+	fs.Var(&env, "env", "")
+	if err := fs.Parse([]string{"-race", "-covermode", "count", "-env", "TOKEN=secret", "-env", "TZ=UTC"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := setFlags(fs), []string{"-covermode=count", "-env=TOKEN=***,TZ=***", "-race=true"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
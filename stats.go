@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+// runStats prints statistics of a profile, which help to find out why a
+// profile is larger than expected or why its coverage is off.
+func runStats(args []string) error {
+	fs := newFlagSet("stats", "coverage.out")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("goverage stats: a profile is required")
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s, err := readProfileStats(f)
+	if err != nil {
+		return err
+	}
+	return s.write(os.Stdout)
+}
+
+// profileStats is statistics of a profile as written.
+type profileStats struct {
+	Mode  string
+	Files int
+	// Blocks is the number of block lines including duplicate ones.
+	Blocks int
+	// Statements is the number of statements, counting duplicate blocks
+	// once as they are merged when the profile is parsed.
+	Statements   int
+	Duplicates   int
+	Overlaps     int
+	InvalidLines int
+}
+
+// readProfileStats reads the profile from r and returns its statistics.
+// Lines with invalid syntax are counted instead of failing.
+func readProfileStats(r io.Reader) (*profileStats, error) {
+	p, err := readRawProfile(r, func(int, string, ...interface{}) {})
+	if err != nil {
+		return nil, err
+	}
+	s := &profileStats{Mode: p.mode, Files: len(p.files), InvalidLines: p.skipped}
+	for _, name := range p.files {
+		for _, b := range p.blocks[name] {
+			s.Blocks++
+			s.Statements += b.numStmt
+		}
+		p.conflicts(name, func(b, prev rawBlock, duplicate bool) {
+			if duplicate {
+				s.Duplicates++
+				s.Statements -= b.numStmt
+			} else {
+				s.Overlaps++
+			}
+		})
+	}
+	return s, nil
+}
+
+func (s *profileStats) write(w io.Writer) error {
+	mode := s.Mode
+	if mode == "" {
+		mode = "(missing)"
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 1, ' ', 0)
+	fmt.Fprintf(tw, "mode:\t%s\n", mode)
+	fmt.Fprintf(tw, "files:\t%d\n", s.Files)
+	fmt.Fprintf(tw, "blocks:\t%d\n", s.Blocks)
+	fmt.Fprintf(tw, "statements:\t%d\n", s.Statements)
+	fmt.Fprintf(tw, "duplicate blocks:\t%d\n", s.Duplicates)
+	fmt.Fprintf(tw, "overlapping blocks:\t%d\n", s.Overlaps)
+	if s.InvalidLines > 0 {
+		fmt.Fprintf(tw, "invalid lines:\t%d\n", s.InvalidLines)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadProfileStats(t *testing.T) {
+	profile := `mode: count
+example.com/app/main.go:1.1,3.2 2 1
+example.com/app/main.go:5.1,7.2 3 0
+example.com/app/main.go:1.1,3.2 2 4
+example.com/app/main.go:6.1,6.10 1 0
+example.com/app/user.go:1.1,2.2 1 1
+broken line
+`
+	s, err := readProfileStats(strings.NewReader(profile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &profileStats{
+		Mode:         "count",
+		Files:        2,
+		Blocks:       5,
+		Statements:   7,
+		Duplicates:   1,
+		Overlaps:     1,
+		InvalidLines: 1,
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("got %+v, want %+v", s, want)
+	}
+	var buf bytes.Buffer
+	if err := s.write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	wantOut := "" +
+		"mode:               count\n" +
+		"files:              2\n" +
+		"blocks:             5\n" +
+		"statements:         7\n" +
+		"duplicate blocks:   1\n" +
+		"overlapping blocks: 1\n" +
+		"invalid lines:      1\n"
+	if got := buf.String(); got != wantOut {
+		t.Errorf("got:\n%s\nwant:\n%s", got, wantOut)
+	}
+}
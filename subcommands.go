@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/tools/cover"
+)
+
+// subcommands maps a subcommand name to the function to run it with its
+// arguments.
+var subcommands = map[string]func(args []string) error{
+	"build":       runBuild,
+	"check":       runCheck,
+	"collect":     runCollect,
+	"compile":     runCompile,
+	"coordinator": runCoordinator,
+	"doctor":      runDoctor,
+	"exec":        runExec,
+	"impact":      runImpact,
+	"merge":       runMerge,
+	"report":      runReport,
+	"select":      runSelect,
+	"self-update": runSelfUpdate,
+	"stats":       runStats,
+	"total":       runTotal,
+	"validate":    runValidate,
+}
+
+// newFlagSet returns a flag set for the subcommand.
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet("goverage "+name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\tgoverage %s %s\n\nFlags:\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runBuild runs "go build -cover" with args to build coverage-instrumented
+// binaries. Coverage data of the binaries is written to the directory set by
+// GOCOVERDIR and can be merged into a profile by "goverage collect".
+func runBuild(args []string) error {
+	buildArgs := []string{"build"}
+	if !hasFlag(args, "cover") {
+		buildArgs = append(buildArgs, "-cover")
+	}
+	cmd := exec.Command("go", append(buildArgs, args...)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		code := exitCode(err)
+		if code < 0 {
+			code = 1
+		}
+		return &ExitError{Msg: fmt.Sprintf("goverage build: %v", err), Code: code}
+	}
+	return nil
+}
+
+// runCollect merges coverage data in GOCOVERDIR directories into the profile.
+func runCollect(args []string) error {
+	fs := newFlagSet("collect", "-covdir dir[,dir...] [-coverprofile coverage.out] [-coerce-mode set|count]")
+	covdirs := fs.String("covdir", "", "Comma separated directories which contain coverage data of instrumented binaries")
+	profile := fs.String("coverprofile", "coverage.out", "Profile to merge coverage data into. It's created if not exists")
+	coerce := fs.String("coerce-mode", "", "Convert the profile and coverage data to the mode (set or count) to merge ones of different modes")
+	fs.Parse(args)
+	if *covdirs == "" {
+		fs.Usage()
+		return errors.New("goverage collect: -covdir is required")
+	}
+	var cpss [][]*cover.Profile
+	if isExist(*profile) {
+		ps, err := readProfiles(*profile)
+		if err != nil {
+			return err
+		}
+		cpss = append(cpss, ps)
+	}
+	for _, dir := range strings.Split(*covdirs, ",") {
+		ps, err := covdataProfiles(dir)
+		if err != nil {
+			return err
+		}
+		cpss = append(cpss, ps)
+	}
+	cpss, err := checkModes(cpss, *coerce)
+	if err != nil {
+		return err
+	}
+	return writeProfile(*profile, normalizeProfiles(mergeProfiles(cpss), newFileResolver().resolve))
+}
+
+// runMerge merges profiles given as arguments and writes the result to stdout
+// or the file given by -o. An argument is either a text cover profile or a
+// GOCOVERDIR directory of binary coverage data.
+func runMerge(args []string) error {
+	fs := newFlagSet("merge", "[-o coverage.out] [-coerce-mode set|count] [-path-rewrite from=>to] profile|covdir...")
+	out := fs.String("o", "", "Write the merged profile to the file instead of stdout")
+	coerce := fs.String("coerce-mode", "", "Convert profiles to the mode (set or count) to merge profiles of different modes")
+	var rs pathRewrites
+	fs.Var(&rs, "path-rewrite", "Rewrite prefixes of file names as 'from=>to'. Can be repeated")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("goverage merge: no input profiles")
+	}
+	cpss := make([][]*cover.Profile, 0, fs.NArg())
+	for _, input := range fs.Args() {
+		ps, err := readProfiles(input)
+		if err != nil {
+			return err
+		}
+		cpss = append(cpss, ps)
+	}
+	cpss, err := checkModes(cpss, *coerce)
+	if err != nil {
+		return err
+	}
+	merged := rewriteProfiles(normalizeProfiles(mergeProfiles(cpss), newFileResolver().resolve), rs)
+	if *out == "" {
+		dumpcp(os.Stdout, merged)
+		return nil
+	}
+	return writeProfile(*out, merged)
+}
+
+// readProfiles reads cover profiles from the text profile or the GOCOVERDIR
+// directory.
+func readProfiles(input string) ([]*cover.Profile, error) {
+	fi, err := os.Stat(input)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return covdataProfiles(input)
+	}
+	ps, err := cover.ParseProfiles(input)
+	if err != nil {
+		return nil, &ProfileParseError{File: input, Err: err}
+	}
+	return ps, nil
+}
+
+// writeProfile writes cover profiles to the file.
+func writeProfile(filename string, profiles []*cover.Profile) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	dumpcp(f, profiles)
+	return f.Close()
+}
+
+// hasFlag reports whether args has the flag with the name, either in -name or
+// -name=value form. A single or double dash is accepted as the flag package.
+func hasFlag(args []string, name string) bool {
+	for _, arg := range args {
+		arg = strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if arg == name || strings.HasPrefix(arg, name+"=") {
+			return true
+		}
+	}
+	return false
+}
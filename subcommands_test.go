@@ -0,0 +1,160 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHasFlag(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"-o", "bin/app", "./cmd/app"}, false},
+		{[]string{"-cover", "./cmd/app"}, true},
+		{[]string{"--cover=true", "./cmd/app"}, true},
+		{[]string{"-coverpkg=./...", "./cmd/app"}, false},
+	}
+	for _, tt := range tests {
+		if got := hasFlag(tt.args, "cover"); got != tt.want {
+			t.Errorf("hasFlag(%v, cover) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestRunCollect_emptyCovdir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-collect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	profile := filepath.Join(dir, "coverage.out")
+	const content = "mode: set\nexample.com/a/a.go:3.10,5.2 1 1\n"
+	if err := ioutil.WriteFile(profile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	covdir := filepath.Join(dir, "covdata")
+	if err := os.Mkdir(covdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := runCollect([]string{"-covdir", covdir, "-coverprofile", profile}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); got != content {
+		t.Errorf("got:\n%s\nwant:\n%s", got, content)
+	}
+}
+
+func TestRunCollect_coerceMode(t *testing.T) {
+	if !goSupportsCovdata() {
+		t.Skip("go tool covdata is not available")
+	}
+	dir, err := ioutil.TempDir("", "goverage-collect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	covdir := filepath.Join(dir, "covdata")
+	if err := os.Mkdir(covdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("go", "test", "-cover", "-covermode", "count", "./example/root", "-args", "-test.gocoverdir="+covdir)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	profile := filepath.Join(dir, "coverage.out")
+	if err := ioutil.WriteFile(profile, []byte("mode: set\nexample.com/a/a.go:3.10,5.2 1 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runCollect([]string{"-covdir", covdir, "-coverprofile", profile}); err == nil {
+		t.Error("want error for coverage data of another mode")
+	}
+	if err := runCollect([]string{"-covdir", covdir, "-coverprofile", profile, "-coerce-mode", "set"}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); !strings.HasPrefix(got, "mode: set\n") || !strings.Contains(got, "example.com/a/a.go:3.10,5.2 1 1\n") {
+		t.Errorf("got:\n%s", got)
+	}
+}
+
+func TestRunMerge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-merge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	a := filepath.Join(dir, "a.out")
+	b := filepath.Join(dir, "b.out")
+	if err := ioutil.WriteFile(a, []byte("mode: count\nexample.com/a/a.go:3.10,5.2 1 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(b, []byte("mode: count\nexample.com/a/a.go:3.10,5.2 1 2\nexample.com/b/b.go:3.10,5.2 1 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	covdir := filepath.Join(dir, "covdata")
+	if err := os.Mkdir(covdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "merged.out")
+	if err := runMerge([]string{"-o", out, a, b, covdir}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "mode: count\nexample.com/a/a.go:3.10,5.2 1 3\nexample.com/b/b.go:3.10,5.2 1 0\n"
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRunMerge_coerceMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goverage-merge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	a := filepath.Join(dir, "a.out")
+	b := filepath.Join(dir, "b.out")
+	if err := ioutil.WriteFile(a, []byte("mode: set\nexample.com/a/a.go:3.10,5.2 1 1\nexample.com/a/a.go:6.10,7.2 1 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(b, []byte("mode: count\nexample.com/a/a.go:3.10,5.2 1 2\nexample.com/a/a.go:6.10,7.2 1 3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "merged.out")
+	if err := runMerge([]string{"-o", out, a, b}); err == nil {
+		t.Error("want error for profiles of different modes")
+	}
+	for mode, want := range map[string]string{
+		"set":   "mode: set\nexample.com/a/a.go:3.10,5.2 1 1\nexample.com/a/a.go:6.10,7.2 1 1\n",
+		"count": "mode: count\nexample.com/a/a.go:3.10,5.2 1 3\nexample.com/a/a.go:6.10,7.2 1 3\n",
+	} {
+		if err := runMerge([]string{"-o", out, "-coerce-mode", mode, a, b}); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("-coerce-mode %s: got:\n%s\nwant:\n%s", mode, got, want)
+		}
+	}
+	if err := runMerge([]string{"-o", out, "-coerce-mode", "atomic", a, b}); err == nil {
+		t.Error("want error for invalid mode")
+	}
+}
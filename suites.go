@@ -0,0 +1,240 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/cover"
+)
+
+// Suites of -suites.
+const (
+	// suiteShort runs tests with -short.
+	suiteShort = "short"
+	// suiteFull runs tests without -short.
+	suiteFull = "full"
+)
+
+// parseSuites parses the comma separated suites of -suites.
+func parseSuites(s string) ([]string, error) {
+	var suites []string
+	seen := make(map[string]bool)
+	for _, suite := range strings.Split(s, ",") {
+		suite = strings.TrimSpace(suite)
+		if suite != suiteShort && suite != suiteFull {
+			return nil, fmt.Errorf("invalid suite %q in -suites: must be short or full", suite)
+		}
+		if !seen[suite] {
+			seen[suite] = true
+			suites = append(suites, suite)
+		}
+	}
+	return suites, nil
+}
+
+// suiteProfile returns the name of the profile of the suite next to
+// coverprofile, such as "coverage.short.out" for "coverage.out".
+func suiteProfile(coverprofile, suite string) string {
+	ext := filepath.Ext(coverprofile)
+	return strings.TrimSuffix(coverprofile, ext) + "." + suite + ext
+}
+
+// runSuites runs tests of the packages once for each suite by runVariants,
+// writing the profile of each suite next to coverprofile.
+func runSuites(suites []string, coverprofile string, args []string) error {
+	coverprofile, err := variantProfile(coverprofile)
+	if err != nil {
+		return err
+	}
+	var vs []variant
+	for _, suite := range suites {
+		vs = append(vs, variant{name: suite, profile: suiteProfile(coverprofile, suite), short: suite == suiteShort})
+	}
+	return runVariants("suite", vs, coverprofile, args)
+}
+
+// variant is a configuration to run tests of the packages with, such as a
+// suite of -suites.
+type variant struct {
+	name    string
+	profile string
+	short   bool
+	// tags is build tags of "go test" of the variant.
+	tags string
+}
+
+// variantProfile returns the absolute path of coverprofile resolved as run
+// does, so that profiles of variants are found when goverage runs in a
+// subdirectory of a module.
+func variantProfile(coverprofile string) (string, error) {
+	// Check conflicts before running any variant, since run checks them for
+	// each.
+	if err := checkFlagConflicts(covermode); err != nil {
+		return "", err
+	}
+	if appendProfile {
+		return "", fmt.Errorf("-append cannot be used with -suites or -tag-matrix")
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	if modRoot := findModuleRoot(wd); modRoot != "" && modRoot != wd {
+		coverprofile = fromRoot(setFlagNames(flag.CommandLine), "coverprofile", coverprofile, modRoot)
+	}
+	return filepath.Abs(coverprofile)
+}
+
+// variantRuns collects outputs of runs of variants.
+type variantRuns struct {
+	outputs []*runOutputs
+	// tr and testEvents are shared by runs of all variants.
+	tr         *tracer
+	testEvents io.Writer
+}
+
+// variants collects outputs of runs by runVariants instead of writing reports
+// and the state for each variant, if not nil.
+var variants *variantRuns
+
+// runVariants runs tests of the packages once for each variant by run, writes
+// the merged profile of all variants to coverprofile, and prints coverage per
+// variant labeled by kind. Reports and the state are written once for the
+// merged profile. Variants run even if a previous one failed, and the first
+// error is returned.
+func runVariants(kind string, vs []variant, coverprofile string, args []string) (err error) {
+	// Packages from stdin can be read only once.
+	if pkgFile == "-" {
+		ps, err := readPkgFile(pkgFile)
+		if err != nil {
+			return err
+		}
+		args, pkgFile = append(args, ps...), ""
+	}
+	variants = &variantRuns{tr: newTracer(os.Getenv)}
+	defer func(tr *tracer) {
+		variants = nil
+		exportTraces(tr, err)
+	}(variants.tr)
+	if testJSONOutput != "" {
+		f, err := os.Create(testJSONOutput)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		variants.testEvents = f
+	}
+	defer func(t string) { tags = t }(tags)
+	var firstErr error
+	var names []string
+	for _, vr := range vs {
+		fmt.Fprintf(testOutput, "=== %s %s\n", strings.ToUpper(kind), vr.name)
+		// Do not leave the profile of a previous run if this one fails.
+		if err := os.Remove(vr.profile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		tags = vr.tags
+		n := len(variants.outputs)
+		if err := run(vr.profile, args, covermode, cpu, parallel, timeout, vr.short, v); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if len(variants.outputs) > n {
+			names = append(names, vr.name)
+		}
+	}
+	if len(variants.outputs) == 0 {
+		return firstErr
+	}
+	out := mergeRunOutputs(variants.outputs)
+	out.coverprofile = coverprofile
+	// The merged coverage is compared with the baseline of the same variants.
+	out.baseline = baselineKey(args, false, "") + " " + kind + "=" + strings.Join(names, ";")
+	f, err := os.Create(coverprofile)
+	if err != nil {
+		return err
+	}
+	dumpProfiles(f, out.profiles, out.pkgs)
+	if err := f.Close(); err != nil {
+		return err
+	}
+	var cpss [][]*cover.Profile
+	for _, o := range variants.outputs {
+		cpss = append(cpss, o.profiles)
+	}
+	writeVariantCoverage(testOutput, kind, names, cpss, out.profiles)
+	resolver := newFileResolver()
+	resolver.addPackages(out.pkgs)
+	resolver.prefetch(out.profiles)
+	if err := out.write(resolver.resolve); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
+
+// mergeRunOutputs merges outputs of runs of variants. The state and the
+// configuration are ones of the first run.
+func mergeRunOutputs(outs []*runOutputs) *runOutputs {
+	first := outs[0]
+	m := &runOutputs{
+		cfg:     first.cfg,
+		state:   first.state,
+		workDir: first.workDir,
+		modRoot: first.modRoot,
+		vcs:     first.vcs,
+		tr:      first.tr,
+	}
+	var cpss [][]*cover.Profile
+	seenPkgs := make(map[string]bool)
+	seenSkipped := make(map[skippedPackage]bool)
+	seenGenerated := make(map[string]bool)
+	seenIgnored := make(map[ignoredRegion]bool)
+	for _, o := range outs {
+		cpss = append(cpss, o.profiles)
+		for _, p := range o.pkgs {
+			if !seenPkgs[p.ImportPath] {
+				seenPkgs[p.ImportPath] = true
+				m.pkgs = append(m.pkgs, p)
+				m.importPaths = append(m.importPaths, p.ImportPath)
+			}
+		}
+		for _, s := range o.skipped {
+			if !seenSkipped[s] {
+				seenSkipped[s] = true
+				m.skipped = append(m.skipped, s)
+			}
+		}
+		for _, g := range o.generated {
+			if !seenGenerated[g.File] {
+				seenGenerated[g.File] = true
+				m.generated = append(m.generated, g)
+			}
+		}
+		for _, r := range o.ignored {
+			if !seenIgnored[r] {
+				seenIgnored[r] = true
+				m.ignored = append(m.ignored, r)
+			}
+		}
+		m.results = append(m.results, o.results...)
+		m.failedPkgs = append(m.failedPkgs, o.failedPkgs...)
+		m.buildFailedPkgs = append(m.buildFailedPkgs, o.buildFailedPkgs...)
+	}
+	m.profiles = mergeProfiles(cpss)
+	m.failedPkgs = uniqueStrings(m.failedPkgs)
+	m.buildFailedPkgs = uniqueStrings(m.buildFailedPkgs)
+	return m
+}
+
+// writeVariantCoverage prints coverage of each variant and the merged one.
+func writeVariantCoverage(w io.Writer, kind string, names []string, cpss [][]*cover.Profile, merged []*cover.Profile) {
+	for i, name := range names {
+		s := statementStats(cpss[i])
+		fmt.Fprintf(w, "%s %s\tcoverage: %.1f%% of statements\n", kind, name, s.percent())
+	}
+	s := statementStats(merged)
+	fmt.Fprintf(w, "merged\tcoverage: %.1f%% of statements\n", s.percent())
+}
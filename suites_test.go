@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestParseSuites(t *testing.T) {
+	got, err := parseSuites("short, full,short")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"short", "full"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, err := parseSuites("short,long"); err == nil {
+		t.Error("got no error for an invalid suite")
+	}
+}
+
+func TestSuiteProfile(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{"coverage.out", "coverage.short.out"},
+		{"out/c.txt", "out/c.short.txt"},
+		{"profile", "profile.short"},
+	} {
+		if got := suiteProfile(tt.in, "short"); got != tt.want {
+			t.Errorf("suiteProfile(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWriteVariantCoverage(t *testing.T) {
+	short := []*cover.Profile{{FileName: "a.go", Mode: "set", Blocks: []cover.ProfileBlock{
+		{StartLine: 1, NumStmt: 1, Count: 1},
+		{StartLine: 2, NumStmt: 3, Count: 0},
+	}}}
+	full := []*cover.Profile{{FileName: "a.go", Mode: "set", Blocks: []cover.ProfileBlock{
+		{StartLine: 1, NumStmt: 1, Count: 0},
+		{StartLine: 2, NumStmt: 3, Count: 1},
+	}}}
+	merged := mergeProfiles([][]*cover.Profile{short, full})
+	var buf bytes.Buffer
+	writeVariantCoverage(&buf, "suite", []string{"short", "full"}, [][]*cover.Profile{short, full}, merged)
+	want := "suite short\tcoverage: 25.0% of statements\n" +
+		"suite full\tcoverage: 75.0% of statements\n" +
+		"merged\tcoverage: 100.0% of statements\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMergeRunOutputs(t *testing.T) {
+	a, b := &listPackage{ImportPath: "a"}, &listPackage{ImportPath: "b"}
+	short := &runOutputs{
+		profiles:   []*cover.Profile{{FileName: "a.go", Mode: "set", Blocks: []cover.ProfileBlock{{StartLine: 1, NumStmt: 1, Count: 1}}}},
+		state:      &runState{},
+		pkgs:       []*listPackage{a},
+		results:    []*packageResult{{Pkg: "a", Success: false}},
+		failedPkgs: []string{"a"},
+		generated:  []generatedFile{{File: "a_gen.go"}},
+	}
+	full := &runOutputs{
+		profiles:   []*cover.Profile{{FileName: "a.go", Mode: "set", Blocks: []cover.ProfileBlock{{StartLine: 1, NumStmt: 1, Count: 0}}}},
+		state:      &runState{},
+		pkgs:       []*listPackage{a, b},
+		results:    []*packageResult{{Pkg: "a", Success: false}, {Pkg: "b", Success: true}},
+		failedPkgs: []string{"a"},
+		generated:  []generatedFile{{File: "a_gen.go"}},
+	}
+	m := mergeRunOutputs([]*runOutputs{short, full})
+	if m.state != short.state {
+		t.Error("got the state of a later run, want the one of the first run")
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(m.importPaths, want) {
+		t.Errorf("importPaths = %v, want %v", m.importPaths, want)
+	}
+	if want := []string{"a"}; !reflect.DeepEqual(m.failedPkgs, want) {
+		t.Errorf("failedPkgs = %v, want %v", m.failedPkgs, want)
+	}
+	if len(m.results) != 3 {
+		t.Errorf("got %d results, want 3", len(m.results))
+	}
+	if len(m.generated) != 1 {
+		t.Errorf("got %d generated files, want 1", len(m.generated))
+	}
+	if got := m.profiles[0].Blocks[0].Count; got != 1 {
+		t.Errorf("merged count = %d, want 1", got)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// noTags is the name of the empty tag set of -tag-matrix.
+const noTags = "notags"
+
+// parseTagMatrix parses tag sets of -tag-matrix separated by ";", each of
+// which is tags separated by commas or spaces as -tags of go test. Tags of a
+// set are sorted, and duplicated sets are dropped.
+func parseTagMatrix(s string) [][]string {
+	var sets [][]string
+	seen := make(map[string]bool)
+	for _, set := range strings.Split(s, ";") {
+		ts := strings.FieldsFunc(set, func(r rune) bool { return r == ',' || r == ' ' })
+		sort.Strings(ts)
+		ts = dedupSorted(ts)
+		if name := tagSetName(ts); !seen[name] {
+			seen[name] = true
+			sets = append(sets, ts)
+		}
+	}
+	return sets
+}
+
+// dedupSorted removes duplicates of the sorted ss in place.
+func dedupSorted(ss []string) []string {
+	var out []string
+	for i, s := range ss {
+		if i == 0 || s != ss[i-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// tagSetName returns the name of the tag set, which is the tags joined by
+// commas or noTags for the empty set. It's also the name of the directory of
+// the profile of the set in -tag-profiles.
+func tagSetName(tags []string) string {
+	if len(tags) == 0 {
+		return noTags
+	}
+	return strings.Join(tags, ",")
+}
+
+// runTagMatrix runs tests of the packages once for each tag set by
+// runVariants. The profile of each set is written into the directory named by
+// the set in dir, which is temporary if dir is empty.
+func runTagMatrix(sets [][]string, coverprofile, dir string, args []string) error {
+	coverprofile, err := variantProfile(coverprofile)
+	if err != nil {
+		return err
+	}
+	if dir == "" {
+		if dir, err = ioutil.TempDir("", "goverage-tags"); err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+	}
+	var vs []variant
+	for _, set := range sets {
+		name := tagSetName(set)
+		setDir := filepath.Join(dir, name)
+		if err := os.MkdirAll(setDir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory of tag set %s: %v", name, err)
+		}
+		vs = append(vs, variant{
+			name:    name,
+			profile: filepath.Join(setDir, filepath.Base(coverprofile)),
+			short:   short,
+			tags:    strings.Join(set, ","),
+		})
+	}
+	return runVariants("tags", vs, coverprofile, args)
+}
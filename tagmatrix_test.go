@@ -0,0 +1,35 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTagMatrix(t *testing.T) {
+	got := parseTagMatrix("integration; postgres,integration ;;integration,integration postgres")
+	want := [][]string{{"integration"}, {"integration", "postgres"}, nil}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestTagSetName(t *testing.T) {
+	if got := tagSetName(nil); got != noTags {
+		t.Errorf("got %q for the empty set, want %q", got, noTags)
+	}
+	if got, want := tagSetName([]string{"a", "b"}), "a,b"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestGoList_tags(t *testing.T) {
+	defer func(old string) { tags = old }(tags)
+	tags = ""
+	if got, want := goList("-json", "./...").Args, []string{"go", "list", "-json", "./..."}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	tags = "integration,postgres"
+	if got, want := goList("-json", "./...").Args, []string{"go", "list", "-tags", "integration,postgres", "-json", "./..."}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// writeTAPFile writes test results of pkgs to filename in TAP version 13.
+// Packages without results, which were not run because of -failfast, are
+// reported as skipped. filename "-" means stdout.
+func writeTAPFile(filename string, pkgs []string, results []*packageResult) error {
+	if filename == "-" {
+		return writeTAP(os.Stdout, pkgs, results)
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := writeTAP(f, pkgs, results); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeTAP(w io.Writer, pkgs []string, results []*packageResult) error {
+	byPkg := make(map[string]*packageResult, len(results))
+	for _, r := range results {
+		byPkg[r.Pkg] = r
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "TAP version 13\n1..%d\n", len(pkgs))
+	for i, pkg := range pkgs {
+		r, ok := byPkg[pkg]
+		switch {
+		case !ok:
+			fmt.Fprintf(&b, "ok %d - %s # SKIP not run\n", i+1, pkg)
+		case r.Cached:
+			fmt.Fprintf(&b, "ok %d - %s (cached)\n", i+1, pkg)
+		case r.Success:
+			fmt.Fprintf(&b, "ok %d - %s\n", i+1, pkg)
+		default:
+			fmt.Fprintf(&b, "not ok %d - %s\n", i+1, pkg)
+			output := r.output()
+			fmt.Fprintf(&b, "  ---\n  exit_status: %d\n  type: %s\n  duration_seconds: %.3f\n", r.ExitCode, classifyFailure(output), r.Duration.Seconds())
+			if tail := tailLines(output, outputTailLines); tail != "" {
+				b.WriteString("  output: |\n")
+				for _, line := range strings.Split(tail, "\n") {
+					fmt.Fprintf(&b, "    %s\n", line)
+				}
+			}
+			b.WriteString("  ...\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestWriteTAP(t *testing.T) {
+	pkgs := []string{"example.com/a", "example.com/b", "example.com/c", "example.com/d"}
+	results := []*packageResult{
+		{Pkg: "example.com/a", Success: true},
+		{Pkg: "example.com/b", ExitCode: 1, Duration: 1500 * time.Millisecond, Stdout: []byte("--- FAIL: TestB (0.00s)\nFAIL\n")},
+		{Pkg: "example.com/c", Success: true, Cached: true},
+	}
+	var buf bytes.Buffer
+	if err := writeTAP(&buf, pkgs, results); err != nil {
+		t.Fatal(err)
+	}
+	const want = `TAP version 13
+1..4
+ok 1 - example.com/a
+not ok 2 - example.com/b
+  ---
+  exit_status: 1
+  type: test
+  duration_seconds: 1.500
+  output: |
+    --- FAIL: TestB (0.00s)
+    FAIL
+  ...
+ok 3 - example.com/c (cached)
+ok 4 - example.com/d # SKIP not run
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// selectCoverTarget returns packages in pkgs whose tests depend on the
+// package of the target file, which are the only tests that can cover it,
+// and the import path of the package.
+func selectCoverTarget(pkgs []*listPackage, target string) ([]*listPackage, string, error) {
+	targetPkg, ok := packageOfFile(pkgs, target)
+	if !ok {
+		return nil, "", fmt.Errorf("-cover-target %s is not in target packages", target)
+	}
+	importPaths := make([]string, 0, len(pkgs))
+	for _, p := range pkgs {
+		importPaths = append(importPaths, p.ImportPath)
+	}
+	in, err := listTestInputs(importPaths)
+	if err != nil {
+		return nil, "", err
+	}
+	return dependentPkgs(pkgs, in.deps, targetPkg), targetPkg, nil
+}
+
+// packageOfFile returns the import path of the package in pkgs whose
+// directory contains the file.
+func packageOfFile(pkgs []*listPackage, filename string) (string, bool) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return "", false
+	}
+	dir := filepath.Dir(abs)
+	for _, p := range pkgs {
+		if rel, ok := relPath(p.Dir, dir); ok && rel == "." {
+			return p.ImportPath, true
+		}
+	}
+	return "", false
+}
+
+// dependentPkgs returns packages in pkgs whose tests depend on importPath
+// according to deps, a map from an import path to dependencies of its test.
+func dependentPkgs(pkgs []*listPackage, deps map[string][]string, importPath string) []*listPackage {
+	var result []*listPackage
+	for _, p := range pkgs {
+		for _, d := range deps[p.ImportPath] {
+			if d == importPath {
+				result = append(result, p)
+				break
+			}
+		}
+	}
+	return result
+}
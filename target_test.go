@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPackageOfFile(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgs := []*listPackage{
+		{ImportPath: "example.com/app", Dir: wd},
+		{ImportPath: "example.com/app/auth", Dir: filepath.Join(wd, "auth")},
+	}
+	tests := []struct {
+		filename string
+		want     string
+		wantOK   bool
+	}{
+		{"main.go", "example.com/app", true},
+		{filepath.Join("auth", "token.go"), "example.com/app/auth", true},
+		{filepath.Join(wd, "auth", "token_test.go"), "example.com/app/auth", true},
+		{filepath.Join("store", "user.go"), "", false},
+	}
+	for _, tt := range tests {
+		got, ok := packageOfFile(pkgs, tt.filename)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("packageOfFile(%q) = %q, %v; want %q, %v", tt.filename, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestDependentPkgs(t *testing.T) {
+	a := &listPackage{ImportPath: "example.com/app"}
+	auth := &listPackage{ImportPath: "example.com/app/auth"}
+	store := &listPackage{ImportPath: "example.com/app/store"}
+	deps := map[string][]string{
+		"example.com/app":       {"example.com/app", "example.com/app/auth", "fmt"},
+		"example.com/app/auth":  {"example.com/app/auth", "crypto/sha256"},
+		"example.com/app/store": {"example.com/app/store"},
+	}
+	got := dependentPkgs([]*listPackage{a, auth, store}, deps, "example.com/app/auth")
+	if want := []*listPackage{a, auth}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
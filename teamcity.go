@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/tools/cover"
+)
+
+var teamcityEscaper = strings.NewReplacer(
+	"|", "||",
+	"'", "|'",
+	"\n", "|n",
+	"\r", "|r",
+	"[", "|[",
+	"]", "|]",
+)
+
+// teamcityMessage formats a TeamCity service message with attributes given
+// as name and value pairs.
+func teamcityMessage(name string, attrs ...string) string {
+	var b strings.Builder
+	b.WriteString("##teamcity[" + name)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		fmt.Fprintf(&b, " %s='%s'", attrs[i], teamcityEscaper.Replace(attrs[i+1]))
+	}
+	b.WriteString("]\n")
+	return b.String()
+}
+
+// writeTeamCityTestStarted writes the start of the TeamCity test of a
+// package. Messages of a package have its import path as flowId, so that
+// TeamCity tells packages tested concurrently apart.
+func writeTeamCityTestStarted(w io.Writer, pkg string) {
+	io.WriteString(w, teamcityMessage("testStarted", "name", pkg, "flowId", pkg))
+}
+
+// writeTeamCityTestFinished writes the result of a package as the end of its
+// TeamCity test.
+func writeTeamCityTestFinished(w io.Writer, r *packageResult) {
+	if !r.Success {
+		output := r.output()
+		io.WriteString(w, teamcityMessage("testFailed",
+			"name", r.Pkg,
+			"message", fmt.Sprintf("%s: exit status %d", classifyFailure(output), r.ExitCode),
+			"details", tailLines(output, outputTailLines),
+			"flowId", r.Pkg))
+	}
+	io.WriteString(w, teamcityMessage("testFinished", "name", r.Pkg, "duration", fmt.Sprint(r.Duration.Milliseconds()), "flowId", r.Pkg))
+}
+
+// writeTeamCityCoverage writes statement coverage of profiles as TeamCity
+// build statistics shown in the coverage tab.
+func writeTeamCityCoverage(w io.Writer, profiles []*cover.Profile) {
+	s := statementStats(profiles)
+	io.WriteString(w, teamcityMessage("buildStatisticValue", "key", "CodeCoverageAbsSCovered", "value", fmt.Sprint(s.Covered)))
+	io.WriteString(w, teamcityMessage("buildStatisticValue", "key", "CodeCoverageAbsSTotal", "value", fmt.Sprint(s.Total)))
+	io.WriteString(w, teamcityMessage("buildStatisticValue", "key", "CodeCoverageS", "value", fmt.Sprintf("%.2f", s.percent())))
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestWriteTeamCityTest(t *testing.T) {
+	var buf bytes.Buffer
+	writeTeamCityTestStarted(&buf, "example.com/a")
+	writeTeamCityTestStarted(&buf, "example.com/b")
+	writeTeamCityTestFinished(&buf, &packageResult{Pkg: "example.com/b", ExitCode: 1, Stdout: []byte("--- FAIL: TestB [x] 'y'\nFAIL\n")})
+	writeTeamCityTestFinished(&buf, &packageResult{Pkg: "example.com/a", Success: true, Duration: 1200 * time.Millisecond})
+	const want = `##teamcity[testStarted name='example.com/a' flowId='example.com/a']
+##teamcity[testStarted name='example.com/b' flowId='example.com/b']
+##teamcity[testFailed name='example.com/b' message='test: exit status 1' details='--- FAIL: TestB |[x|] |'y|'|nFAIL' flowId='example.com/b']
+##teamcity[testFinished name='example.com/b' duration='0' flowId='example.com/b']
+##teamcity[testFinished name='example.com/a' duration='1200' flowId='example.com/a']
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteTeamCityCoverage(t *testing.T) {
+	profiles := []*cover.Profile{{Blocks: []cover.ProfileBlock{{NumStmt: 3, Count: 1}, {NumStmt: 1, Count: 0}}}}
+	var buf bytes.Buffer
+	writeTeamCityCoverage(&buf, profiles)
+	const want = `##teamcity[buildStatisticValue key='CodeCoverageAbsSCovered' value='3']
+##teamcity[buildStatisticValue key='CodeCoverageAbsSTotal' value='4']
+##teamcity[buildStatisticValue key='CodeCoverageS' value='75.00']
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/tools/cover"
+)
+
+// testBinariesFile is the name of the manifest of test binaries written by
+// "goverage compile" in the output directory.
+const testBinariesFile = "goverage-tests.json"
+
+// testBinaries is the content of testBinariesFile.
+type testBinaries struct {
+	Packages []*testBinary `json:"packages"`
+}
+
+// testBinary is a coverage-instrumented test binary of a package.
+type testBinary struct {
+	ImportPath string `json:"import_path"`
+	// Binary is the file name of the binary in the output directory.
+	Binary string `json:"binary"`
+	// Dir is the slash separated directory of the package relative to the
+	// directory where "goverage compile" ran. Tests run in it as "go test"
+	// does, if it exists.
+	Dir string `json:"dir"`
+}
+
+// runCompile builds coverage-instrumented test binaries of packages into a
+// directory without running them, so that "goverage exec" runs them
+// elsewhere, e.g. on another machine or container.
+func runCompile(args []string) error {
+	fs := newFlagSet("compile", "-o dir [-covermode mode] [-coverpkg-mode mode] [-race] [-tags tags] package...")
+	out := fs.String("o", "", "Directory to write test binaries to")
+	mode := fs.String("covermode", "", "sent as covermode argument to go test")
+	pkgMode := fs.String("coverpkg-mode", coverpkgAll, "Packages to instrument for tests of each package: all, module, deps or self")
+	race := fs.Bool("race", false, "enable data race detection")
+	tags := fs.String("tags", "", "sent as tags argument to go test")
+	fs.Parse(args)
+	if *out == "" {
+		fs.Usage()
+		return errors.New("goverage compile: -o is required")
+	}
+	pkgs, err := resolvePkgs(fs.Args(), false)
+	if err != nil {
+		return err
+	}
+	coverpkgOf, err := coverpkgs(*pkgMode, pkgs)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		return err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	var bins testBinaries
+	var failed []string
+	for _, p := range pkgs {
+		if len(p.TestGoFiles) == 0 && len(p.XTestGoFiles) == 0 {
+			continue
+		}
+		bin := testBinaryName(p.ImportPath)
+		testArgs := []string{"test", "-c", "-o", filepath.Join(*out, bin), "-coverpkg", coverpkgOf[p.ImportPath]}
+		if *mode != "" {
+			testArgs = append(testArgs, "-covermode", *mode)
+		}
+		if *race {
+			testArgs = append(testArgs, "-race")
+		}
+		if *tags != "" {
+			testArgs = append(testArgs, "-tags", *tags)
+		}
+		cmd := exec.Command("go", append(testArgs, p.ImportPath)...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			failed = append(failed, p.ImportPath)
+			continue
+		}
+		dir := p.Dir
+		if rel, ok := relPath(wd, p.Dir); ok {
+			dir = rel
+		}
+		bins.Packages = append(bins.Packages, &testBinary{ImportPath: p.ImportPath, Binary: bin, Dir: filepath.ToSlash(dir)})
+	}
+	if err := writeJSONFile(filepath.Join(*out, testBinariesFile), bins); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return &BuildError{Packages: failed}
+	}
+	return nil
+}
+
+// testBinaryName returns the file name of the test binary of the package,
+// such as "github.com_me_app_store.test".
+func testBinaryName(importPath string) string {
+	name := strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(importPath) + ".test"
+	goos := os.Getenv("GOOS")
+	if goos == "" {
+		goos = runtime.GOOS
+	}
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// runExec runs test binaries built by "goverage compile" and merges their
+// profiles.
+func runExec(args []string) error {
+	fs := newFlagSet("exec", "[-coverprofile coverage.out] [-run regexp] [-v] dir")
+	profile := fs.String("coverprofile", "coverage.out", "Write the merged profile to the file")
+	run := fs.String("run", "", "Run only tests matching the regexp")
+	verbose := fs.Bool("v", false, "Print verbose output of tests")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("goverage exec: a directory of test binaries is required")
+	}
+	dir, err := filepath.Abs(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, testBinariesFile))
+	if err != nil {
+		return fmt.Errorf("goverage exec: %v", err)
+	}
+	var bins testBinaries
+	if err := json.Unmarshal(b, &bins); err != nil {
+		return fmt.Errorf("goverage exec: invalid %s: %v", testBinariesFile, err)
+	}
+	var cpss [][]*cover.Profile
+	var failed []string
+	for _, bin := range bins.Packages {
+		tmp, err := tmpProfileName()
+		if err != nil {
+			return err
+		}
+		testArgs := []string{"-test.coverprofile=" + tmp}
+		if *run != "" {
+			testArgs = append(testArgs, "-test.run="+*run)
+		}
+		if *verbose {
+			testArgs = append(testArgs, "-test.v")
+		}
+		cmd := exec.Command(filepath.Join(dir, bin.Binary), testArgs...)
+		if fi, err := os.Stat(filepath.FromSlash(bin.Dir)); err == nil && fi.IsDir() {
+			cmd.Dir = filepath.FromSlash(bin.Dir)
+		} else {
+			log.Printf("%s: directory %s does not exist; run tests in the current directory", bin.ImportPath, bin.Dir)
+		}
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := testCmds.run(cmd); err != nil {
+			failed = append(failed, bin.ImportPath)
+		}
+		if isExist(tmp) {
+			ps, err := cover.ParseProfiles(tmp)
+			os.Remove(tmp)
+			if err != nil {
+				return &ProfileParseError{File: tmp, Err: err}
+			}
+			cpss = append(cpss, ps)
+		}
+	}
+	if err := writeProfile(*profile, mergeProfiles(cpss)); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return &TestFailure{Packages: failed}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"testing"
+)
+
+func TestTestBinaryName(t *testing.T) {
+	defer os.Setenv("GOOS", os.Getenv("GOOS"))
+	os.Setenv("GOOS", "linux")
+	if got, want := testBinaryName("github.com/me/app/store"), "github.com_me_app_store.test"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	os.Setenv("GOOS", "windows")
+	if got, want := testBinaryName("github.com/me/app"), "github.com_me_app.test.exe"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	os.Setenv("GOOS", "")
+	want := "app.test"
+	if runtime.GOOS == "windows" {
+		want += ".exe"
+	}
+	if got := testBinaryName("app"); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
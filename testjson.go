@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// testEvent is an event of "go test -json". Fields are kept as is so that
+// fields added by newer Go are not lost.
+type testEvent map[string]interface{}
+
+// useTestJSON reports whether "go test" runs with -json, which is needed to
+// write events and to tell outcomes of tests in attempts.
+func useTestJSON() bool {
+	return testJSONOutput != "" || retries > 0 || detectFlaky > 1
+}
+
+// parseTestJSON parses stdout of "go test -json" for pkg. It returns events
+// in which non-JSON lines of stdout and lines of stderr (e.g. build errors)
+// are converted to output events, and events without a package are set to
+// pkg. output is the plain text output of the test reconstructed from the
+// events.
+func parseTestJSON(pkg string, stdout, stderr []byte) (events [][]byte, output []byte) {
+	var out bytes.Buffer
+	add := func(e testEvent) {
+		if p, _ := e["Package"].(string); p == "" {
+			e["Package"] = pkg
+		}
+		if o, ok := e["Output"].(string); ok {
+			out.WriteString(o)
+		}
+		b, err := json.Marshal(e)
+		if err != nil {
+			return
+		}
+		events = append(events, b)
+	}
+	for _, src := range [][]byte{stdout, stderr} {
+		s := bufio.NewScanner(bytes.NewReader(src))
+		s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for s.Scan() {
+			line := s.Bytes()
+			var e testEvent
+			if len(line) > 0 && line[0] == '{' && json.Unmarshal(line, &e) == nil {
+				add(e)
+				continue
+			}
+			add(testEvent{"Action": "output", "Output": string(line) + "\n"})
+		}
+	}
+	return events, out.Bytes()
+}
+
+// writeTestEvents writes events of the package result annotated with vcs, one
+// per line. A cached result, which has no events, is written as a passed
+// package.
+func writeTestEvents(w io.Writer, r *packageResult, vcs vcsInfo) error {
+	events := r.Events
+	if r.Cached {
+		now := time.Now()
+		for _, e := range []testEvent{
+			{"Time": now, "Action": "output", "Package": r.Pkg, "Output": "ok  \t" + r.Pkg + "\t(cached)\n"},
+			{"Time": now, "Action": "pass", "Package": r.Pkg, "Elapsed": 0},
+		} {
+			b, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			events = append(events, b)
+		}
+	}
+	for _, e := range events {
+		if _, err := w.Write(append(vcs.annotateEvent(e), '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
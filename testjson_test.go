@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestParseTestJSON(t *testing.T) {
+	stdout := []byte(`{"Action":"run","Test":"TestA","Package":"example.com/a"}
+{"Action":"output","Test":"TestA","Output":"--- FAIL: TestA (0.00s)\n"}
+not json
+{"Action":"fail","Package":"example.com/a","Elapsed":0.1}
+`)
+	stderr := []byte("# example.com/a\n")
+	events, output := parseTestJSON("example.com/a", stdout, stderr)
+	if len(events) != 5 {
+		t.Fatalf("got %d events, want 5", len(events))
+	}
+	for _, b := range events {
+		var e testEvent
+		if err := json.Unmarshal(b, &e); err != nil {
+			t.Fatal(err)
+		}
+		if e["Package"] != "example.com/a" {
+			t.Errorf("event without package: %s", b)
+		}
+	}
+	if want := "--- FAIL: TestA (0.00s)\nnot json\n# example.com/a\n"; string(output) != want {
+		t.Errorf("got output %q, want %q", output, want)
+	}
+}
+
+func TestWriteTestEvents(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeTestEvents(&buf, &packageResult{Pkg: "example.com/a", Events: [][]byte{[]byte(`{"Action":"pass"}`)}}, vcsInfo{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeTestEvents(&buf, &packageResult{Pkg: "example.com/b", Success: true, Cached: true}, vcsInfo{}); err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	s := bufio.NewScanner(&buf)
+	for s.Scan() {
+		var e testEvent
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		actions = append(actions, e["Action"].(string))
+	}
+	if want := []string{"pass", "output", "pass"}; len(actions) != len(want) || actions[0] != want[0] || actions[1] != want[1] || actions[2] != want[2] {
+		t.Errorf("got actions %v, want %v", actions, want)
+	}
+}
+
+func TestRun_test_json_output(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("./example/root"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	tmpfile, err := ioutil.TempFile("", "goverage-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	events, err := ioutil.TempFile("", "goverage-test-events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(events.Name())
+	testJSONOutput = events.Name()
+	err = run(tmpfile.Name(), []string{"./..."}, "", "", "", "", false, false)
+	testJSONOutput = ""
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(events.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	passed := make(map[string]bool)
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		var e testEvent
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			t.Fatalf("invalid event %q: %v", s.Bytes(), err)
+		}
+		if e["Action"] == "pass" && e["Test"] == nil {
+			passed[e["Package"].(string)] = true
+		}
+	}
+	if len(passed) == 0 {
+		t.Errorf("no passed packages in events:\n%s", b)
+	}
+	p, err := ioutil.ReadFile(tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p) == 0 {
+		t.Error("coverage profile is empty")
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"text/tabwriter"
+
+	"golang.org/x/tools/cover"
+)
+
+// runTotal prints the total statement coverage of an existing profile.
+func runTotal(args []string) error {
+	fs := newFlagSet("total", "[-by-package] [-config .goverage.yml] coverage.out")
+	byPackage := fs.Bool("by-package", false, "Also print statement coverage of each package")
+	cfgFile := fs.String("config", "", "Config file (default \""+defaultConfigFile+"\" if exists)")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("goverage total: a profile is required")
+	}
+	cfg, err := loadConfig(configFromRoot(*cfgFile))
+	if err != nil {
+		return err
+	}
+	profiles, err := readProfiles(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	return writeTotal(os.Stdout, cfg.filterProfiles(profiles, newProfileResolver(profiles).resolve), *byPackage)
+}
+
+// writeTotal writes the total statement coverage of profiles as a percentage
+// such as "83.3%", which scripts can compare with thresholds. If byPackage is
+// true, coverage of each package precedes the total.
+func writeTotal(w io.Writer, profiles []*cover.Profile, byPackage bool) error {
+	total := statementStats(profiles)
+	if !byPackage {
+		_, err := fmt.Fprintf(w, "%.1f%%\n", total.percent())
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, s := range packageStats(profiles) {
+		fmt.Fprintf(tw, "%s\t%.1f%%\n", s.Name, s.percent())
+	}
+	fmt.Fprintf(tw, "total\t%.1f%%\n", total.percent())
+	return tw.Flush()
+}
+
+// packageStats returns statement coverage of each package, sorted by the
+// import path.
+func packageStats(profiles []*cover.Profile) []*coverStats {
+	stats := make(map[string]*coverStats)
+	for _, p := range profiles {
+		pkg := path.Dir(p.FileName)
+		s, ok := stats[pkg]
+		if !ok {
+			s = &coverStats{Name: pkg}
+			stats[pkg] = s
+		}
+		ps := statementStats([]*cover.Profile{p})
+		s.Covered += ps.Covered
+		s.Total += ps.Total
+	}
+	result := make([]*coverStats, 0, len(stats))
+	for _, s := range stats {
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
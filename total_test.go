@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestWriteTotal(t *testing.T) {
+	profiles := []*cover.Profile{
+		{FileName: "example.com/app/main.go", Mode: "set", Blocks: []cover.ProfileBlock{
+			{StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 2, NumStmt: 3, Count: 1},
+			{StartLine: 3, StartCol: 1, EndLine: 4, EndCol: 2, NumStmt: 1, Count: 0},
+		}},
+		{FileName: "example.com/app/store/user.go", Mode: "set", Blocks: []cover.ProfileBlock{
+			{StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 2, NumStmt: 2, Count: 0},
+		}},
+		{FileName: "example.com/app/store/item.go", Mode: "set", Blocks: []cover.ProfileBlock{
+			{StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 2, NumStmt: 2, Count: 1},
+		}},
+	}
+	var buf bytes.Buffer
+	if err := writeTotal(&buf, profiles, false); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "62.5%\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	buf.Reset()
+	if err := writeTotal(&buf, profiles, true); err != nil {
+		t.Fatal(err)
+	}
+	want := "" +
+		"example.com/app        75.0%\n" +
+		"example.com/app/store  50.0%\n" +
+		"total                  62.5%\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OTLP span kinds and status codes used by tracer.
+const (
+	otlpSpanKindInternal = 1
+	otlpStatusOK         = 1
+	otlpStatusError      = 2
+)
+
+// tracer records spans of a run and exports them to an OpenTelemetry
+// collector by OTLP/HTTP with JSON encoding. It's configured by the standard
+// OTEL_* environment variables.
+type tracer struct {
+	endpoint    string
+	headers     map[string]string
+	serviceName string
+	client      *http.Client
+	// vcs is the state of the repository, which is exported as resource
+	// attributes.
+	vcs vcsInfo
+
+	traceID string
+	rootID  string
+	start   time.Time
+	spans   []otlpSpan
+
+	// finished is true if the result of the run is set by setResult. Runs
+	// which fail before writing reports have no coverage.
+	finished bool
+	coverage float64
+	failed   int
+}
+
+// newTracer returns a tracer if OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or
+// OTEL_EXPORTER_OTLP_ENDPOINT is set. Otherwise, it returns nil.
+func newTracer(getenv func(string) string) *tracer {
+	endpoint := getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		base := getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if base == "" {
+			return nil
+		}
+		endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+	headers := parseOTLPHeaders(getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	for k, v := range parseOTLPHeaders(getenv("OTEL_EXPORTER_OTLP_TRACES_HEADERS")) {
+		headers[k] = v
+	}
+	serviceName := getenv("OTEL_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = "goverage"
+	}
+	return &tracer{
+		endpoint:    endpoint,
+		headers:     headers,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		traceID:     randomHex(16),
+		rootID:      randomHex(8),
+		start:       time.Now(),
+	}
+}
+
+// parseOTLPHeaders parses a comma separated list of key=value pairs.
+func parseOTLPHeaders(s string) map[string]string {
+	headers := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		i := strings.Index(kv, "=")
+		if i < 0 {
+			continue
+		}
+		headers[strings.TrimSpace(kv[:i])] = strings.TrimSpace(kv[i+1:])
+	}
+	return headers
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// packageSpan records the test of a package which started at start.
+func (t *tracer) packageSpan(r *packageResult, start time.Time) {
+	s := statementStats(r.Profiles)
+	span := otlpSpan{
+		TraceID:           t.traceID,
+		SpanID:            randomHex(8),
+		ParentSpanID:      t.rootID,
+		Name:              "go test " + r.Pkg,
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: unixNano(start),
+		EndTimeUnixNano:   unixNano(time.Now()),
+		Attributes: []otlpAttribute{
+			stringAttribute("goverage.package", r.Pkg),
+			boolAttribute("goverage.success", r.Success),
+			boolAttribute("goverage.cached", r.Cached),
+			intAttribute("goverage.exit_status", r.ExitCode),
+			doubleAttribute("goverage.coverage", s.percent()),
+		},
+		Status: otlpStatus{Code: otlpStatusOK},
+	}
+	if !r.Success {
+		span.Status = otlpStatus{Code: otlpStatusError, Message: classifyFailure(r.output())}
+	}
+	t.spans = append(t.spans, span)
+}
+
+// setResult sets total coverage and the number of failed packages of the run.
+func (t *tracer) setResult(coverage float64, failed int) {
+	t.finished, t.coverage, t.failed = true, coverage, failed
+}
+
+// export records the span of the whole run, which ended with runErr, and
+// sends all spans to the collector.
+func (t *tracer) export(runErr error) error {
+	root := otlpSpan{
+		TraceID:           t.traceID,
+		SpanID:            t.rootID,
+		Name:              "goverage",
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: unixNano(t.start),
+		EndTimeUnixNano:   unixNano(time.Now()),
+		Attributes: []otlpAttribute{
+			intAttribute("goverage.packages", len(t.spans)),
+			intAttribute("goverage.failed_packages", t.failed),
+		},
+		Status: otlpStatus{Code: otlpStatusOK},
+	}
+	if t.finished {
+		root.Attributes = append(root.Attributes, doubleAttribute("goverage.coverage", t.coverage))
+	}
+	if t.failed > 0 {
+		root.Status = otlpStatus{Code: otlpStatusError, Message: fmt.Sprintf("%d package(s) failed", t.failed)}
+	} else if runErr != nil {
+		root.Status = otlpStatus{Code: otlpStatusError, Message: runErr.Error()}
+	}
+	req := otlpTraceRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: append([]otlpAttribute{stringAttribute("service.name", t.serviceName)}, t.vcs.otlpAttributes()...)},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/haya14busa/goverage"},
+			Spans: append([]otlpSpan{root}, t.spans...),
+		}},
+	}}}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		httpReq.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("POST %s: %s", t.endpoint, resp.Status)
+	}
+	return nil
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// OTLP/HTTP JSON request types. See
+// https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/trace/v1/trace.proto
+type otlpTraceRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func stringAttribute(key, v string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: &v}}
+}
+
+func boolAttribute(key string, v bool) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{BoolValue: &v}}
+}
+
+func intAttribute(key string, v int) otlpAttribute {
+	s := strconv.Itoa(v)
+	return otlpAttribute{Key: key, Value: otlpValue{IntValue: &s}}
+}
+
+func doubleAttribute(key string, v float64) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{DoubleValue: &v}}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewTracer(t *testing.T) {
+	env := func(m map[string]string) func(string) string {
+		return func(key string) string { return m[key] }
+	}
+	if tr := newTracer(env(nil)); tr != nil {
+		t.Error("tracer should be disabled without endpoints")
+	}
+	tr := newTracer(env(map[string]string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318/",
+		"OTEL_EXPORTER_OTLP_HEADERS":  "authorization=Bearer x, x-a=1",
+	}))
+	if tr.endpoint != "http://localhost:4318/v1/traces" || tr.serviceName != "goverage" {
+		t.Errorf("unexpected tracer: %+v", tr)
+	}
+	if tr.headers["authorization"] != "Bearer x" || tr.headers["x-a"] != "1" {
+		t.Errorf("unexpected headers: %v", tr.headers)
+	}
+	tr = newTracer(env(map[string]string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT":        "http://localhost:4318",
+		"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "http://collector/traces",
+		"OTEL_SERVICE_NAME":                  "ci",
+	}))
+	if tr.endpoint != "http://collector/traces" || tr.serviceName != "ci" {
+		t.Errorf("unexpected tracer: %+v", tr)
+	}
+}
+
+func TestTracerExport(t *testing.T) {
+	var got otlpTraceRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("x-token") != "secret" {
+			t.Errorf("unexpected headers: %v", r.Header)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+	tr := newTracer(func(key string) string {
+		return map[string]string{
+			"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": srv.URL,
+			"OTEL_EXPORTER_OTLP_TRACES_HEADERS":  "x-token=secret",
+		}[key]
+	})
+	tr.packageSpan(&packageResult{Pkg: "example.com/a", Success: true, Duration: time.Second}, time.Now())
+	tr.packageSpan(&packageResult{Pkg: "example.com/b", ExitCode: 1, Stdout: []byte("--- FAIL: TestB\n")}, time.Now())
+	tr.setResult(50, 1)
+	if err := tr.export(&TestFailure{Packages: []string{"example.com/b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.ResourceSpans) != 1 || len(got.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected request: %+v", got)
+	}
+	spans := got.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+	root := spans[0]
+	if root.Name != "goverage" || root.ParentSpanID != "" || root.Status.Code != otlpStatusError {
+		t.Errorf("unexpected root span: %+v", root)
+	}
+	for _, s := range spans[1:] {
+		if s.TraceID != root.TraceID || s.ParentSpanID != root.SpanID {
+			t.Errorf("span %s is not a child of the root span", s.Name)
+		}
+	}
+	if spans[1].Name != "go test example.com/a" || spans[1].Status.Code != otlpStatusOK {
+		t.Errorf("unexpected span: %+v", spans[1])
+	}
+	if spans[2].Status.Code != otlpStatusError || spans[2].Status.Message != failureTest {
+		t.Errorf("unexpected span: %+v", spans[2])
+	}
+	if len(root.TraceID) != 32 || len(root.SpanID) != 16 {
+		t.Errorf("invalid IDs: %s %s", root.TraceID, root.SpanID)
+	}
+}
+
+func TestTracerExport_error(t *testing.T) {
+	var got otlpTraceRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+	tr := newTracer(func(key string) string {
+		return map[string]string{"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": srv.URL}[key]
+	})
+	// A run interrupted before writing reports has no coverage.
+	if err := tr.export(&ExitError{Msg: "goverage: interrupted", Code: 130}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.ResourceSpans) != 1 || len(got.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected request: %+v", got)
+	}
+	root := got.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	if root.Status.Code != otlpStatusError || root.Status.Message != "goverage: interrupted" {
+		t.Errorf("unexpected status: %+v", root.Status)
+	}
+	for _, a := range root.Attributes {
+		if a.Key == "goverage.coverage" {
+			t.Errorf("unexpected coverage of an interrupted run: %+v", a)
+		}
+	}
+}
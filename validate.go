@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// runValidate checks a profile and prints its problems so that corrupted
+// profiles are diagnosed before they are uploaded or merged.
+func runValidate(args []string) error {
+	fs := newFlagSet("validate", "[-no-files] coverage.out")
+	noFiles := fs.Bool("no-files", false, "Do not check whether files in the profile exist")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("goverage validate: a profile is required")
+	}
+	filename := fs.Arg(0)
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var resolve func(string) (string, error)
+	if !*noFiles {
+		resolve = newFileResolver().resolve
+	}
+	problems, err := validateProfile(f, resolve)
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		fmt.Printf("%s:%d: %s\n", filename, p.Line, p.Msg)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("goverage validate: %d problem(s) in %s", len(problems), filename)
+	}
+	return nil
+}
+
+// profileProblem is a problem found at Line of a profile.
+type profileProblem struct {
+	Line int
+	Msg  string
+}
+
+// blockLineRe matches a block line of a profile. It's the same as the one of
+// golang.org/x/tools/cover.
+var blockLineRe = regexp.MustCompile(`^(.+):([0-9]+)\.([0-9]+),([0-9]+)\.([0-9]+) ([0-9]+) ([0-9]+)$`)
+
+// validateProfile checks the header, the syntax of blocks, duplicate and
+// overlapping blocks of the profile read from r. If resolve is not nil, it
+// also checks files in the profile exist. Problems are sorted by line.
+func validateProfile(r io.Reader, resolve func(string) (string, error)) ([]profileProblem, error) {
+	var problems []profileProblem
+	report := func(line int, format string, args ...interface{}) {
+		problems = append(problems, profileProblem{Line: line, Msg: fmt.Sprintf(format, args...)})
+	}
+	p, err := readRawProfile(r, report)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range p.files {
+		if resolve != nil {
+			line := p.blocks[name][0].line
+			if filename, err := resolve(name); err != nil {
+				report(line, "%v", err)
+			} else if !isExist(filename) {
+				report(line, "file %s does not exist", filename)
+			}
+		}
+		p.conflicts(name, func(b, prev rawBlock, duplicate bool) {
+			if duplicate {
+				report(b.line, "duplicate block of %s (line %d)", name, prev.line)
+			} else {
+				report(b.line, "block overlaps with the block of %s at line %d", name, prev.line)
+			}
+		})
+	}
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Line < problems[j].Line })
+	return problems, nil
+}
+
+// rawBlock is a block line of a profile as written. Unlike
+// golang.org/x/tools/cover, duplicate blocks are not merged.
+type rawBlock struct {
+	line    int
+	pos     blockPos
+	numStmt int
+	count   int
+}
+
+// rawProfile is blocks of a profile as written.
+type rawProfile struct {
+	mode string
+	// files is file names in order of their first appearance and blocks
+	// maps them to their blocks.
+	files  []string
+	blocks map[string][]rawBlock
+	// skipped is the number of invalid lines which are skipped.
+	skipped int
+}
+
+// readRawProfile reads the profile from r. Problems of the header and the
+// syntax of blocks are reported by report, and invalid lines are skipped.
+func readRawProfile(r io.Reader, report func(line int, format string, args ...interface{})) (*rawProfile, error) {
+	p := &rawProfile{blocks: make(map[string][]rawBlock)}
+	s := bufio.NewScanner(r)
+	l := 0
+	for s.Scan() {
+		l++
+		line := s.Text()
+		if l == 1 {
+			if strings.HasPrefix(line, "mode: ") {
+				p.mode = strings.TrimPrefix(line, "mode: ")
+				if p.mode != "set" && p.mode != "count" && p.mode != "atomic" {
+					report(l, "unknown mode %q", p.mode)
+				}
+				continue
+			}
+			report(l, "missing mode header")
+		}
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "mode: ") {
+			report(l, "unexpected mode line %q", line)
+			p.skipped++
+			continue
+		}
+		m := blockLineRe.FindStringSubmatch(line)
+		if m == nil {
+			report(l, "invalid block %q", line)
+			p.skipped++
+			continue
+		}
+		n, err := atois(m[2:])
+		if err != nil {
+			report(l, "invalid block %q: %v", line, err)
+			p.skipped++
+			continue
+		}
+		pos := blockPos{n[0], n[1], n[2], n[3]}
+		if pos.endLine < pos.startLine || pos.endLine == pos.startLine && pos.endCol < pos.startCol {
+			report(l, "block ends before it starts")
+		}
+		if p.mode == "set" && n[5] > 1 {
+			report(l, "count %d in set mode", n[5])
+		}
+		if _, ok := p.blocks[m[1]]; !ok {
+			p.files = append(p.files, m[1])
+		}
+		p.blocks[m[1]] = append(p.blocks[m[1]], rawBlock{line: l, pos: pos, numStmt: n[4], count: n[5]})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if l == 0 {
+		report(1, "missing mode header")
+	}
+	return p, nil
+}
+
+// conflicts calls fn for each block of the file which duplicates or overlaps
+// with the preceding block in order of positions.
+func (p *rawProfile) conflicts(name string, fn func(b, prev rawBlock, duplicate bool)) {
+	blocks := append([]rawBlock(nil), p.blocks[name]...)
+	sort.SliceStable(blocks, func(i, j int) bool {
+		pi, pj := blocks[i].pos, blocks[j].pos
+		return pi.startLine < pj.startLine || pi.startLine == pj.startLine && pi.startCol < pj.startCol
+	})
+	for i := 1; i < len(blocks); i++ {
+		prev, b := blocks[i-1], blocks[i]
+		switch {
+		case b.pos == prev.pos:
+			fn(b, prev, true)
+		case b.pos.startLine < prev.pos.endLine || b.pos.startLine == prev.pos.endLine && b.pos.startCol < prev.pos.endCol:
+			fn(b, prev, false)
+		}
+	}
+}
+
+// atois converts decimal numbers.
+func atois(ss []string) ([]int, error) {
+	ns := make([]int, len(ss))
+	for i, s := range ss {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, err
+		}
+		ns[i] = n
+	}
+	return ns, nil
+}
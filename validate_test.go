@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateProfile(t *testing.T) {
+	const profile = "mode: set\n" +
+		"example.com/a/a.go:3.10,5.2 1 1\n" +
+		"example.com/a/a.go:5.2,7.3 1 2\n" +
+		"example.com/a/a.go:3.10,5.2 1 0\n" +
+		"example.com/a/a.go:6.1,8.2 1 0\n" +
+		"example.com/b/b.go:3.10 1 0\n" +
+		"example.com/b/b.go:9.1,8.2 1 0\n" +
+		"mode: set\n" +
+		"example.com/missing/c.go:1.1,2.2 1 0\n"
+	resolve := func(name string) (string, error) {
+		if strings.HasPrefix(name, "example.com/missing/") {
+			return "", errors.New("cannot find package")
+		}
+		return "validate_test.go", nil
+	}
+	got, err := validateProfile(strings.NewReader(profile), resolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []profileProblem{
+		{Line: 3, Msg: "count 2 in set mode"},
+		{Line: 4, Msg: "duplicate block of example.com/a/a.go (line 2)"},
+		{Line: 5, Msg: "block overlaps with the block of example.com/a/a.go at line 3"},
+		{Line: 6, Msg: `invalid block "example.com/b/b.go:3.10 1 0"`},
+		{Line: 7, Msg: "block ends before it starts"},
+		{Line: 8, Msg: `unexpected mode line "mode: set"`},
+		{Line: 9, Msg: "cannot find package"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestValidateProfile_header(t *testing.T) {
+	tests := []struct {
+		profile string
+		want    []profileProblem
+	}{
+		{"mode: count\nexample.com/a/a.go:3.10,5.2 1 3\n", nil},
+		{"", []profileProblem{{Line: 1, Msg: "missing mode header"}}},
+		{"mode: foo\n", []profileProblem{{Line: 1, Msg: `unknown mode "foo"`}}},
+		{"example.com/a/a.go:3.10,5.2 1 3\n", []profileProblem{{Line: 1, Msg: "missing mode header"}}},
+	}
+	for _, tt := range tests {
+		got, err := validateProfile(strings.NewReader(tt.profile), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("validateProfile(%q) = %v, want %v", tt.profile, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strings"
+)
+
+// vcsInfo is the state of the git repository of a run. It's embedded in JSON
+// outputs so that coverage data points can be correlated with commits.
+type vcsInfo struct {
+	Revision string `json:"git_revision,omitempty"`
+	Branch   string `json:"git_branch,omitempty"`
+	Dirty    bool   `json:"git_dirty,omitempty"`
+}
+
+// detectVCS returns the state of the git repository of the working
+// directory. It's empty outside of git repositories.
+func detectVCS() vcsInfo {
+	var vcs vcsInfo
+	root, err := gitRoot()
+	if err != nil {
+		return vcs
+	}
+	vcs.Revision, vcs.Dirty, _ = gitRevision(root)
+	vcs.Branch = gitBranch(root)
+	return vcs
+}
+
+// gitBranch returns the current branch of the repository at root. It's empty
+// if HEAD is detached, e.g. in CI checkouts of commits.
+func gitBranch(root string) string {
+	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	if branch := strings.TrimSpace(string(out)); branch != "HEAD" {
+		return branch
+	}
+	return ""
+}
+
+// annotateEvent returns the "go test -json" event with fields of vcs, which
+// are named like other fields of events. Events which cannot be decoded and
+// empty vcs are returned as is.
+func (vcs vcsInfo) annotateEvent(event []byte) []byte {
+	if vcs.Revision == "" {
+		return event
+	}
+	var e testEvent
+	if err := json.Unmarshal(event, &e); err != nil {
+		return event
+	}
+	e["GitRevision"] = vcs.Revision
+	if vcs.Branch != "" {
+		e["GitBranch"] = vcs.Branch
+	}
+	if vcs.Dirty {
+		e["GitDirty"] = true
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return event
+	}
+	return b
+}
+
+// otlpAttributes returns vcs as OpenTelemetry resource attributes of the
+// semantic conventions for VCS.
+func (vcs vcsInfo) otlpAttributes() []otlpAttribute {
+	var attrs []otlpAttribute
+	if vcs.Revision != "" {
+		attrs = append(attrs, stringAttribute("vcs.ref.head.revision", vcs.Revision))
+	}
+	if vcs.Branch != "" {
+		attrs = append(attrs, stringAttribute("vcs.ref.head.name", vcs.Branch))
+	}
+	if vcs.Revision != "" {
+		attrs = append(attrs, boolAttribute("goverage.git_dirty", vcs.Dirty))
+	}
+	return attrs
+}
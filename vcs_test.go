@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestVCSInfo_annotateEvent(t *testing.T) {
+	event := []byte(`{"Action":"pass","Package":"example.com/a"}`)
+	if got := (vcsInfo{}).annotateEvent(event); string(got) != string(event) {
+		t.Errorf("got %s without revision", got)
+	}
+	vcs := vcsInfo{Revision: "abc", Branch: "main", Dirty: true}
+	var e testEvent
+	if err := json.Unmarshal(vcs.annotateEvent(event), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e["Action"] != "pass" || e["GitRevision"] != "abc" || e["GitBranch"] != "main" || e["GitDirty"] != true {
+		t.Errorf("got %v", e)
+	}
+	if got := vcs.annotateEvent([]byte("not json")); string(got) != "not json" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestVCSInfo_json(t *testing.T) {
+	b, err := json.Marshal(failuresReport{vcsInfo: vcsInfo{Revision: "abc"}, Failures: []packageFailure{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"git_revision":"abc","failures":[],"skipped":null}`; string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+}
+
+func TestVCSInfo_otlpAttributes(t *testing.T) {
+	if attrs := (vcsInfo{}).otlpAttributes(); len(attrs) != 0 {
+		t.Errorf("got %v without revision", attrs)
+	}
+	attrs := vcsInfo{Revision: "abc", Branch: "main"}.otlpAttributes()
+	if len(attrs) != 3 || attrs[0].Key != "vcs.ref.head.revision" || attrs[1].Key != "vcs.ref.head.name" {
+		t.Errorf("got %+v", attrs)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// buildVersion is the version of the goverage binary and the toolchain which
+// built it.
+type buildVersion struct {
+	Version  string
+	Revision string
+	Modified bool
+	Go       string
+}
+
+// readBuildVersion returns the version of the running binary from its build
+// info.
+func readBuildVersion() buildVersion {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return buildVersion{Version: version, Go: runtime.Version()}
+	}
+	return buildVersionOf(info, version)
+}
+
+// buildVersionOf returns the version in the build info. ldVersion, which
+// releases set by -ldflags, takes precedence over the module version, which
+// is "(devel)" for builds in the repository.
+func buildVersionOf(info *debug.BuildInfo, ldVersion string) buildVersion {
+	bv := buildVersion{Version: ldVersion, Go: info.GoVersion}
+	if bv.Go == "" {
+		bv.Go = runtime.Version()
+	}
+	if ldVersion == "devel" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		bv.Version = info.Main.Version
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			bv.Revision = s.Value
+		case "vcs.modified":
+			bv.Modified = s.Value == "true"
+		}
+	}
+	return bv
+}
+
+func (bv buildVersion) String() string {
+	s := "goverage " + bv.Version
+	if bv.Revision != "" {
+		rev := bv.Revision
+		if len(rev) > 12 {
+			rev = rev[:12]
+		}
+		if bv.Modified {
+			rev += ", modified"
+		}
+		s += fmt.Sprintf(" (%s)", rev)
+	}
+	return s + " built with " + bv.Go
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+func TestBuildVersionOf(t *testing.T) {
+	info := &debug.BuildInfo{
+		GoVersion: "go1.22.1",
+		Main:      debug.Module{Path: "github.com/haya14busa/goverage", Version: "v1.4.0"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "4fafad5c0d5e3b0c8f6b5f1f7d2e5c9a6b1e2d3f"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+	bv := buildVersionOf(info, "devel")
+	if want := (buildVersion{Version: "v1.4.0", Revision: "4fafad5c0d5e3b0c8f6b5f1f7d2e5c9a6b1e2d3f", Modified: true, Go: "go1.22.1"}); bv != want {
+		t.Errorf("got %+v, want %+v", bv, want)
+	}
+	if want := "goverage v1.4.0 (4fafad5c0d5e, modified) built with go1.22.1"; bv.String() != want {
+		t.Errorf("got %q, want %q", bv.String(), want)
+	}
+	if bv := buildVersionOf(info, "v1.5.0"); bv.Version != "v1.5.0" {
+		t.Errorf("got version %q, want the one set by -ldflags", bv.Version)
+	}
+	info.Main.Version = "(devel)"
+	info.Settings = nil
+	bv = buildVersionOf(info, "devel")
+	if want := "goverage devel built with go1.22.1"; bv.String() != want {
+		t.Errorf("got %q, want %q", bv.String(), want)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// workerPool is remote machines of -workers, which run tests of packages over
+// SSH in copies of the module. It's experimental.
+type workerPool struct {
+	// hosts is idle hosts. A host given several times runs tests of that
+	// many packages concurrently.
+	hosts chan string
+	all   []string
+	// dir is the directory of the copy of the module on workers, relative to
+	// their home directories.
+	dir string
+	// wd is the slash separated working directory relative to the root of
+	// the module.
+	wd string
+}
+
+// workers is the pool of -workers. It's set by run.
+var workers *workerPool
+
+// newWorkerPool returns workers of the comma separated hosts, which test the
+// module in root.
+func newWorkerPool(hosts, root string) (*workerPool, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	rel, ok := relPath(root, wd)
+	if !ok {
+		return nil, fmt.Errorf("-workers: the current directory is outside of %s", root)
+	}
+	w := &workerPool{dir: ".goverage-" + filepath.Base(root), wd: rel}
+	for _, h := range strings.Split(hosts, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			w.all = append(w.all, h)
+		}
+	}
+	if len(w.all) == 0 {
+		return nil, errors.New("-workers: no hosts")
+	}
+	w.hosts = make(chan string, len(w.all))
+	for _, h := range w.all {
+		w.hosts <- h
+	}
+	return w, nil
+}
+
+// sync copies the module in root to the workers by rsync.
+func (w *workerPool) sync(root string) error {
+	seen := make(map[string]bool)
+	errs := make(chan error, len(w.all))
+	var wg sync.WaitGroup
+	for _, h := range w.all {
+		if seen[h] {
+			continue
+		}
+		seen[h] = true
+		wg.Add(1)
+		go func(h string) {
+			defer wg.Done()
+			cmd := exec.Command("rsync", "-az", "--delete", "--exclude=/.git", "--exclude=/.goverage*",
+				root+string(filepath.Separator), h+":"+w.dir+"/")
+			if out, err := cmd.CombinedOutput(); err != nil {
+				errs <- fmt.Errorf("failed to copy the module to %s: %v\n%s", h, err, out)
+			}
+		}(h)
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// acquire blocks until a host is idle and returns it.
+func (w *workerPool) acquire() string {
+	return <-w.hosts
+}
+
+func (w *workerPool) release(host string) {
+	w.hosts <- host
+}
+
+// remoteHome is the prefix of paths on workers relative to their home
+// directories. See remoteShellArg.
+const remoteHome = "~/"
+
+// remoteProfile returns the path of the profile on workers for the local
+// profile, which starts with remoteHome.
+func (w *workerPool) remoteProfile(local string) string {
+	return remoteHome + path.Join(w.dir, ".goverage-"+filepath.Base(local))
+}
+
+// remoteShellArg quotes the argument for the shell of workers. Paths starting
+// with remoteHome are expanded to absolute ones, since "go test" resolves a
+// relative -coverprofile against the package directory.
+func remoteShellArg(a string) string {
+	if strings.HasPrefix(a, remoteHome) {
+		return `"$HOME"/` + shellQuote(strings.TrimPrefix(a, remoteHome))
+	}
+	return shellQuote(a)
+}
+
+// command returns a command to run the go command with args on the host in
+// the working directory. env is set for the go command.
+func (w *workerPool) command(host string, args, env []string) *exec.Cmd {
+	script := []string{"cd", shellQuote(path.Join(w.dir, w.wd)), "&&"}
+	if goflags := os.Getenv("GOFLAGS"); goflags != "" {
+		env = append([]string{"GOFLAGS=" + goflags}, env...)
+	}
+	if len(env) > 0 {
+		script = append(script, "env")
+		for _, kv := range env {
+			script = append(script, shellQuote(kv))
+		}
+	}
+	script = append(script, "go")
+	for _, a := range args {
+		script = append(script, remoteShellArg(a))
+	}
+	return exec.Command("ssh", host, strings.Join(script, " "))
+}
+
+// fetch moves the remote profile on the host to the local file. The local
+// file is not created if the remote profile doesn't exist, e.g. because the
+// package has no tests.
+func (w *workerPool) fetch(host, remote, local string) error {
+	p := remoteShellArg(remote)
+	out, err := exec.Command("ssh", host, "test ! -e "+p+" || { cat "+p+" && rm -f "+p+"; }").Output()
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s from %s: %v", remote, host, err)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return ioutil.WriteFile(local, out, 0644)
+}
+
+// shellQuote quotes s for POSIX shells.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_=./,:@+") == "" {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// remoteArgs returns args with the local profile after -coverprofile replaced
+// with the remote one.
+func remoteArgs(args []string, local, remote string) []string {
+	result := make([]string, len(args))
+	for i, a := range args {
+		if a == local && i > 0 && args[i-1] == "-coverprofile" {
+			a = remote
+		}
+		result[i] = a
+	}
+	return result
+}
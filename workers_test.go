@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWorkerPool(t *testing.T) {
+	defer os.Setenv("GOFLAGS", os.Getenv("GOFLAGS"))
+	os.Setenv("GOFLAGS", "")
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Dir(wd)
+	w, err := newWorkerPool("a, b,a", root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b", "a"}; !reflect.DeepEqual(w.all, want) {
+		t.Errorf("hosts: got %v, want %v", w.all, want)
+	}
+	dir := ".goverage-" + filepath.Base(root)
+	remote := w.remoteProfile(filepath.Join(os.TempDir(), "goverage123"))
+	if want := "~/" + dir + "/.goverage-goverage123"; remote != want {
+		t.Errorf("remoteProfile: got %q, want %q", remote, want)
+	}
+	args := remoteArgs([]string{"test", "./foo", "-coverprofile", "/tmp/x", "-run", "Test it's"}, "/tmp/x", remote)
+	cmd := w.command("a", args, []string{"A=1 2"})
+	want := []string{"ssh", "a", "cd " + dir + "/" + filepath.Base(wd) + " && env 'A=1 2' go test ./foo -coverprofile \"$HOME\"/" + dir + "/.goverage-goverage123" + ` -run 'Test it'\''s'`}
+	if !reflect.DeepEqual(cmd.Args, want) {
+		t.Errorf("command: got %q, want %q", cmd.Args, want)
+	}
+	if got, want := w.acquire(), "a"; got != want {
+		t.Errorf("acquire: got %q, want %q", got, want)
+	}
+	if _, err := newWorkerPool(" ,", root); err == nil {
+		t.Error("want an error without hosts")
+	}
+	if _, err := newWorkerPool("a", filepath.Join(wd, "example")); err == nil {
+		t.Error("want an error outside of the root")
+	}
+}